@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+func (app *application) listArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	archiveType := httprouter.ParamsFromContext(r.Context()).ByName("type")
+	if !validator.In(archiveType, entity.ArchiveTypes...) {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		repository.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-deleted_at")
+	input.Filters.SortSafelist = []string{"id", "-id", "deleted_at", "-deleted_at"}
+
+	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := app.models.Archive.GetAll(archiveType, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"archived": items, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreArchivedHandler(w http.ResponseWriter, r *http.Request) {
+	archiveType := httprouter.ParamsFromContext(r.Context()).ByName("type")
+
+	id, err := app.readIDParam(r)
+	if err != nil || !validator.In(archiveType, entity.ArchiveTypes...) {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Archive.Restore(archiveType, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrParentArchived):
+			app.parentArchivedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "record successfully restored"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
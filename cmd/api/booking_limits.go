@@ -0,0 +1,352 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// bookingLimits returns the limits of a show, falling back to the server's
+// defaults when the show has none of its own.
+func (app *application) bookingLimits(showId int64) (*entity.BookingLimits, error) {
+	limits, err := app.models.BookingLimits.GetForShow(showId)
+	if err != nil {
+		if !errors.Is(err, repository.ErrRecordNotFound) {
+			return nil, err
+		}
+
+		limits = &entity.BookingLimits{
+			ShowId:          showId,
+			MaxSeatsPerUser: int32(app.config.limits.maxSeats),
+			MaxPendingHolds: int32(app.config.limits.maxPendingHolds),
+			Default:         true,
+		}
+	}
+
+	return limits, nil
+}
+
+// enforceBookingLimits checks that the user may hold more seats and
+// unpaid reservations for a show, writing an error response and
+// returning false if not. The booking must be inserted in tx, which keeps
+// other bookings of the user for the show waiting until it ends. Going over
+// the seat limit together with accounts that paid with the same payment
+// identity, or booking too many seats from one IP address, flags the account
+// for an admin to review.
+func (app *application) enforceBookingLimits(w http.ResponseWriter, r *http.Request, tx *sql.Tx, userId, showId int64, seats, holds int) bool {
+	limits, err := app.bookingLimits(showId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	ip := clientIP(r)
+
+	usage, err := app.models.BookingLimits.Usage(tx, userId, showId, ip, app.config.limits.velocityWindow)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return false
+	}
+
+	v := validator.New()
+
+	v.Check(usage.SeatsHeld+seats <= int(limits.MaxSeatsPerUser), "tickets", "must not bring your seats for this show over "+strconv.Itoa(int(limits.MaxSeatsPerUser)))
+	v.Check(holds == 0 || usage.PendingHolds+holds <= int(limits.MaxPendingHolds), "tickets", "you already have "+strconv.Itoa(usage.PendingHolds)+" unpaid reservations for this show, pay for or wait out one first")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return false
+	}
+
+	reason := ""
+	switch {
+	case usage.LinkedSeats > 0 && usage.LinkedSeats+usage.SeatsHeld+seats > int(limits.MaxSeatsPerUser):
+		reason = entity.FlagSharedPayment
+	case ip != "" && usage.IPSeats+seats > app.config.limits.velocityMaxSeats:
+		reason = entity.FlagSharedIP
+	}
+
+	if reason != "" {
+		err = app.models.BookingLimits.Flag(userId, showId, reason)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+
+		app.logger.PrintInfo("account flagged", map[string]string{
+			"user_id": strconv.FormatInt(userId, 10),
+			"show_id": strconv.FormatInt(showId, 10),
+			"reason":  reason,
+		})
+
+		app.bookingVelocityResponse(w, r)
+		return false
+	}
+
+	return true
+}
+
+// clientIP returns the IP address a request was made from.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+
+	return ip
+}
+
+func (app *application) showBookingLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	limits, err := app.bookingLimits(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"booking_limits": limits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateBookingLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		MaxSeatsPerUser int32 `json:"max_seats_per_user"`
+		MaxPendingHolds int32 `json:"max_pending_holds"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	limits := &entity.BookingLimits{
+		ShowId:          id,
+		MaxSeatsPerUser: input.MaxSeatsPerUser,
+		MaxPendingHolds: input.MaxPendingHolds,
+	}
+
+	v := validator.New()
+
+	if repository.ValidateBookingLimits(v, limits); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.BookingLimits.SetForShow(limits)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"booking_limits": limits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteBookingLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.BookingLimits.DeleteForShow(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "show booking limits reset to the defaults"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listFlaggedAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Resolved bool
+		repository.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Resolved = app.readBool(qs, "resolved", false, v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	flags, metadata, err := app.models.BookingLimits.GetFlags(input.Resolved, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"flagged_accounts": flags, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) readAccountFlag(w http.ResponseWriter, r *http.Request) (*entity.AccountFlag, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+
+	flag, err := app.models.BookingLimits.GetFlag(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return flag, true
+}
+
+// cancelFlaggedHoldsHandler releases the seats of every unpaid reservation
+// a flagged account holds for the flagged show and resolves the flag. A hold
+// paid for in the meantime is left alone, and a payment that arrives after
+// its hold was cancelled is refunded to the wallet.
+func (app *application) cancelFlaggedHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	flag, ok := app.readAccountFlag(w, r)
+	if !ok {
+		return
+	}
+
+	// Holds for a show that has since been deleted went with it.
+	ids := []int64{}
+	if flag.ShowId != nil {
+		var err error
+
+		ids, err = app.models.BookingLimits.GetPendingHolds(flag.UserId, *flag.ShowId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	cancelled := 0
+	for _, id := range ids {
+		reservation, err := app.models.Reservation.GetById(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrRecordNotFound) {
+				continue
+			}
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		seatIds := make([]int64, len(reservation.Tickets))
+		for i, ticket := range reservation.Tickets {
+			seatIds[i] = ticket.SeatId
+		}
+
+		err = app.models.Reservation.Delete(reservation.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound), errors.Is(err, repository.ErrEditConflict):
+				continue
+			default:
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+		}
+
+		err = app.models.Seat.UpdateSeatStatus(true, reservation.ShowId, seatIds)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		cancelled++
+	}
+
+	err := app.models.BookingLimits.ResolveFlag(flag)
+	if err != nil && !errors.Is(err, repository.ErrEditConflict) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	flag.PendingHolds -= cancelled
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"flagged_account": flag, "cancelled_holds": cancelled}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveFlaggedAccountHandler dismisses a flag without touching the
+// account's reservations.
+func (app *application) resolveFlaggedAccountHandler(w http.ResponseWriter, r *http.Request) {
+	flag, ok := app.readAccountFlag(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.BookingLimits.ResolveFlag(flag)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"flagged_account": flag}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+
+	maxImportBytes = 10 << 20
+	maxImportRows  = 5000
+)
+
+// catalogColumns is the column order of CSV exports. Imports accept the
+// columns in any order as long as the header names them.
+var catalogColumns = []string{"external_id", "title", "year", "runtime", "genres", "age_rating", "language", "img"}
+
+// catalogRow is a movie as it appears in a JSON-lines import or export.
+type catalogRow struct {
+	ExternalId string         `json:"external_id"`
+	Title      string         `json:"title"`
+	Year       int32          `json:"year"`
+	Runtime    entity.Runtime `json:"runtime"`
+	Genres     []string       `json:"genres"`
+	AgeRating  string         `json:"age_rating,omitempty"`
+	Language   string         `json:"language,omitempty"`
+	Img        string         `json:"img,omitempty"`
+}
+
+type importRowError struct {
+	Row    int               `json:"row"`
+	Errors map[string]string `json:"errors"`
+}
+
+// readCatalogCSV parses a CSV import. Genres are separated by "|" and the
+// runtime is a number of minutes. Values that cannot be parsed are reported
+// on the validator of their row rather than failing the whole import.
+func readCatalogCSV(r io.Reader) ([]*entity.Movie, []*validator.Validator, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("body must not be empty")
+		}
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !validator.In(name, catalogColumns...) {
+			return nil, nil, fmt.Errorf("header contains unknown column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"external_id", "title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("header must contain the %q column", name)
+		}
+	}
+
+	var movies []*entity.Movie
+	var validators []*validator.Validator
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(movies) == maxImportRows {
+			return nil, nil, fmt.Errorf("body must not contain more than %d movies", maxImportRows)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		movie := &entity.Movie{
+			ExternalId: field("external_id"),
+			Title:      field("title"),
+			Genres:     []string{},
+			AgeRating:  field("age_rating"),
+			Language:   field("language"),
+			Img:        field("img"),
+		}
+
+		v := validator.New()
+
+		if s := field("year"); s != "" {
+			year, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				v.AddError("year", "must be an integer value")
+			}
+			movie.Year = int32(year)
+		}
+
+		if s := field("runtime"); s != "" {
+			runtime, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				v.AddError("runtime", "must be a number of minutes")
+			}
+			movie.Runtime = entity.Runtime(runtime)
+		}
+
+		for _, genre := range strings.Split(field("genres"), "|") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				movie.Genres = append(movie.Genres, genre)
+			}
+		}
+
+		movies = append(movies, movie)
+		validators = append(validators, v)
+	}
+
+	return movies, validators, nil
+}
+
+// readCatalogJSONLines parses a JSON-lines import, one movie object per line.
+// Blank lines are skipped.
+func readCatalogJSONLines(r io.Reader) ([]*entity.Movie, []*validator.Validator, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1_048_576)
+
+	var movies []*entity.Movie
+	var validators []*validator.Validator
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if len(movies) == maxImportRows {
+			return nil, nil, fmt.Errorf("body must not contain more than %d movies", maxImportRows)
+		}
+
+		var row catalogRow
+
+		v := validator.New()
+
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+
+		err := dec.Decode(&row)
+		if err != nil {
+			v.AddError("json", fmt.Sprintf("must be a valid movie object: %s", err))
+		}
+
+		movie := &entity.Movie{
+			ExternalId: row.ExternalId,
+			Title:      row.Title,
+			Year:       row.Year,
+			Runtime:    row.Runtime,
+			Genres:     row.Genres,
+			AgeRating:  row.AgeRating,
+			Language:   row.Language,
+			Img:        row.Img,
+		}
+
+		movies = append(movies, movie)
+		validators = append(validators, v)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(movies) == 0 {
+		return nil, nil, errors.New("body must not be empty")
+	}
+
+	return movies, validators, nil
+}
+
+// importFormat picks the import format from the format query parameter,
+// falling back to the request's Content-Type.
+func (app *application) importFormat(r *http.Request) string {
+	if format := app.readString(r.URL.Query(), "format", ""); format != "" {
+		return format
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		return formatCSV
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return formatJSONL
+	default:
+		return ""
+	}
+}
+
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	format := app.importFormat(r)
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+
+	v.Check(validator.In(format, formatCSV, formatJSONL), "format", "must be csv or jsonl")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var movies []*entity.Movie
+	var validators []*validator.Validator
+	var err error
+
+	switch format {
+	case formatCSV:
+		movies, validators, err = readCatalogCSV(r.Body)
+	case formatJSONL:
+		movies, validators, err = readCatalogJSONLines(r.Body)
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if len(movies) == 0 {
+		app.badRequestResponse(w, r, errors.New("body must contain at least one movie"))
+		return
+	}
+
+	rowErrors := []importRowError{}
+	seen := make(map[string]int, len(movies))
+
+	for i, movie := range movies {
+		rv := validators[i]
+
+		if movie.AgeRating == "" {
+			movie.AgeRating = entity.RatingGeneral
+		}
+
+		if row, ok := seen[movie.ExternalId]; ok && movie.ExternalId != "" {
+			rv.AddError("external_id", fmt.Sprintf("duplicates row %d", row))
+		}
+		seen[movie.ExternalId] = i + 1
+
+		if repository.ValidateMovieImport(rv, movie); !rv.Valid() {
+			rowErrors = append(rowErrors, importRowError{Row: i + 1, Errors: rv.Errors})
+		}
+	}
+
+	if len(rowErrors) > 0 {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, rowErrors)
+		return
+	}
+
+	summary, err := app.models.Movies.Import(movies, dryRun)
+	if err != nil {
+		var importErr *repository.ImportError
+		switch {
+		case errors.As(err, &importErr) && errors.Is(err, repository.ErrUnknownGenre):
+			rowErrors = append(rowErrors, importRowError{Row: importErr.Index + 1, Errors: map[string]string{"genres": "contains an unknown genre"}})
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, rowErrors)
+		case errors.As(err, &importErr):
+			rowErrors = append(rowErrors, importRowError{Row: importErr.Index + 1, Errors: map[string]string{"movie": "violates a database constraint"}})
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, rowErrors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"import": summary}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// exportMoviesHandler streams the catalogue in a format importMoviesHandler
+// accepts, so an export can be edited and imported again (movies created
+// through the API need an external_id filled in first). Once the first row
+// is written the status can no longer change, so later errors are only
+// logged.
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	format := app.readString(r.URL.Query(), "format", formatCSV)
+
+	if v.Check(validator.In(format, formatCSV, formatJSONL), "format", "must be csv or jsonl"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var err error
+
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="movies.csv"`)
+
+		cw := csv.NewWriter(w)
+
+		err = cw.Write(catalogColumns)
+		if err == nil {
+			err = app.models.Movies.Export(func(movie *entity.Movie) error {
+				return cw.Write([]string{
+					movie.ExternalId,
+					movie.Title,
+					strconv.Itoa(int(movie.Year)),
+					strconv.Itoa(int(movie.Runtime)),
+					strings.Join(movie.Genres, "|"),
+					movie.AgeRating,
+					movie.Language,
+					movie.Img,
+				})
+			})
+		}
+
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	case formatJSONL:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="movies.jsonl"`)
+
+		enc := json.NewEncoder(w)
+
+		err = app.models.Movies.Export(func(movie *entity.Movie) error {
+			return enc.Encode(catalogRow{
+				ExternalId: movie.ExternalId,
+				Title:      movie.Title,
+				Year:       movie.Year,
+				Runtime:    movie.Runtime,
+				Genres:     movie.Genres,
+				AgeRating:  movie.AgeRating,
+				Language:   movie.Language,
+				Img:        movie.Img,
+			})
+		})
+	}
+
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// priceConcessions fills in the name and unit price of items from the
+// catalog of the show's theatre and returns their total. It returns
+// ErrRecordNotFound if an item is not on sale there. Stock is only checked
+// when the items are reserved.
+func (app *application) priceConcessions(show *entity.Show, items []*entity.ConcessionItem) (int64, error) {
+	screen, err := app.models.Screen.Get(show.ScreenId)
+	if err != nil {
+		return 0, err
+	}
+
+	ids := make([]int64, len(items))
+	for i, item := range items {
+		ids[i] = item.ConcessionId
+	}
+
+	concessions, err := app.models.Concessions.GetAllForTheatre(screen.Theatre_id, ids, true)
+	if err != nil {
+		return 0, err
+	}
+
+	byId := make(map[int64]*entity.Concession, len(concessions))
+	for _, concession := range concessions {
+		byId[concession.ID] = concession
+	}
+
+	var total int64
+
+	for _, item := range items {
+		concession, ok := byId[item.ConcessionId]
+		if !ok {
+			return 0, repository.ErrRecordNotFound
+		}
+
+		item.Name = concession.Name
+		item.Price = concession.Price
+		total += concession.Price * int64(item.Quantity)
+	}
+
+	return total, nil
+}
+
+func (app *application) listConcessionsHandler(w http.ResponseWriter, r *http.Request) {
+	theatreId, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	concessions, err := app.models.Concessions.GetAllForTheatre(theatreId, nil, true)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"concessions": concessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createConcessionHandler(w http.ResponseWriter, r *http.Request) {
+	theatreId, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Price       int64  `json:"price"`
+		Stock       int32  `json:"stock"`
+		Active      *bool  `json:"active"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	concession := &entity.Concession{
+		TheatreId:   theatreId,
+		Name:        input.Name,
+		Description: input.Description,
+		Price:       input.Price,
+		Stock:       input.Stock,
+		Active:      true,
+	}
+
+	if input.Active != nil {
+		concession.Active = *input.Active
+	}
+
+	v := validator.New()
+
+	if repository.ValidateConcession(v, concession); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Concessions.Insert(concession)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"concession": concession}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showConcessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	concession, err := app.models.Concessions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"concession": concession}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateConcessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	concession, err := app.models.Concessions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		Price       *int64  `json:"price"`
+		Stock       *int32  `json:"stock"`
+		Active      *bool   `json:"active"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		concession.Name = *input.Name
+	}
+	if input.Description != nil {
+		concession.Description = *input.Description
+	}
+	if input.Price != nil {
+		concession.Price = *input.Price
+	}
+	if input.Stock != nil {
+		concession.Stock = *input.Stock
+	}
+	if input.Active != nil {
+		concession.Active = *input.Active
+	}
+
+	v := validator.New()
+
+	if repository.ValidateConcession(v, concession); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Concessions.Update(concession)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"concession": concession}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteConcessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Concessions.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.concessionSoldResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "concession successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -98,3 +98,47 @@ func (app *application) requestTimeoutResponse(w http.ResponseWriter, r *http.Re
 	message := "payment time out"
 	app.errorResponse(w, r, http.StatusRequestTimeout, message)
 }
+
+func (app *application) ageRestrictedResponse(w http.ResponseWriter, r *http.Request, message string) {
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) reviewNotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you can only review movies you have a paid ticket for and whose show has started"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) activeReservationsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to change the record because it has paid reservations for upcoming shows"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) parentArchivedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to restore the record while its parent is archived, restore the parent instead"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) unpricedSeatResponse(w http.ResponseWriter, r *http.Request) {
+	message := "some of the selected seats have no price for this show"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) concessionSoldResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this concession has been sold and cannot be deleted, deactivate it instead"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) changeWindowClosedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this reservation can no longer be changed this close to the showtime"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) bookingVelocityResponse(w http.ResponseWriter, r *http.Request) {
+	message := "too many seats have been booked for this show from your account or network, the booking has been sent for review"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) resaleClosedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "resale for this show has closed"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// groupShareTransPrefix marks ZaloPay transactions paying for a share of a
+// group booking.
+const groupShareTransPrefix = "gs"
+
+// generateGroupShareTransId returns the ZaloPay transaction id of a share
+// payment, so that every attempt to pay for a share is paid for separately.
+func generateGroupShareTransId(paymentId int64) string {
+	now := time.Now()
+	return fmt.Sprintf("%02d%02d%02d_%s%v", now.Year()%100, int(now.Month()), now.Day(), groupShareTransPrefix, paymentId)
+}
+
+// createGroupBookingHandler holds seats for a group and splits them into
+// shares, each paid separately by a member through its invitation token.
+// Seats are held until the group hold runs out, or until the booking cutoff
+// before the showtime if that comes first.
+func (app *application) createGroupBookingHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		ShowId int64 `json:"show_id"`
+		Shares []struct {
+			Tickets []struct {
+				SeatId     int64  `json:"seat_id"`
+				TicketType string `json:"ticket_type"`
+			} `json:"tickets"`
+		} `json:"shares"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	show, err := app.models.Show.Get(input.ShowId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	movie, err := app.models.Movies.Get(show.MovieId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if message := ageRestrictionError(user, movie, show.Showtime); message != "" {
+		app.ageRestrictedResponse(w, r, message)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(len(input.Shares) >= 2, "shares", "must contain at least 2 shares")
+	v.Check(len(input.Shares) <= 10, "shares", "must not contain more than 10 shares")
+
+	var seatIds []int64
+	shareTickets := make([][]*entity.SeatPrice, len(input.Shares))
+
+	for i, share := range input.Shares {
+		v.Check(len(share.Tickets) > 0, "shares", "must each contain at least 1 seat")
+
+		for _, ticket := range share.Tickets {
+			ticketType := ticket.TicketType
+			if ticketType == "" {
+				ticketType = entity.TicketAdult
+			}
+
+			shareTickets[i] = append(shareTickets[i], &entity.SeatPrice{SeatId: ticket.SeatId, TicketType: ticketType})
+			seatIds = append(seatIds, ticket.SeatId)
+		}
+	}
+
+	v.Check(len(seatIds) <= 20, "shares", "must not contain more than 20 seats in total")
+	v.Check(uniqueIDs(seatIds), "shares", "must not contain the same seat twice")
+
+	expiresAt := time.Now().Add(app.config.booking.groupHold)
+	if deadline := show.Showtime.Add(-app.config.booking.changeCutoff); deadline.Before(expiresAt) {
+		expiresAt = deadline
+	}
+
+	v.Check(expiresAt.After(time.Now()), "show_id", "starts too soon for a group booking")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	booking := &entity.GroupBooking{
+		OrganizerId: user.ID,
+		ShowId:      show.ID,
+		ExpiresAt:   expiresAt,
+	}
+
+	quotes := make([]*pricing.Quote, len(shareTickets))
+
+	for i, tickets := range shareTickets {
+		quotes[i], err = app.quoteShow(show, tickets)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrTicketTypeNotOffered):
+				v.AddError("shares", "must only use ticket types offered for this show")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, repository.ErrRecordNotFound):
+				v.AddError("shares", "must only contain seats of the show's screen")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, repository.ErrUnpricedSeat):
+				app.unpricedSeatResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		share := &entity.GroupShare{
+			Amount:    quotes[i].Total,
+			ExpiresAt: expiresAt,
+			ShowId:    show.ID,
+		}
+		for _, line := range quotes[i].Lines {
+			share.SeatIds = append(share.SeatIds, line.SeatId)
+		}
+
+		booking.Shares = append(booking.Shares, share)
+	}
+
+	tx, err := app.models.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer tx.Rollback()
+
+	// Unpaid shares are held for the group rather than the organizer, so they
+	// only count towards the organizer's seats.
+	if !app.enforceBookingLimits(w, r, tx, user.ID, show.ID, len(seatIds), 0) {
+		return
+	}
+
+	err = app.models.GroupBookings.Insert(tx, booking)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSeatUnavailable):
+			v.AddError("shares", "must only contain available seats")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for i, share := range booking.Shares {
+		seats := make([]*entity.SeatPrice, len(quotes[i].Lines))
+		for j, line := range quotes[i].Lines {
+			seats[j] = &entity.SeatPrice{SeatId: line.SeatId, Category: line.Category, TicketType: line.TicketType, Price: line.Price}
+		}
+
+		reservation := &entity.Reservation{
+			UserId:   user.ID,
+			ShowId:   show.ID,
+			Amount:   share.Amount,
+			ClientIP: clientIP(r),
+		}
+
+		err = app.models.Reservation.Insert(tx, reservation, seats)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		share.GroupBookingId = booking.ID
+		share.ReservationId = &reservation.ID
+
+		err = app.models.GroupBookings.AddShare(tx, share)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"group_booking": booking}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showGroupBookingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	booking, err := app.models.GroupBookings.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if booking.OrganizerId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"group_booking": booking}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// showGroupShareHandler returns the share an invitation token is for with
+// the seats it covers.
+func (app *application) showGroupShareHandler(w http.ResponseWriter, r *http.Request) {
+	share, ok := app.readGroupShare(w, r)
+	if !ok {
+		return
+	}
+
+	env := envelope{"group_share": share}
+
+	if share.ReservationId != nil {
+		reservation, err := app.models.Reservation.GetById(*share.ReservationId)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env["tickets"] = reservation.Tickets
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// payGroupShareHandler starts a ZaloPay payment for a share on behalf of the
+// user. The share's seats become the user's once the payment is confirmed,
+// and the share can be paid by someone else if it is not paid in time. The
+// member must be allowed to see the movie and to use the share's ticket
+// types, as if they had booked the seats themselves.
+func (app *application) payGroupShareHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	share, ok := app.readGroupShare(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(share.Status == entity.SharePending, "status", "share is not waiting for payment")
+	v.Check(share.ExpiresAt.After(time.Now()), "expires_at", "group booking has expired")
+	v.Check(share.ReservationId != nil, "reservation_id", "share no longer holds any seats")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reservation, err := app.models.Reservation.GetById(*share.ReservationId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	show, err := app.models.Show.Get(share.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(show.MovieId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if message := ageRestrictionError(user, movie, show.Showtime); message != "" {
+		app.ageRestrictedResponse(w, r, message)
+		return
+	}
+
+	for _, ticket := range reservation.Tickets {
+		if message := ticketTypeError(user, movie, ticket.TicketType); message != "" {
+			v.AddError("ticket_type", message)
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	paymentId, err := app.models.GroupBookings.ReserveShare(share, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	param := Params{
+		AppUser:       user.Email,
+		ItemPrice:     strconv.FormatInt(share.Amount, 10),
+		ReservationId: generateGroupShareTransId(paymentId),
+	}
+
+	response, err := CreaterOrder(param)
+	if err != nil {
+		if err := app.models.GroupBookings.ReleaseShare(paymentId); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"group_share": share, "payment": response}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+	go func(paymentId int64) {
+		time.Sleep(10 * time.Minute)
+
+		err := app.models.GroupBookings.ReleaseShare(paymentId)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}(paymentId)
+}
+
+// readGroupShare loads the share named by the invitation token in the URL.
+// It writes the error response itself and returns false if the request
+// should stop.
+func (app *application) readGroupShare(w http.ResponseWriter, r *http.Request) (*entity.GroupShare, bool) {
+	token := httprouter.ParamsFromContext(r.Context()).ByName("token")
+
+	v := validator.New()
+
+	if repository.ValidateShareToken(v, token); !v.Valid() {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+
+	share, err := app.models.GroupBookings.GetShareByToken(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return share, true
+}
+
+// completeGroupShare confirms a paid share, records who paid for it and
+// awards the member loyalty points for it.
+func (app *application) completeGroupShare(paymentId int64, paymentIdentity string) error {
+	reservationId, err := app.models.GroupBookings.CompleteShare(paymentId)
+	if err != nil || reservationId == 0 {
+		return err
+	}
+
+	if paymentIdentity != "" {
+		err = app.models.Reservation.SetPaymentIdentity(reservationId, paymentIdentity)
+		if err != nil {
+			return err
+		}
+	}
+
+	return app.awardLoyaltyPoints(reservationId)
+}
+
+// expireGroupBookings releases the seats of unpaid shares once their group
+// booking has expired, checking every minute until ctx is cancelled.
+func (app *application) expireGroupBookings(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := app.models.GroupBookings.ExpireBefore(time.Now())
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+
+			if expired > 0 {
+				app.logger.PrintInfo("group shares expired", map[string]string{"count": strconv.FormatInt(expired, 10)})
+			}
+		}
+	}
+}
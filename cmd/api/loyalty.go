@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// awardLoyaltyPoints credits the points a paid reservation earns at the
+// member's current tier. The tier takes the reservation's own spend into
+// account.
+func (app *application) awardLoyaltyPoints(reservationId int64) error {
+	reservation, err := app.models.Reservation.GetById(reservationId)
+	if err != nil {
+		return err
+	}
+
+	if reservation.Status != "success" || reservation.PointsEarned > 0 {
+		return nil
+	}
+
+	loyalty, err := app.loyaltyFor(reservation.UserId)
+	if err != nil {
+		return err
+	}
+
+	return app.models.Loyalty.Earn(reservation.ID, pricing.EarnedPoints(reservation.Amount, loyalty.Tier))
+}
+
+func (app *application) loyaltyFor(userId int64) (*entity.Loyalty, error) {
+	points, err := app.models.Loyalty.GetPoints(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	spend, err := app.models.Loyalty.AnnualSpend(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	tiers, err := app.models.Loyalty.GetTiers()
+	if err != nil {
+		return nil, err
+	}
+
+	tier, next := pricing.TierFor(tiers, spend)
+
+	loyalty := &entity.Loyalty{
+		Points:      points,
+		AnnualSpend: spend,
+		Tier:        tier,
+		NextTier:    next,
+	}
+	if next != nil {
+		loyalty.SpendToNextTier = next.MinSpend - spend
+	}
+
+	return loyalty, nil
+}
+
+func (app *application) showLoyaltyHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		repository.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "points", "-id", "-created_at", "-points"}
+
+	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	loyalty, err := app.loyaltyFor(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	history, metadata, err := app.models.Loyalty.GetTransactions(user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loyalty": loyalty, "history": history, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listLoyaltyTiersHandler(w http.ResponseWriter, r *http.Request) {
+	tiers, err := app.models.Loyalty.GetTiers()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loyalty_tiers": tiers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateLoyaltyTierHandler(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+
+	tier, err := app.models.Loyalty.GetTier(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name       *string `json:"name"`
+		MinSpend   *int64  `json:"min_spend"`
+		Multiplier *int32  `json:"multiplier"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		tier.Name = *input.Name
+	}
+	if input.MinSpend != nil {
+		tier.MinSpend = *input.MinSpend
+	}
+	if input.Multiplier != nil {
+		tier.Multiplier = *input.Multiplier
+	}
+
+	v := validator.New()
+
+	if repository.ValidateLoyaltyTier(v, tier); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Loyalty.UpdateTier(tier)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			v.AddError("min_spend", "another tier already starts at this spend")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"loyalty_tier": tier}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	data "greenlight.zuyanh.net/internal/repository"
@@ -15,6 +16,7 @@ import (
 	"greenlight.zuyanh.net/internal/jsonlog"
 	"greenlight.zuyanh.net/internal/mailer"
 	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/storage"
 )
 
 const version = "1.0.0"
@@ -43,6 +45,25 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	storage struct {
+		dir            string
+		maxUploadBytes int64
+	}
+	booking struct {
+		changeCutoff     time.Duration
+		resaleCutoff     time.Duration
+		resaleFeePercent int
+		groupHold        time.Duration
+	}
+	limits struct {
+		maxSeats         int
+		maxPendingHolds  int
+		velocityWindow   time.Duration
+		velocityMaxSeats int
+	}
+	pricing struct {
+		location *time.Location
+	}
 }
 
 type application struct {
@@ -50,6 +71,7 @@ type application struct {
 	logger       *jsonlog.Logger
 	models       repository.Models
 	mailer       mailer.Mailer
+	storage      storage.Storage
 	wg           sync.WaitGroup
 	transChannel chan int64
 }
@@ -78,6 +100,29 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", "ec3f1d449dd8cc", "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Greenlight <no-reply@greenlight.net>", "SMTP sender")
 
+	flag.StringVar(&cfg.storage.dir, "storage-dir", "./uploads", "Directory for uploaded media files")
+	flag.Int64Var(&cfg.storage.maxUploadBytes, "storage-max-upload-bytes", 5<<20, "Maximum size of an uploaded image")
+
+	flag.DurationVar(&cfg.booking.changeCutoff, "booking-change-cutoff", 2*time.Hour, "How long before the showtime reservations can no longer be changed")
+	flag.DurationVar(&cfg.booking.resaleCutoff, "booking-resale-cutoff", time.Hour, "How long before the showtime resale listings are withdrawn")
+	flag.IntVar(&cfg.booking.resaleFeePercent, "booking-resale-fee-percent", 10, "Percentage of a resale price kept as a fee")
+	flag.DurationVar(&cfg.booking.groupHold, "booking-group-hold", 24*time.Hour, "How long seats of a group booking are held for its members to pay")
+
+	flag.IntVar(&cfg.limits.maxSeats, "booking-max-seats", 10, "Default maximum number of seats one account may hold for a show")
+	flag.IntVar(&cfg.limits.maxPendingHolds, "booking-max-pending-holds", 2, "Default maximum number of unpaid reservations one account may hold for a show")
+	flag.DurationVar(&cfg.limits.velocityWindow, "booking-velocity-window", 15*time.Minute, "Window over which seats booked from one IP address are counted")
+	flag.IntVar(&cfg.limits.velocityMaxSeats, "booking-velocity-max-seats", 20, "Maximum number of seats booked for a show from one IP address within the velocity window")
+
+	cfg.pricing.location = time.Local
+	flag.Func("pricing-timezone", "Time zone of the cinemas, which pricing rule dates and times are read in (default local)", func(val string) error {
+		loc, err := time.LoadLocation(val)
+		if err != nil {
+			return err
+		}
+		cfg.pricing.location = loc
+		return nil
+	})
+
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
@@ -96,17 +141,23 @@ func main() {
 
 	logger.PrintInfo("database connection pool established", nil)
 
+	store, err := storage.NewLocal(cfg.storage.dir)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	app := &application{
 		config:       cfg,
 		logger:       logger,
 		models:       data.NewModel(db),
 		mailer:       mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		storage:      store,
 		transChannel: make(chan int64),
 	}
 
 	var wg sync.WaitGroup
 
-	wg.Add(1)
+	wg.Add(3)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -115,6 +166,16 @@ func main() {
 		app.listeningForTransaction(ctx)
 	}()
 
+	go func() {
+		defer wg.Done()
+		app.withdrawClosedResaleListings(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		app.expireGroupBookings(ctx)
+	}()
+
 	err = app.serve()
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -160,7 +221,22 @@ func (app *application) listeningForTransaction(ctx context.Context) {
 			fmt.Println("receive:", id)
 			err := app.models.Reservation.UpdateStatus(id, "success")
 			if err != nil {
-				app.logger.PrintFatal(err, nil)
+				if !errors.Is(err, repository.ErrEditConflict) {
+					app.logger.PrintFatal(err, nil)
+				}
+
+				// The reservation was already paid, or was cancelled before
+				// the payment arrived and is refunded.
+				err = app.models.Reservation.RefundCancelled(id)
+				if err != nil {
+					app.logger.PrintError(err, nil)
+				}
+				continue
+			}
+
+			err = app.awardLoyaltyPoints(id)
+			if err != nil {
+				app.logger.PrintError(err, nil)
 			}
 		}
 	}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.zuyanh.net/internal/imaging"
+	"greenlight.zuyanh.net/internal/storage"
+)
+
+const (
+	mediaURLPrefix  = "/media/"
+	thumbnailWidth  = 300
+	thumbnailHeight = 450
+)
+
+// readImageUpload reads the file in the given multipart form field, enforcing
+// the configured upload size limit.
+func (app *application) readImageUpload(w http.ResponseWriter, r *http.Request, field string) ([]byte, error) {
+	maxBytes := app.config.storage.maxUploadBytes
+	// Leave some headroom for the multipart boundaries and headers.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+64*1024)
+
+	err := r.ParseMultipartForm(maxBytes)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return nil, fmt.Errorf("%s must not be larger than %d bytes", field, maxBytes)
+		default:
+			return nil, errors.New("body must be a multipart form")
+		}
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("%s file must be provided", field)
+	}
+	defer file.Close()
+
+	if header.Size > maxBytes {
+		return nil, fmt.Errorf("%s must not be larger than %d bytes", field, maxBytes)
+	}
+
+	return io.ReadAll(file)
+}
+
+// storeImage saves the original upload and a resized thumbnail under prefix
+// and returns the public URLs of both. The thumbnail sits next to the
+// original with a "_thumb" suffix, see thumbnailKey.
+func (app *application) storeImage(prefix string, data []byte, img image.Image, format string) (string, string, error) {
+	name := make([]byte, 16)
+	_, err := rand.Read(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := fmt.Sprintf("%s/%s%s", prefix, hex.EncodeToString(name), imaging.Extension(format))
+
+	err = app.storage.Save(key, bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+
+	var thumb bytes.Buffer
+	err = imaging.Encode(&thumb, imaging.Thumbnail(img, thumbnailWidth, thumbnailHeight), format)
+	if err != nil {
+		app.storage.Delete(key)
+		return "", "", err
+	}
+
+	err = app.storage.Save(thumbnailKey(key), &thumb)
+	if err != nil {
+		app.storage.Delete(key)
+		return "", "", err
+	}
+
+	return mediaURLPrefix + key, mediaURLPrefix + thumbnailKey(key), nil
+}
+
+// removeImage deletes a stored image and its thumbnail. URLs that were not
+// issued by storeImage are ignored.
+func (app *application) removeImage(url string) {
+	if !strings.HasPrefix(url, mediaURLPrefix) {
+		return
+	}
+
+	key := strings.TrimPrefix(url, mediaURLPrefix)
+
+	for _, k := range []string{key, thumbnailKey(key)} {
+		err := app.storage.Delete(k)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"key": k})
+		}
+	}
+}
+
+func thumbnailKey(key string) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_thumb" + ext
+}
+
+// decodeImageResponse decodes an uploaded image, writing a validation error
+// response and returning ok == false if it is not acceptable.
+func (app *application) decodeImageResponse(w http.ResponseWriter, r *http.Request, data []byte) (img image.Image, format string, ok bool) {
+	img, format, err := imaging.Decode(data)
+	if err != nil {
+		switch {
+		case errors.Is(err, imaging.ErrUnsupportedFormat):
+			app.failedValidationResponse(w, r, map[string]string{"image": "must be a JPEG or PNG image"})
+		case errors.Is(err, imaging.ErrImageTooLarge):
+			app.failedValidationResponse(w, r, map[string]string{"image": "dimensions are too large"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, "", false
+	}
+
+	return img, format, true
+}
+
+func (app *application) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	key := strings.TrimPrefix(params.ByName("filepath"), "/")
+
+	file, err := app.storage.Open(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrInvalidKey):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	// Stored keys are random and never rewritten, so clients may cache them
+	// for as long as they like.
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -6,16 +6,19 @@ import (
 	"greenlight.zuyanh.net/internal/entity"
 	"greenlight.zuyanh.net/internal/repository"
 	"net/http"
+	"strings"
 
 	"greenlight.zuyanh.net/internal/validator"
 )
 
 func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string         `json:"title"`
-		Year    int32          `json:"year"`
-		Runtime entity.Runtime `json:"run_time"`
-		Genres  []string       `json:"genres"`
+		Title     string         `json:"title"`
+		Year      int32          `json:"year"`
+		Runtime   entity.Runtime `json:"run_time"`
+		Genres    []string       `json:"genres"`
+		AgeRating string         `json:"age_rating"`
+		Language  string         `json:"language"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -25,10 +28,16 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	movie := &entity.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:     input.Title,
+		Year:      input.Year,
+		Runtime:   input.Runtime,
+		Genres:    input.Genres,
+		AgeRating: input.AgeRating,
+		Language:  input.Language,
+	}
+
+	if movie.AgeRating == "" {
+		movie.AgeRating = entity.RatingGeneral
 	}
 
 	v := validator.New()
@@ -40,7 +49,13 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 
 	err = app.models.Movies.Insert(movie)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, repository.ErrUnknownGenre):
+			v.AddError("genres", "contains an unknown genre")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -97,10 +112,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	var input struct {
-		Title   *string         `json:"title"`
-		Year    *int32          `json:"year"`
-		Runtime *entity.Runtime `json:"run_time"`
-		Genres  []string        `json:"genres"`
+		Title     *string         `json:"title"`
+		Year      *int32          `json:"year"`
+		Runtime   *entity.Runtime `json:"run_time"`
+		Genres    []string        `json:"genres"`
+		AgeRating *string         `json:"age_rating"`
+		Language  *string         `json:"language"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -121,6 +138,12 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	if input.Genres != nil {
 		movie.Genres = input.Genres // Note that we don't need to dereference a slice.
 	}
+	if input.AgeRating != nil {
+		movie.AgeRating = *input.AgeRating
+	}
+	if input.Language != nil {
+		movie.Language = *input.Language
+	}
 
 	v := validator.New()
 	if repository.ValidateMovie(v, movie); !v.Valid() {
@@ -133,6 +156,9 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		switch {
 		case errors.Is(err, repository.ErrEditConflict):
 			app.editConflictResponse(w, r)
+		case errors.Is(err, repository.ErrUnknownGenre):
+			v.AddError("genres", "contains an unknown genre")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -157,13 +183,15 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		switch {
 		case errors.Is(err, repository.ErrRecordNotFound):
 			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully deleted"}, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "movie successfully archived"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -171,8 +199,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		repository.MovieSearch
 		repository.Filters
 	}
 
@@ -182,18 +209,32 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.PersonId = int64(app.readInt(qs, "person", 0, v))
+	input.YearFrom = app.readInt(qs, "year_from", 0, v)
+	input.YearTo = app.readInt(qs, "year_to", 0, v)
+	input.RuntimeMin = app.readInt(qs, "runtime_min", 0, v)
+	input.RuntimeMax = app.readInt(qs, "runtime_max", 0, v)
+	input.Language = app.readString(qs, "language", "")
+	input.AgeRatings = app.readCSV(qs, "age_rating", []string{})
+
+	// Searching by title ranks by relevance unless another order is asked for.
+	defaultSort := "id"
+	if input.Title != "" {
+		defaultSort = "-relevance"
+	}
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
-	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+	input.Filters.Sort = app.readString(qs, "sort", defaultSort)
+	input.Filters.SortSafelist = []string{"id", "title", "year", "runtime", "rating_average", "rating_count", "relevance", "-id", "-title", "-year", "-runtime", "-rating_average", "-rating_count", "-relevance"}
 
+	repository.ValidateMovieSearch(v, input.MovieSearch)
 	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, metadata, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, metadata, err := app.models.Movies.GetAll(input.MovieSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -204,3 +245,93 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	q := strings.TrimSpace(app.readString(qs, "q", ""))
+	limit := app.readInt(qs, "limit", 10, v)
+
+	v.Check(q != "", "q", "must be provided")
+	v.Check(len(q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Autocomplete(q, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) uploadMoviePosterHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data, err := app.readImageUpload(w, r, "image")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	img, format, ok := app.decodeImageResponse(w, r, data)
+	if !ok {
+		return
+	}
+
+	url, _, err := app.storeImage(fmt.Sprintf("movies/%d", movie.ID), data, img, format)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	oldImg := movie.Img
+	movie.Img = url
+
+	err = app.models.Movies.Update(movie)
+	if err != nil {
+		app.removeImage(url)
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(func() {
+		app.removeImage(oldImg)
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// quoteShow prices tickets for a show with the current pricing rules and the
+// show's ticket types. Tickets carry a seat and a ticket type. Without
+// tickets it quotes every category priced for the show as every ticket type
+// the show offers.
+func (app *application) quoteShow(show *entity.Show, tickets []*entity.SeatPrice) (*pricing.Quote, error) {
+	offered, err := app.models.TicketTypes.GetForShow(show.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticketTypes := make(map[string]*entity.TicketType, len(offered))
+	for _, ticketType := range offered {
+		ticketTypes[ticketType.Code] = ticketType
+	}
+
+	var seats []*entity.SeatPrice
+
+	if len(tickets) > 0 {
+		seatIds := make([]int64, len(tickets))
+		for i, ticket := range tickets {
+			if _, ok := ticketTypes[ticket.TicketType]; !ok {
+				return nil, repository.ErrTicketTypeNotOffered
+			}
+			seatIds[i] = ticket.SeatId
+		}
+
+		prices, err := app.models.Prices.GetForSeats(show.ID, seatIds)
+		if err != nil {
+			return nil, err
+		}
+
+		bySeat := make(map[int64]*entity.SeatPrice, len(prices))
+		for _, price := range prices {
+			bySeat[price.SeatId] = price
+		}
+
+		for _, ticket := range tickets {
+			price := bySeat[ticket.SeatId]
+			seats = append(seats, &entity.SeatPrice{
+				SeatId:     ticket.SeatId,
+				Category:   price.Category,
+				TicketType: ticket.TicketType,
+				Price:      price.Price,
+			})
+		}
+	} else {
+		prices, err := app.models.Prices.GetForShow(show.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, price := range prices {
+			for _, ticketType := range offered {
+				seats = append(seats, &entity.SeatPrice{Category: price.Category, TicketType: ticketType.Code, Price: price.Price})
+			}
+		}
+	}
+
+	format, err := app.models.Formats.Get(show.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := app.models.PricingRules.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	occupancy, err := app.models.Seat.Occupancy(show.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	c := pricing.Context{
+		Showtime:  show.Showtime,
+		Now:       time.Now(),
+		Occupancy: occupancy,
+		Location:  app.config.pricing.location,
+	}
+
+	return pricing.New(rules).Quote(seats, ticketTypes, format.Surcharge, c), nil
+}
+
+func (app *application) quoteShowHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	seatIds := app.readIDs(qs, "seat_ids", v)
+	ticketType := app.readString(qs, "ticket_type", entity.TicketAdult)
+	applyCode := app.readString(qs, "apply_code", "")
+
+	v.Check(len(seatIds) <= 20, "seat_ids", "must not contain more than 20 seats")
+	v.Check(uniqueIDs(seatIds), "seat_ids", "must not contain duplicate values")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	show, err := app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tickets := make([]*entity.SeatPrice, len(seatIds))
+	for i, seatId := range seatIds {
+		tickets[i] = &entity.SeatPrice{SeatId: seatId, TicketType: ticketType}
+	}
+
+	quote, err := app.quoteShow(show, tickets)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTicketTypeNotOffered):
+			v.AddError("ticket_type", "must be a ticket type offered for this show")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrRecordNotFound):
+			v.AddError("seat_ids", "must only contain seats of the show's screen")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrUnpricedSeat):
+			app.unpricedSeatResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"quote": quote}
+
+	if applyCode != "" {
+		_, discount, err := app.applyPromoCode(applyCode, show, quote.Total)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				v.AddError("apply_code", "is not a valid code")
+				app.failedValidationResponse(w, r, v.Errors)
+			case isPromoError(err):
+				v.AddError("apply_code", err.Error())
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		env["discount"] = discount
+		env["total"] = quote.Total - discount
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listPricingRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := app.models.PricingRules.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"pricing_rules": rules}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name       string                   `json:"name"`
+		Priority   int32                    `json:"priority"`
+		Conditions entity.PricingConditions `json:"conditions"`
+		Adjustment string                   `json:"adjustment"`
+		Amount     int64                    `json:"amount"`
+		Stop       bool                     `json:"stop"`
+		ValidFrom  string                   `json:"valid_from"`
+		ValidTo    string                   `json:"valid_to"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rule := &entity.PricingRule{
+		Name:       input.Name,
+		Priority:   input.Priority,
+		Conditions: input.Conditions,
+		Adjustment: input.Adjustment,
+		Amount:     input.Amount,
+		Stop:       input.Stop,
+		ValidFrom:  input.ValidFrom,
+		ValidTo:    input.ValidTo,
+	}
+
+	v := validator.New()
+
+	if repository.ValidatePricingRule(v, rule); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PricingRules.Insert(rule)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"pricing_rule": rule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	rule, err := app.models.PricingRules.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"pricing_rule": rule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updatePricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	rule, err := app.models.PricingRules.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name       *string                   `json:"name"`
+		Priority   *int32                    `json:"priority"`
+		Conditions *entity.PricingConditions `json:"conditions"`
+		Adjustment *string                   `json:"adjustment"`
+		Amount     *int64                    `json:"amount"`
+		Stop       *bool                     `json:"stop"`
+		ValidFrom  *string                   `json:"valid_from"`
+		ValidTo    *string                   `json:"valid_to"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		rule.Name = *input.Name
+	}
+	if input.Priority != nil {
+		rule.Priority = *input.Priority
+	}
+	if input.Conditions != nil {
+		rule.Conditions = *input.Conditions
+	}
+	if input.Adjustment != nil {
+		rule.Adjustment = *input.Adjustment
+	}
+	if input.Amount != nil {
+		rule.Amount = *input.Amount
+	}
+	if input.Stop != nil {
+		rule.Stop = *input.Stop
+	}
+	if input.ValidFrom != nil {
+		rule.ValidFrom = *input.ValidFrom
+	}
+	if input.ValidTo != nil {
+		rule.ValidTo = *input.ValidTo
+	}
+
+	v := validator.New()
+
+	if repository.ValidatePricingRule(v, rule); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PricingRules.Update(rule)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"pricing_rule": rule}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.PricingRules.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "pricing rule successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
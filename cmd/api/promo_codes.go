@@ -0,0 +1,297 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// applyPromoCode looks code up and works out its discount on a booking of
+// show worth subtotal. Usage limits are only checked when the code is
+// redeemed with the reservation.
+func (app *application) applyPromoCode(code string, show *entity.Show, subtotal int64) (*entity.PromoCode, int64, error) {
+	promo, err := app.models.PromoCodes.GetByCode(code)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	screen, err := app.models.Screen.Get(show.ScreenId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	order := pricing.Order{
+		MovieId:   show.MovieId,
+		TheatreId: screen.Theatre_id,
+		Showtime:  show.Showtime,
+		Now:       time.Now(),
+		Subtotal:  subtotal,
+		Location:  app.config.pricing.location,
+	}
+
+	discount, err := pricing.Discount(promo, order)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return promo, discount, nil
+}
+
+// isPromoError reports whether err explains why a promo code does not apply
+// to a booking, as opposed to a failure looking it up.
+func isPromoError(err error) bool {
+	var minSpend *pricing.MinSpendError
+
+	return errors.Is(err, pricing.ErrPromoInactive) ||
+		errors.Is(err, pricing.ErrPromoNotStarted) ||
+		errors.Is(err, pricing.ErrPromoExpired) ||
+		errors.Is(err, pricing.ErrPromoNotApplicable) ||
+		errors.As(err, &minSpend)
+}
+
+func (app *application) listPromoCodesHandler(w http.ResponseWriter, r *http.Request) {
+	promos, err := app.models.PromoCodes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"promo_codes": promos}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Code          string   `json:"code"`
+		Description   string   `json:"description"`
+		DiscountType  string   `json:"discount_type"`
+		DiscountValue int64    `json:"discount_value"`
+		MinSpend      int64    `json:"min_spend"`
+		MaxDiscount   *int64   `json:"max_discount"`
+		ValidFrom     string   `json:"valid_from"`
+		ValidTo       string   `json:"valid_to"`
+		MovieIds      []int64  `json:"movie_ids"`
+		TheatreIds    []int64  `json:"theatre_ids"`
+		Weekdays      []string `json:"weekdays"`
+		UsageLimit    *int32   `json:"usage_limit"`
+		PerUserLimit  *int32   `json:"per_user_limit"`
+		Active        *bool    `json:"active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	promo := &entity.PromoCode{
+		Code:          input.Code,
+		Description:   input.Description,
+		DiscountType:  input.DiscountType,
+		DiscountValue: input.DiscountValue,
+		MinSpend:      input.MinSpend,
+		MaxDiscount:   input.MaxDiscount,
+		ValidFrom:     input.ValidFrom,
+		ValidTo:       input.ValidTo,
+		MovieIds:      input.MovieIds,
+		TheatreIds:    input.TheatreIds,
+		Weekdays:      input.Weekdays,
+		UsageLimit:    input.UsageLimit,
+		PerUserLimit:  input.PerUserLimit,
+		Active:        true,
+	}
+
+	if input.Active != nil {
+		promo.Active = *input.Active
+	}
+
+	v := validator.New()
+
+	if repository.ValidatePromoCode(v, promo); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PromoCodes.Insert(promo)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			v.AddError("code", "a promo code with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"promo_code": promo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	promo, err := app.models.PromoCodes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"promo_code": promo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updatePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	promo, err := app.models.PromoCodes.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Code          *string  `json:"code"`
+		Description   *string  `json:"description"`
+		DiscountType  *string  `json:"discount_type"`
+		DiscountValue *int64   `json:"discount_value"`
+		MinSpend      *int64   `json:"min_spend"`
+		MaxDiscount   *int64   `json:"max_discount"`
+		ValidFrom     *string  `json:"valid_from"`
+		ValidTo       *string  `json:"valid_to"`
+		MovieIds      []int64  `json:"movie_ids"`
+		TheatreIds    []int64  `json:"theatre_ids"`
+		Weekdays      []string `json:"weekdays"`
+		UsageLimit    *int32   `json:"usage_limit"`
+		PerUserLimit  *int32   `json:"per_user_limit"`
+		Active        *bool    `json:"active"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Code != nil {
+		promo.Code = *input.Code
+	}
+	if input.Description != nil {
+		promo.Description = *input.Description
+	}
+	if input.DiscountType != nil {
+		promo.DiscountType = *input.DiscountType
+	}
+	if input.DiscountValue != nil {
+		promo.DiscountValue = *input.DiscountValue
+	}
+	if input.MinSpend != nil {
+		promo.MinSpend = *input.MinSpend
+	}
+	if input.MaxDiscount != nil {
+		promo.MaxDiscount = input.MaxDiscount
+	}
+	if input.ValidFrom != nil {
+		promo.ValidFrom = *input.ValidFrom
+	}
+	if input.ValidTo != nil {
+		promo.ValidTo = *input.ValidTo
+	}
+	if input.MovieIds != nil {
+		promo.MovieIds = input.MovieIds
+	}
+	if input.TheatreIds != nil {
+		promo.TheatreIds = input.TheatreIds
+	}
+	if input.Weekdays != nil {
+		promo.Weekdays = input.Weekdays
+	}
+	if input.UsageLimit != nil {
+		promo.UsageLimit = input.UsageLimit
+	}
+	if input.PerUserLimit != nil {
+		promo.PerUserLimit = input.PerUserLimit
+	}
+	if input.Active != nil {
+		promo.Active = *input.Active
+	}
+
+	v := validator.New()
+
+	if repository.ValidatePromoCode(v, promo); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.PromoCodes.Update(promo)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			v.AddError("code", "a promo code with this code already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"promo_code": promo}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deletePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.PromoCodes.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "promo code successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
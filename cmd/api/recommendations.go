@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	v := validator.New()
+
+	limit := app.readInt(r.URL.Query(), "limit", 10, v)
+
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	recommendations, err := app.models.Recommendations.GetForUser(user.ID, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recommendations": recommendations}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// resaleTransPrefix marks ZaloPay transactions paying for a resale listing.
+const resaleTransPrefix = "rs"
+
+// generateResaleTransId returns the ZaloPay transaction id of a purchase, so
+// that every attempt to buy a listing is paid for separately.
+func generateResaleTransId(purchaseId int64) string {
+	now := time.Now()
+	return fmt.Sprintf("%02d%02d%02d_%s%v", now.Year()%100, int(now.Month()), now.Day(), resaleTransPrefix, purchaseId)
+}
+
+// createResaleListingHandler lists a seat of one of the user's paid
+// reservations for resale. The price defaults to, and may not be more than,
+// what was actually paid for the seat once the promo and points discounts of
+// the reservation it was bought on are shared out between its tickets.
+func (app *application) createResaleListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	reservation, err := app.models.Reservation.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if reservation.UserId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		SeatId int64  `json:"seat_id"`
+		Price  *int64 `json:"price"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var ticket *entity.Ticket
+	for _, t := range reservation.Tickets {
+		if t.SeatId == input.SeatId {
+			ticket = t
+		}
+	}
+
+	v := validator.New()
+
+	v.Check(reservation.Status == "success", "status", "reservation has not been paid")
+	v.Check(reservation.CheckedInAt == nil, "checked_in_at", "reservation has already been checked in")
+	v.Check(ticket != nil, "seat_id", "must be a seat held by the reservation")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	faceValue := ticket.PaidPrice
+
+	if v.Check(faceValue > 0, "seat_id", "was not paid for and cannot be resold"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	listing := &entity.ResaleListing{
+		ReservationId: reservation.ID,
+		SellerId:      user.ID,
+		SeatId:        ticket.SeatId,
+		Row:           ticket.Row,
+		Number:        ticket.Number,
+		Category:      ticket.Category,
+		TicketType:    ticket.TicketType,
+		FaceValue:     faceValue,
+		Price:         faceValue,
+	}
+
+	if input.Price != nil {
+		listing.Price = *input.Price
+	}
+
+	if repository.ValidateResaleListing(v, listing); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	show, err := app.models.Show.Get(reservation.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if time.Until(show.Showtime) < app.config.booking.resaleCutoff {
+		app.resaleClosedResponse(w, r)
+		return
+	}
+
+	err = app.models.Resale.Insert(listing)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			v.AddError("seat_id", "is already listed for resale")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrTransferPending):
+			v.AddError("seat_id", "is being transferred")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"resale_listing": listing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listShowResaleHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	listings, err := app.models.Resale.GetAllForShow(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"resale_listings": listings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMyResaleHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	listings, err := app.models.Resale.GetAllForSeller(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"resale_listings": listings}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) withdrawResaleListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	listing, err := app.models.Resale.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if listing.SellerId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Resale.Withdraw(listing)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"resale_listing": listing}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purchaseResaleListingHandler holds a listing for the user and starts a
+// ZaloPay payment for it. The seat is handed over once the payment is
+// confirmed, and the listing goes back on sale if it is not paid in time.
+// Buyers must be allowed to see the movie and to use the ticket type, as if
+// they had booked the seat themselves.
+func (app *application) purchaseResaleListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	listing, err := app.models.Resale.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	show, err := app.models.Show.Get(listing.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if time.Until(show.Showtime) < app.config.booking.resaleCutoff {
+		app.resaleClosedResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(show.MovieId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if message := ageRestrictionError(user, movie, show.Showtime); message != "" {
+		app.ageRestrictedResponse(w, r, message)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(listing.SellerId != user.ID, "seller_id", "you cannot buy your own listing")
+	v.Check(listing.Status == entity.ResaleListed, "status", "listing is not on sale")
+
+	if message := ticketTypeError(user, movie, listing.TicketType); message != "" {
+		v.AddError("ticket_type", message)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	fee := pricing.ResaleFee(listing.Price, app.config.booking.resaleFeePercent)
+
+	purchaseId, err := app.models.Resale.Reserve(listing, user.ID, fee)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	param := Params{
+		AppUser:       user.Email,
+		ItemPrice:     strconv.FormatInt(listing.Price, 10),
+		ReservationId: generateResaleTransId(purchaseId),
+	}
+
+	response, err := CreaterOrder(param)
+	if err != nil {
+		if err := app.models.Resale.Release(purchaseId); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"resale_listing": listing, "payment": response}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+	go func(purchaseId int64) {
+		time.Sleep(10 * time.Minute)
+
+		err := app.models.Resale.Release(purchaseId)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}(purchaseId)
+}
+
+// withdrawClosedResaleListings takes listings off sale once their show is
+// within the resale cutoff, checking every minute until ctx is cancelled.
+func (app *application) withdrawClosedResaleListings(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			withdrawn, err := app.models.Resale.WithdrawBefore(app.config.booking.resaleCutoff)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+
+			if withdrawn > 0 {
+				app.logger.PrintInfo("resale listings withdrawn", map[string]string{"count": strconv.FormatInt(withdrawn, 10)})
+			}
+		}
+	}
+}
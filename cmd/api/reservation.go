@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
 	"greenlight.zuyanh.net/internal/repository"
 	"greenlight.zuyanh.net/internal/validator"
 	"net/http"
@@ -21,10 +23,27 @@ func (app *application) createReservationHandler(w http.ResponseWriter, r *http.
 	}
 	defer tx.Commit()
 
+	// Tickets pick a ticket type per seat. Seats listed in seat_ids alone are
+	// sold as adult tickets. ApplyCode is an optional promo code,
+	// RedeemPoints and FreeTickets spend loyalty points and WalletAmount is
+	// the part of the total paid from the user's wallet. Concessions are
+	// food and drink items bought with the tickets.
 	var input struct {
 		UserId  int64   `json:"user_id"`
 		ShowId  int64   `json:"show_id"`
 		SeatIds []int64 `json:"seat_ids"`
+		Tickets []struct {
+			SeatId     int64  `json:"seat_id"`
+			TicketType string `json:"ticket_type"`
+		} `json:"tickets"`
+		ApplyCode    string `json:"apply_code"`
+		RedeemPoints int64  `json:"redeem_points"`
+		FreeTickets  int    `json:"free_tickets"`
+		WalletAmount int64  `json:"wallet_amount"`
+		Concessions  []struct {
+			ConcessionId int64 `json:"concession_id"`
+			Quantity     int32 `json:"quantity"`
+		} `json:"concessions"`
 	}
 
 	err = app.readJSON(w, r, &input)
@@ -34,49 +53,328 @@ func (app *application) createReservationHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	user, err := app.models.Users.GetById(input.UserId)
+	tickets := []*entity.SeatPrice{}
+	if len(input.Tickets) > 0 {
+		input.SeatIds = nil
+		for _, ticket := range input.Tickets {
+			tickets = append(tickets, &entity.SeatPrice{SeatId: ticket.SeatId, TicketType: ticket.TicketType})
+			input.SeatIds = append(input.SeatIds, ticket.SeatId)
+		}
+	} else {
+		for _, seatId := range input.SeatIds {
+			tickets = append(tickets, &entity.SeatPrice{SeatId: seatId, TicketType: entity.TicketAdult})
+		}
+	}
+
+	// Ticket type eligibility is that of the user making the booking.
+	user := app.contextGetUser(r)
+
+	show, err := app.models.Show.Get(input.ShowId)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	total, err := app.models.Seat.UpdateSeatStatus(false, input.ShowId, input.SeatIds)
+	movie, err := app.models.Movies.Get(show.MovieId)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	// Age ratings apply to whoever is making the booking, not the account
+	// named in the request.
+	if message := ageRestrictionError(user, movie, show.Showtime); message != "" {
+		app.ageRestrictedResponse(w, r, message)
+		return
+	}
+
+	v := validator.New()
+
+	// Bookings are always made for the authenticated user, so that per-user
+	// limits such as promo code usage cannot be dodged by naming another
+	// account.
+	v.Check(input.UserId == user.ID, "user_id", "must be your own account")
+	v.Check(len(tickets) > 0, "tickets", "must contain at least 1 seat")
+	v.Check(uniqueIDs(input.SeatIds), "tickets", "must not contain the same seat twice")
+
+	items := make([]*entity.ConcessionItem, len(input.Concessions))
+	concessionIds := make([]int64, len(input.Concessions))
+	for i, concession := range input.Concessions {
+		v.Check(concession.Quantity > 0 && concession.Quantity <= 20, "concessions", "must have a quantity between 1 and 20")
+		items[i] = &entity.ConcessionItem{ConcessionId: concession.ConcessionId, Quantity: concession.Quantity}
+		concessionIds[i] = concession.ConcessionId
+	}
+	v.Check(len(items) <= 20, "concessions", "must not contain more than 20 items")
+	v.Check(uniqueIDs(concessionIds), "concessions", "must not contain the same item twice")
+	for _, ticket := range tickets {
+		if message := ticketTypeError(user, movie, ticket.TicketType); message != "" {
+			v.AddError("tickets", message)
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.enforceBookingLimits(w, r, tx, user.ID, show.ID, len(tickets), 1) {
+		return
+	}
+
+	quote, err := app.quoteShow(show, tickets)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTicketTypeNotOffered):
+			v.AddError("tickets", "must only use ticket types offered for this show")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrRecordNotFound):
+			v.AddError("tickets", "must only contain seats of the show's screen")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrUnpricedSeat):
+			app.unpricedSeatResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var promo *entity.PromoCode
+	var discount int64
+
+	if input.ApplyCode != "" {
+		promo, discount, err = app.applyPromoCode(input.ApplyCode, show, quote.Total)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				v.AddError("apply_code", "is not a valid code")
+				app.failedValidationResponse(w, r, v.Errors)
+			case isPromoError(err):
+				v.AddError("apply_code", err.Error())
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	total := quote.Total - discount
+
+	var pointsDiscount, pointsRedeemed int64
+
+	if input.RedeemPoints != 0 || input.FreeTickets != 0 {
+		v.Check(input.RedeemPoints >= 0, "redeem_points", "must not be negative")
+		v.Check(input.FreeTickets >= 0, "free_tickets", "must not be negative")
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		pointsDiscount, pointsRedeemed, err = pricing.Redemption(quote.Lines, total, input.RedeemPoints, input.FreeTickets)
+		if err != nil {
+			switch {
+			case errors.Is(err, pricing.ErrTooManyFreeTickets):
+				v.AddError("free_tickets", "must not be more than the number of tickets")
+			case errors.Is(err, pricing.ErrPointsExceedTotal):
+				v.AddError("redeem_points", "must not be worth more than the amount left to pay")
+			}
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		total -= pointsDiscount
+	}
+
+	var concessionsAmount int64
+
+	if len(items) > 0 {
+		concessionsAmount, err = app.priceConcessions(show, items)
+		if err != nil {
+			switch {
+			case errors.Is(err, repository.ErrRecordNotFound):
+				v.AddError("concessions", "must only contain items on sale at the show's theatre")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		total += concessionsAmount
+	}
+
+	if input.WalletAmount != 0 {
+		v.Check(input.WalletAmount > 0, "wallet_amount", "must not be negative")
+		v.Check(input.WalletAmount <= total, "wallet_amount", "must not be more than the total")
+
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+	}
+
+	err = app.models.Seat.UpdateSeatStatus(false, input.ShowId, input.SeatIds)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSeatUnavailable):
+			v.AddError("tickets", "must only contain available seats")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// releaseSeats undoes the booking when it fails after the seats were
+	// taken.
+	releaseSeats := func() {
+		tx.Rollback()
+
+		if err := app.models.Seat.UpdateSeatStatus(true, input.ShowId, input.SeatIds); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}
+
+	seats := make([]*entity.SeatPrice, len(quote.Lines))
+	for i, line := range quote.Lines {
+		seats[i] = &entity.SeatPrice{SeatId: line.SeatId, Category: line.Category, TicketType: line.TicketType, Price: line.Price}
+	}
+
 	reservation := &entity.Reservation{
-		UserId: input.UserId,
-		ShowId: input.ShowId,
-		Amount: total,
+		UserId:            user.ID,
+		ShowId:            input.ShowId,
+		Amount:            total,
+		Discount:          discount,
+		WalletAmount:      input.WalletAmount,
+		PointsRedeemed:    pointsRedeemed,
+		PointsDiscount:    pointsDiscount,
+		ConcessionsAmount: concessionsAmount,
+		ClientIP:          clientIP(r),
 	}
 
-	err = app.models.Reservation.Insert(tx, reservation, input.SeatIds)
+	err = app.models.Reservation.Insert(tx, reservation, seats)
 	if err != nil {
+		releaseSeats()
 		app.serverErrorResponse(w, r, err)
-		tx.Rollback()
+		return
+	}
+
+	if promo != nil {
+		err = app.models.PromoCodes.Redeem(tx, promo, reservation)
+		if err != nil {
+			releaseSeats()
+
+			switch {
+			case errors.Is(err, repository.ErrPromoUsageLimit):
+				v.AddError("apply_code", "has reached its usage limit")
+				app.failedValidationResponse(w, r, v.Errors)
+			case errors.Is(err, repository.ErrPromoUserLimit):
+				v.AddError("apply_code", "has already been used the maximum number of times on your account")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	if len(items) > 0 {
+		err = app.models.Concessions.Reserve(tx, reservation, items)
+		if err != nil {
+			releaseSeats()
+
+			switch {
+			case errors.Is(err, repository.ErrOutOfStock):
+				v.AddError("concessions", "must only contain items in stock")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	if reservation.PointsRedeemed > 0 {
+		err = app.models.Loyalty.Redeem(tx, reservation)
+		if err != nil {
+			releaseSeats()
+
+			switch {
+			case errors.Is(err, repository.ErrInsufficientPoints):
+				v.AddError("redeem_points", "must not be more than your points balance")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	if reservation.WalletAmount > 0 {
+		reference := "reservation:" + strconv.FormatInt(reservation.ID, 10)
+
+		err = app.models.Wallets.Debit(tx, reservation.UserId, reservation.WalletAmount, entity.WalletPayment, reference)
+		if err != nil {
+			releaseSeats()
+
+			switch {
+			case errors.Is(err, repository.ErrInsufficientFunds):
+				v.AddError("wallet_amount", "must not be more than your wallet balance")
+				app.failedValidationResponse(w, r, v.Errors)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	// Nothing is left to pay through ZaloPay, so the reservation is already
+	// paid and never expires.
+	if reservation.Status == "success" {
+		err = tx.Commit()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			if err := app.awardLoyaltyPoints(reservation.ID); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		})
+
+		err = app.writeJSON(w, http.StatusCreated, envelope{"reservation": reservation}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
 	param := Params{
 		AppUser:       user.Email,
-		ItemPrice:     strconv.FormatInt(total, 10),
+		ItemPrice:     strconv.FormatInt(total-reservation.WalletAmount, 10),
 		ReservationId: generateTransId(reservation.ID),
 	}
 
 	response, err := CreaterOrder(param)
 	fmt.Println(response)
 	if err != nil {
+		releaseSeats()
 		app.serverErrorResponse(w, r, err)
-		tx.Rollback()
 		return
 	}
+
+	// The order exists from here on, so the reservation is kept even if the
+	// response cannot be written and expires like any unpaid one.
 	err = app.writeJSON(w, http.StatusOK, envelope{"payment": response}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
-		tx.Rollback()
-		return
 	}
 
 	go func(seatIds []int64) {
@@ -84,16 +382,23 @@ func (app *application) createReservationHandler(w http.ResponseWriter, r *http.
 
 		res, err := app.models.Reservation.GetById(reservation.ID)
 		if err != nil {
-
+			// The hold may already have been cancelled by an admin.
+			if !errors.Is(err, repository.ErrRecordNotFound) {
+				app.logger.PrintError(err, nil)
+			}
+			return
 		}
 		if res.Status == "pending" {
-			go func() {
-				_, err = app.models.Seat.UpdateSeatStatus(true, reservation.ShowId, seatIds)
-				if err != nil {
+			err = app.models.Reservation.Delete(res.ID)
+			if err != nil {
+				// It may have been paid or cancelled in the meantime.
+				if !errors.Is(err, repository.ErrEditConflict) && !errors.Is(err, repository.ErrRecordNotFound) {
 					app.logger.PrintError(err, nil)
 				}
-			}()
-			err = app.models.Reservation.Delete(res.ID)
+				return
+			}
+
+			err = app.models.Seat.UpdateSeatStatus(true, reservation.ShowId, seatIds)
 			if err != nil {
 				app.logger.PrintError(err, nil)
 			}
@@ -142,6 +447,168 @@ func (app *application) listReservationHandler(w http.ResponseWriter, r *http.Re
 	}
 }
 
+func (app *application) checkInReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	reservation, err := app.models.Reservation.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.checkIn(w, r, reservation)
+}
+
+// checkInTicketHandler checks in the reservation a ticket token was issued
+// for, as scanned at the door.
+func (app *application) checkInTicketHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TicketToken string `json:"ticket_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if repository.ValidateTicketToken(v, input.TicketToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reservation, err := app.models.Reservation.GetByTicketToken(input.TicketToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.checkIn(w, r, reservation)
+}
+
+func (app *application) checkIn(w http.ResponseWriter, r *http.Request, reservation *entity.Reservation) {
+	v := validator.New()
+	v.Check(reservation.Status == "success", "status", "reservation has not been paid")
+	v.Check(reservation.CheckedInAt == nil, "checked_in_at", "reservation has already been checked in")
+	v.Check(len(reservation.Tickets) > 0, "tickets", "all seats of the reservation have been transferred")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	show, err := app.models.Show.Get(reservation.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(show.MovieId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Reservation.CheckIn(reservation)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Staff must ask for ID on restricted films even though the date of birth
+	// was verified at booking time, since tickets can be handed to others.
+	// Child, student and senior tickets likewise need proof of eligibility.
+	concession := false
+	for _, ticket := range reservation.Tickets {
+		if ticket.TicketType != entity.TicketAdult {
+			concession = true
+		}
+	}
+
+	env := envelope{
+		"reservation":               reservation,
+		"age_rating":                movie.AgeRating,
+		"id_check_required":         movie.Restricted(),
+		"concession_check_required": concession,
+	}
+	if movie.Restricted() {
+		env["minimum_age"] = movie.MinimumAge()
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// ageRestrictionError returns a message explaining why user may not book a
+// showing of movie at showtime, or an empty string if they may.
+func ageRestrictionError(user *entity.User, movie *entity.Movie, showtime time.Time) string {
+	if !movie.Restricted() {
+		return ""
+	}
+
+	if user.DateOfBirth == nil || !user.DobVerified {
+		return fmt.Sprintf("this movie is rated %s and requires a verified date of birth to book", movie.AgeRating)
+	}
+
+	if user.AgeAt(showtime) < movie.MinimumAge() {
+		return fmt.Sprintf("you must be at least %d years old to book this movie", movie.MinimumAge())
+	}
+
+	return ""
+}
+
+// ticketTypeError returns a message explaining why user may not buy a ticket
+// of ticketType for movie, or an empty string if they may.
+func ticketTypeError(user *entity.User, movie *entity.Movie, ticketType string) string {
+	switch ticketType {
+	case entity.TicketChild:
+		if movie.Restricted() {
+			return fmt.Sprintf("child tickets are not sold for %s movies", movie.AgeRating)
+		}
+	case entity.TicketStudent:
+		if !user.StudentVerified {
+			return "student tickets require a verified student status"
+		}
+	}
+
+	return ""
+}
+
+func uniqueIDs(ids []int64) bool {
+	seen := make(map[int64]bool, len(ids))
+
+	for _, id := range ids {
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+
+	return true
+}
+
 func generateTransId(id int64) string {
 	now := time.Now()
 	return fmt.Sprintf("%02d%02d%02d_%v", now.Year()%100, int(now.Month()), now.Day(), id)
@@ -15,34 +15,164 @@ func (app *application) routes() http.Handler {
 
 	//guest base
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.showMovieHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.withStaticSegments(app.showMovieHandler, map[string]http.HandlerFunc{
+		"autocomplete": app.autocompleteMoviesHandler,
+		"export":       app.requirePermission("admin", app.exportMoviesHandler),
+	}))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.listCreditsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.listMovieReviewsHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/search", app.searchHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.listGenresHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/formats", app.listFormatsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/price-categories", app.listPriceCategoriesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/ticket-types", app.listTicketTypesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/loyalty-tiers", app.listLoyaltyTiersHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.listPeopleHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/theatres", app.listTheatreHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/theatres/:id", app.withStaticSegments(app.showTheatreHandler, map[string]http.HandlerFunc{
+		"nearby": app.nearbyTheatresHandler,
+	}))
+	router.HandlerFunc(http.MethodGet, "/v1/theatres/:id/images", app.listTheatreImagesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/theatres/:id/concessions", app.listConcessionsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/concessions/:id", app.showConcessionHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/screens", app.listScreensHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/screens/:id", app.showScreenHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/screens/:id/prices", app.showScreenPricesHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/shows", app.listShowHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id", app.showShowHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id/prices", app.showShowPricesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id/quote", app.quoteShowHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id/ticket-types", app.showShowTicketTypesHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id/resale", app.listShowResaleHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/seats", app.listAvailableSeatsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/seats/:id", app.showSeatHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
 	router.HandlerFunc(http.MethodPost, "/callback", app.zaloPayCallBackHandler)
 
+	router.HandlerFunc(http.MethodGet, "/media/*filepath", app.serveMediaHandler)
+
 	//user base
 	router.HandlerFunc(http.MethodPost, "/v1/payment", app.requirePermission("user", app.createReservationHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/reservations", app.requirePermission("user", app.listReservationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/reservations/:id/change-seats", app.requirePermission("user", app.changeSeatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/reservations/:id/transfers", app.requirePermission("user", app.listTicketTransfersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/reservations/:id/transfers", app.requirePermission("user", app.createTicketTransferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/group-bookings", app.requirePermission("user", app.createGroupBookingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/group-bookings/:id", app.requirePermission("user", app.showGroupBookingHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/group-shares/:token", app.requirePermission("user", app.showGroupShareHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/group-shares/:token/pay", app.requirePermission("user", app.payGroupShareHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/reservations/:id/resale", app.requirePermission("user", app.createResaleListingHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/resale/:id/purchase", app.requirePermission("user", app.purchaseResaleListingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/resale/:id", app.requirePermission("user", app.withdrawResaleListingHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/transfers/:id/accept", app.requirePermission("user", app.acceptTicketTransferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/transfers/:id/decline", app.requirePermission("user", app.declineTicketTransferHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/transfers/:id/cancel", app.requirePermission("user", app.cancelTicketTransferHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me", app.requirePermission("user", app.updateCurrentUserHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/recommendations", app.requirePermission("user", app.listRecommendationsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/resale", app.requirePermission("user", app.listMyResaleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/transfers", app.requirePermission("user", app.listIncomingTransfersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/loyalty", app.requirePermission("user", app.showLoyaltyHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/wallet", app.requirePermission("user", app.showWalletHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/wallet/transactions", app.requirePermission("user", app.listWalletTransactionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/gift-cards/purchase", app.requirePermission("user", app.purchaseGiftCardHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/gift-cards/redeem", app.requirePermission("user", app.redeemGiftCardHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requirePermission("user", app.createReviewHandler))
 
 	//admin base
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/verify-dob", app.requirePermission("admin", app.verifyUserDobHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/:id/verify-student", app.requirePermission("admin", app.verifyUserStudentHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/reservations/:id/check-in", app.requirePermission("admin", app.checkInReservationHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/tickets/check-in", app.requirePermission("admin", app.checkInTicketHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/reviews", app.requirePermission("admin", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/reviews/:id/moderation", app.requirePermission("admin", app.moderateReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/reviews/:id/moderation", app.requirePermission("admin", app.listReviewModerationsHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/theatres", app.requirePermission("admin", app.createTheatreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/theatres/:id", app.requirePermission("admin", app.updateTheatreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/theatres/:id", app.requirePermission("admin", app.deleteTheatreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/theatres/:id/images", app.requirePermission("admin", app.uploadTheatreImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/theatres/:id/images/:image_id", app.requirePermission("admin", app.deleteTheatreImageHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/theatres/:id/concessions", app.requirePermission("admin", app.createConcessionHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/concessions/:id", app.requirePermission("admin", app.updateConcessionHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/concessions/:id", app.requirePermission("admin", app.deleteConcessionHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/screens", app.requirePermission("admin", app.createScreenHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/screens/:id", app.requirePermission("admin", app.updateScreenHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/screens/:id", app.requirePermission("admin", app.deleteScreenHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/screens/:id/prices", app.requirePermission("admin", app.updateScreenPricesHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/shows", app.requirePermission("admin", app.createShowHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/shows/:id", app.requirePermission("admin", app.updateShowHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/shows/:id", app.requirePermission("admin", app.deleteShowHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/shows/:id/prices", app.requirePermission("admin", app.updateShowPricesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/shows/:id/ticket-types", app.requirePermission("admin", app.updateShowTicketTypesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/shows/:id/booking-limits", app.requirePermission("admin", app.showBookingLimitsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/shows/:id/booking-limits", app.requirePermission("admin", app.updateBookingLimitsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/shows/:id/booking-limits", app.requirePermission("admin", app.deleteBookingLimitsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/flagged-accounts", app.requirePermission("admin", app.listFlaggedAccountsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/flagged-accounts/:id/cancel-holds", app.requirePermission("admin", app.cancelFlaggedHoldsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/flagged-accounts/:id/resolve", app.requirePermission("admin", app.resolveFlaggedAccountHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/seats", app.requirePermission("admin", app.createSeatHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/seats/:id", app.requirePermission("admin", app.updateSeatHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/seats/:id", app.requirePermission("admin", app.deleteSeatHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("admin", app.createMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id", app.requirePermission("admin", app.withStaticSegments(app.notFoundErrorResponse, map[string]http.HandlerFunc{
+		"import": app.importMoviesHandler,
+	})))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("admin", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("admin", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/poster", app.requirePermission("admin", app.uploadMoviePosterHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("admin", app.createCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/credits/:id", app.requirePermission("admin", app.deleteCreditHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("admin", app.createPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("admin", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("admin", app.deletePersonHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/archive/:type", app.requirePermission("admin", app.listArchiveHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/archive/:type/:id/restore", app.requirePermission("admin", app.restoreArchivedHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/genres", app.requirePermission("admin", app.createGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/genres/:id", app.requirePermission("admin", app.updateGenreHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/genres/:id", app.requirePermission("admin", app.deleteGenreHandler))
+
+	router.HandlerFunc(http.MethodPatch, "/v1/formats/:code", app.requirePermission("admin", app.updateFormatHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/price-categories", app.requirePermission("admin", app.createPriceCategoryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/price-categories/:code", app.requirePermission("admin", app.updatePriceCategoryHandler))
+
+	router.HandlerFunc(http.MethodPatch, "/v1/ticket-types/:code", app.requirePermission("admin", app.updateTicketTypeHandler))
+
+	router.HandlerFunc(http.MethodPatch, "/v1/loyalty-tiers/:code", app.requirePermission("admin", app.updateLoyaltyTierHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/pricing-rules", app.requirePermission("admin", app.listPricingRulesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/pricing-rules", app.requirePermission("admin", app.createPricingRuleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/pricing-rules/:id", app.requirePermission("admin", app.showPricingRuleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/pricing-rules/:id", app.requirePermission("admin", app.updatePricingRuleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/pricing-rules/:id", app.requirePermission("admin", app.deletePricingRuleHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/gift-cards", app.requirePermission("admin", app.listGiftCardsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/gift-cards", app.requirePermission("admin", app.issueGiftCardsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/promo-codes", app.requirePermission("admin", app.listPromoCodesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/promo-codes", app.requirePermission("admin", app.createPromoCodeHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/promo-codes/:id", app.requirePermission("admin", app.showPromoCodeHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/promo-codes/:id", app.requirePermission("admin", app.updatePromoCodeHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/promo-codes/:id", app.requirePermission("admin", app.deletePromoCodeHandler))
 
 	return app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router))))
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"greenlight.zuyanh.net/internal/entity"
 	data "greenlight.zuyanh.net/internal/repository"
 	"net/http"
@@ -10,18 +11,25 @@ import (
 
 func (app *application) createScreenHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Number    int32 `json:"number"`
-		TheatreId int64 `json:"theatre_id"`
+		Number    int32    `json:"number"`
+		TheatreId int64    `json:"theatre_id"`
+		Formats   []string `json:"formats"`
 	}
 
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
+		return
 	}
 
 	screen := &entity.Screen{
 		Number:     input.Number,
 		Theatre_id: input.TheatreId,
+		Formats:    input.Formats,
+	}
+
+	if screen.Formats == nil {
+		screen.Formats = []string{entity.Format2D}
 	}
 
 	v := validator.New()
@@ -47,3 +55,162 @@ func (app *application) createScreenHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) listScreensHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TheatreId int64
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.TheatreId = int64(app.readInt(qs, "theatre_id", 0, v))
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+	input.Filters.SortSafelist = []string{"id", "-id", "number", "-number"}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	screens, metadata, err := app.models.Screen.GetAll(input.TheatreId, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"screens": screens, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showScreenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	screen, err := app.models.Screen.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	seats, err := app.models.Seat.GetAllByScreenId(screen.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"screen": screen, "seats": seats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateScreenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	screen, err := app.models.Screen.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Number    *int32   `json:"number"`
+		TheatreId *int64   `json:"theatre_id"`
+		Formats   []string `json:"formats"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Number != nil {
+		screen.Number = *input.Number
+	}
+	if input.TheatreId != nil {
+		screen.Theatre_id = *input.TheatreId
+	}
+	if input.Formats != nil {
+		screen.Formats = input.Formats
+	}
+
+	v := validator.New()
+
+	if data.ValidateScreens(v, screen); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Screen.Update(screen)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, data.ErrViolatesForeignKey):
+			app.violateForeignKeyResponse(w, r)
+		case errors.Is(err, data.ErrUnsupportedFormat):
+			v.AddError("formats", "must include the formats of upcoming shows on this screen")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"screen": screen}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteScreenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Screen.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, data.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "screen successfully archived"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Q     string
+		Types []string
+		Limit int
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Q = strings.TrimSpace(app.readString(qs, "q", ""))
+	input.Types = app.readCSV(qs, "types", []string{entity.SearchMovie, entity.SearchTheatre, entity.SearchPerson, entity.SearchShow})
+	input.Limit = app.readInt(qs, "limit", 5, v)
+
+	v.Check(input.Q != "", "q", "must be provided")
+	v.Check(len(input.Q) <= 100, "q", "must not be more than 100 bytes long")
+	v.Check(input.Limit > 0, "limit", "must be greater than zero")
+	v.Check(input.Limit <= 20, "limit", "must be a maximum of 20")
+	v.Check(validator.Unique(input.Types), "types", "must not contain duplicate values")
+
+	for _, t := range input.Types {
+		v.Check(validator.In(t, entity.SearchMovie, entity.SearchTheatre, entity.SearchPerson, entity.SearchShow), "types", "must be a list of movie, theatre, person or show")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, err := app.models.Search.Search(input.Q, input.Types, input.Limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
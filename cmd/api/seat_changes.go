@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// seatChangeTransPrefix marks ZaloPay transactions paying the difference of
+// a seat change.
+const seatChangeTransPrefix = "sc"
+
+func generateSeatChangeTransId(id int64) string {
+	now := time.Now()
+	return fmt.Sprintf("%02d%02d%02d_%s%v", now.Year()%100, int(now.Month()), now.Day(), seatChangeTransPrefix, id)
+}
+
+// changeSeatsHandler moves tickets of a paid reservation to other seats of
+// the same show. Each ticket keeps its ticket type and is repriced on its new
+// seat. A higher price is paid through ZaloPay before the change is applied
+// and a lower one is refunded to the wallet, but never more than was actually
+// paid for the ticket once the reservation's discounts are shared out.
+func (app *application) changeSeatsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	reservation, err := app.models.Reservation.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if reservation.UserId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Changes []struct {
+			FromSeatId int64 `json:"from_seat_id"`
+			ToSeatId   int64 `json:"to_seat_id"`
+		} `json:"changes"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	held := make(map[int64]*entity.Ticket, len(reservation.Tickets))
+	for _, ticket := range reservation.Tickets {
+		held[ticket.SeatId] = ticket
+	}
+
+	v := validator.New()
+
+	v.Check(reservation.Status == "success", "status", "reservation has not been paid")
+	v.Check(reservation.CheckedInAt == nil, "checked_in_at", "reservation has already been checked in")
+	v.Check(reservation.TransferredFrom == nil, "transferred_from", "transferred tickets cannot be changed")
+
+	v.Check(len(input.Changes) > 0, "changes", "must contain at least 1 seat")
+
+	fromSeatIds := make([]int64, len(input.Changes))
+	toSeatIds := make([]int64, len(input.Changes))
+	tickets := make([]*entity.SeatPrice, len(input.Changes))
+
+	for i, change := range input.Changes {
+		fromSeatIds[i] = change.FromSeatId
+		toSeatIds[i] = change.ToSeatId
+
+		ticket, ok := held[change.FromSeatId]
+		if !ok {
+			v.AddError("changes", "must only move seats held by the reservation")
+			continue
+		}
+		if _, ok := held[change.ToSeatId]; ok {
+			v.AddError("changes", "must not move to a seat already held by the reservation")
+		}
+
+		tickets[i] = &entity.SeatPrice{SeatId: change.ToSeatId, TicketType: ticket.TicketType}
+	}
+
+	v.Check(uniqueIDs(fromSeatIds), "changes", "must not move the same seat twice")
+	v.Check(uniqueIDs(toSeatIds), "changes", "must not move two seats to the same seat")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	show, err := app.models.Show.Get(reservation.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if time.Until(show.Showtime) < app.config.booking.changeCutoff {
+		app.changeWindowClosedResponse(w, r)
+		return
+	}
+
+	quote, err := app.quoteShow(show, tickets)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTicketTypeNotOffered):
+			v.AddError("changes", "must only move tickets of types still offered for this show")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrRecordNotFound):
+			v.AddError("changes", "must only move to seats of the show's screen")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrUnpricedSeat):
+			app.unpricedSeatResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	change := &entity.SeatChange{ReservationId: reservation.ID}
+
+	// Tickets of a reservation that was not paid for, such as one received
+	// in a transfer, have nothing to refund.
+	refundable := reservation.Amount > 0 && reservation.TransferredFrom == nil
+
+	for i, line := range quote.Lines {
+		from := held[fromSeatIds[i]]
+
+		difference := line.Price - from.Price
+		if difference < 0 {
+			var paid int64
+			if refundable {
+				paid = from.PaidPrice
+			}
+
+			if -difference > paid {
+				difference = -paid
+			}
+		}
+
+		change.Items = append(change.Items, &entity.SeatChangeItem{
+			FromSeatId: from.SeatId,
+			ToSeatId:   line.SeatId,
+			TicketType: line.TicketType,
+			Price:      line.Price,
+		})
+		change.Amount += difference
+	}
+
+	err = app.models.SeatChanges.Insert(change)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrSeatUnavailable):
+			v.AddError("changes", "must only move to available seats")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrSeatListed):
+			v.AddError("changes", "must not move seats listed for resale")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrSeatChangePending):
+			v.AddError("changes", "must not move seats already being changed")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrTransferPending):
+			v.AddError("changes", "must not move seats being transferred")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if change.Status == entity.SeatChangeSuccess {
+		reservation, err = app.models.Reservation.GetById(reservation.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"reservation": reservation, "seat_change": change}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	param := Params{
+		AppUser:       user.Email,
+		ItemPrice:     strconv.FormatInt(change.Amount, 10),
+		ReservationId: generateSeatChangeTransId(change.ID),
+	}
+
+	response, err := CreaterOrder(param)
+	if err != nil {
+		if err := app.models.SeatChanges.Expire(change.ID); err != nil {
+			app.logger.PrintError(err, nil)
+		}
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"seat_change": change, "payment": response}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+
+	go func(changeId int64) {
+		time.Sleep(10 * time.Minute)
+
+		err := app.models.SeatChanges.Expire(changeId)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	}(change.ID)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"greenlight.zuyanh.net/internal/entity"
 	"greenlight.zuyanh.net/internal/repository"
 	"net/http"
@@ -12,6 +13,7 @@ func (app *application) createSeatHandler(w http.ResponseWriter, r *http.Request
 	var input struct {
 		Row       string `json:"row"`
 		Number    int32  `json:"number"`
+		Category  string `json:"category"`
 		Price     int32  `json:"price"`
 		Screen_id int64  `json:"screen_id"`
 	}
@@ -25,10 +27,15 @@ func (app *application) createSeatHandler(w http.ResponseWriter, r *http.Request
 	seat := &entity.Seat{
 		Row:       input.Row,
 		Number:    input.Number,
+		Category:  input.Category,
 		Price:     input.Price,
 		Screen_id: input.Screen_id,
 	}
 
+	if seat.Category == "" {
+		seat.Category = "standard"
+	}
+
 	v := validator.New()
 
 	if repository.ValidateSeat(v, seat); !v.Valid() {
@@ -38,7 +45,14 @@ func (app *application) createSeatHandler(w http.ResponseWriter, r *http.Request
 
 	err = app.models.Seat.Insert(seat)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.violateForeignKeyResponse(w, r)
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			app.duplicateConstraintResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
@@ -52,6 +66,7 @@ func (app *application) createSeatHandler(w http.ResponseWriter, r *http.Request
 func (app *application) listAvailableSeatsHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Show_id int64
+		Status  string
 		repository.Filters
 	}
 
@@ -60,18 +75,21 @@ func (app *application) listAvailableSeatsHandler(w http.ResponseWriter, r *http
 	qs := r.URL.Query()
 
 	input.Show_id = int64(app.readInt(qs, "show_id", 0, v))
+	input.Status = app.readString(qs, "status", "")
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 	input.Filters.SortSafelist = []string{"id", "-id", "row", "-row", "number", "-number", "price", "-price"}
 
+	v.Check(validator.In(input.Status, "", "available", "booked"), "status", "must be available or booked")
+
 	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	seats, metadata, err := app.models.Seat.GetAllByShowId(input.Show_id, "", input.Filters)
+	seats, metadata, err := app.models.Seat.GetAllByShowId(input.Show_id, input.Status, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -82,3 +100,125 @@ func (app *application) listAvailableSeatsHandler(w http.ResponseWriter, r *http
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) showSeatHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	seat, err := app.models.Seat.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"seat": seat}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateSeatHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	seat, err := app.models.Seat.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Row      *string `json:"row"`
+		Number   *int32  `json:"number"`
+		Category *string `json:"category"`
+		Price    *int32  `json:"price"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Row != nil {
+		seat.Row = *input.Row
+	}
+	if input.Number != nil {
+		seat.Number = *input.Number
+	}
+	if input.Category != nil {
+		seat.Category = *input.Category
+	}
+	if input.Price != nil {
+		seat.Price = *input.Price
+	}
+
+	v := validator.New()
+
+	if repository.ValidateSeat(v, seat); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Seat.Update(seat)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.violateForeignKeyResponse(w, r)
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			app.duplicateConstraintResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"seat": seat}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteSeatHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Seat.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "seat successfully archived"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
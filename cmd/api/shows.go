@@ -13,9 +13,12 @@ import (
 
 func (app *application) createShowHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		ShowTime time.Time `json:"showtime"`
-		MovieId  int64     `json:"movie_id"`
-		ScreenId int64     `json:"screen_id"`
+		ShowTime         time.Time `json:"showtime"`
+		MovieId          int64     `json:"movie_id"`
+		ScreenId         int64     `json:"screen_id"`
+		Format           string    `json:"format"`
+		AudioLanguage    string    `json:"audio_language"`
+		SubtitleLanguage string    `json:"subtitle_language"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -38,9 +41,16 @@ func (app *application) createShowHandler(w http.ResponseWriter, r *http.Request
 	fmt.Printf("Seats retrieved: %v\n", seatIds)
 
 	show := &entity.Show{
-		Showtime: input.ShowTime,
-		MovieId:  input.MovieId,
-		ScreenId: input.ScreenId,
+		Showtime:         input.ShowTime,
+		MovieId:          input.MovieId,
+		ScreenId:         input.ScreenId,
+		Format:           input.Format,
+		AudioLanguage:    input.AudioLanguage,
+		SubtitleLanguage: input.SubtitleLanguage,
+	}
+
+	if show.Format == "" {
+		show.Format = entity.Format2D
 	}
 
 	v := validator.New()
@@ -55,6 +65,9 @@ func (app *application) createShowHandler(w http.ResponseWriter, r *http.Request
 		switch {
 		case errors.Is(err, repository.ErrViolatesForeignKey):
 			app.violateForeignKeyResponse(w, r)
+		case errors.Is(err, repository.ErrUnsupportedFormat):
+			v.AddError("format", "is not supported by the screen")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -75,8 +88,7 @@ func (app *application) createShowHandler(w http.ResponseWriter, r *http.Request
 
 func (app *application) listShowHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Date  string
-		Title string
+		repository.ShowSearch
 		repository.Filters
 	}
 
@@ -86,20 +98,28 @@ func (app *application) listShowHandler(w http.ResponseWriter, r *http.Request)
 
 	input.Date = app.readString(qs, "date", "")
 	input.Title = app.readString(qs, "title", "")
+	input.Format = app.readString(qs, "format", "")
+	input.AudioLanguage = app.readString(qs, "audio_language", "")
+	input.SubtitleLanguage = app.readString(qs, "subtitle_language", "")
 
 	input.Filters.Page = app.readInt(qs, "page", 1, v)
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
-	input.Filters.SortSafelist = []string{"id", "-id", "datetime", "-datetime"}
+	input.Filters.SortSafelist = []string{"id", "-id", "showtime", "-showtime"}
 
-	repository.ValidateDateFormat(v, input.Date)
+	if input.Date != "" {
+		repository.ValidateDateFormat(v, input.Date)
+	}
+	if input.Format != "" {
+		v.Check(validator.In(input.Format, entity.Formats...), "format", "must be one of 2d, 3d, imax or 4dx")
+	}
 	repository.ValidateFilters(v, input.Filters)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	shows, metadata, err := app.models.Show.GetAll(input.Date, input.Title, input.Filters)
+	shows, metadata, err := app.models.Show.GetAll(input.ShowSearch, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -110,3 +130,136 @@ func (app *application) listShowHandler(w http.ResponseWriter, r *http.Request)
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) showShowHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	show, err := app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"show": show}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateShowHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	show, err := app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		ShowTime         *time.Time `json:"showtime"`
+		MovieId          *int64     `json:"movie_id"`
+		ScreenId         *int64     `json:"screen_id"`
+		Format           *string    `json:"format"`
+		AudioLanguage    *string    `json:"audio_language"`
+		SubtitleLanguage *string    `json:"subtitle_language"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.ShowTime != nil {
+		show.Showtime = *input.ShowTime
+	}
+	if input.MovieId != nil {
+		show.MovieId = *input.MovieId
+	}
+	if input.ScreenId != nil {
+		show.ScreenId = *input.ScreenId
+	}
+	if input.Format != nil {
+		show.Format = *input.Format
+	}
+	if input.AudioLanguage != nil {
+		show.AudioLanguage = *input.AudioLanguage
+	}
+	if input.SubtitleLanguage != nil {
+		show.SubtitleLanguage = *input.SubtitleLanguage
+	}
+
+	v := validator.New()
+
+	if repository.ValidateShow(v, show); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Show.Update(show)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.violateForeignKeyResponse(w, r)
+		case errors.Is(err, repository.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
+		case errors.Is(err, repository.ErrUnsupportedFormat):
+			v.AddError("format", "is not supported by the screen")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"show": show}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteShowHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Show.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "show successfully archived"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
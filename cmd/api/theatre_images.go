@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"net/http"
+)
+
+func (app *application) uploadTheatreImageHandler(w http.ResponseWriter, r *http.Request) {
+	theatreId, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	data, err := app.readImageUpload(w, r, "image")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	img, format, ok := app.decodeImageResponse(w, r, data)
+	if !ok {
+		return
+	}
+
+	url, thumbnailURL, err := app.storeImage(fmt.Sprintf("theatres/%d", theatreId), data, img, format)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	image := &entity.TheatreImage{
+		TheatreId:    theatreId,
+		URL:          url,
+		ThumbnailURL: thumbnailURL,
+	}
+
+	err = app.models.TheatreImages.Insert(image)
+	if err != nil {
+		app.removeImage(url)
+		switch {
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": image}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listTheatreImagesHandler(w http.ResponseWriter, r *http.Request) {
+	theatreId, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	images, err := app.models.TheatreImages.GetAllForTheatre(theatreId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"images": images}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTheatreImageHandler(w http.ResponseWriter, r *http.Request) {
+	theatreId, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	imageId, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	image, err := app.models.TheatreImages.Get(imageId)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if image.TheatreId != theatreId {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.TheatreImages.Delete(image.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.background(func() {
+		app.removeImage(image.URL)
+	})
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"greenlight.zuyanh.net/internal/entity"
 	"greenlight.zuyanh.net/internal/repository"
 	"net/http"
@@ -10,8 +11,14 @@ import (
 
 func (app *application) createTheatreHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Name string `json:"name"`
-		City string `json:"city"`
+		Name         string            `json:"name"`
+		City         string            `json:"city"`
+		Address      string            `json:"address"`
+		Latitude     *float64          `json:"latitude"`
+		Longitude    *float64          `json:"longitude"`
+		Phone        string            `json:"phone"`
+		OpeningHours map[string]string `json:"opening_hours"`
+		Amenities    []string          `json:"amenities"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -21,8 +28,21 @@ func (app *application) createTheatreHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	theatre := &entity.Theatres{
-		Name: input.Name,
-		City: input.City,
+		Name:         input.Name,
+		City:         input.City,
+		Address:      input.Address,
+		Latitude:     input.Latitude,
+		Longitude:    input.Longitude,
+		Phone:        input.Phone,
+		OpeningHours: input.OpeningHours,
+		Amenities:    input.Amenities,
+	}
+
+	if theatre.OpeningHours == nil {
+		theatre.OpeningHours = map[string]string{}
+	}
+	if theatre.Amenities == nil {
+		theatre.Amenities = []string{}
 	}
 
 	v := validator.New()
@@ -82,3 +102,178 @@ func (app *application) listTheatreHandler(w http.ResponseWriter, r *http.Reques
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) showTheatreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	theatre, err := app.models.Theatres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"theatre": theatre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateTheatreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	theatre, err := app.models.Theatres.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name         *string           `json:"name"`
+		City         *string           `json:"city"`
+		Address      *string           `json:"address"`
+		Latitude     *float64          `json:"latitude"`
+		Longitude    *float64          `json:"longitude"`
+		Phone        *string           `json:"phone"`
+		OpeningHours map[string]string `json:"opening_hours"`
+		Amenities    []string          `json:"amenities"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		theatre.Name = *input.Name
+	}
+	if input.City != nil {
+		theatre.City = *input.City
+	}
+	if input.Address != nil {
+		theatre.Address = *input.Address
+	}
+	if input.Latitude != nil {
+		theatre.Latitude = input.Latitude
+	}
+	if input.Longitude != nil {
+		theatre.Longitude = input.Longitude
+	}
+	if input.Phone != nil {
+		theatre.Phone = *input.Phone
+	}
+	if input.OpeningHours != nil {
+		theatre.OpeningHours = input.OpeningHours
+	}
+	if input.Amenities != nil {
+		theatre.Amenities = input.Amenities
+	}
+
+	v := validator.New()
+
+	if repository.ValidateTheatres(v, theatre); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Theatres.Update(theatre)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		case errors.Is(err, repository.ErrDuplicateConstraint):
+			app.duplicateConstraintResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"theatre": theatre}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTheatreHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	err = app.models.Theatres.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrActiveReservations):
+			app.activeReservationsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "theatre successfully archived"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) nearbyTheatresHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Lat       float64
+		Lng       float64
+		RadiusKm  float64
+		Limit     int
+		WithShows bool
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	v.Check(qs.Get("lat") != "", "lat", "must be provided")
+	v.Check(qs.Get("lng") != "", "lng", "must be provided")
+
+	input.Lat = app.readFloat(qs, "lat", 0, v)
+	input.Lng = app.readFloat(qs, "lng", 0, v)
+	input.RadiusKm = app.readFloat(qs, "radius_km", 10, v)
+	input.Limit = app.readInt(qs, "limit", 20, v)
+	input.WithShows = app.readBool(qs, "shows", false, v)
+
+	if repository.ValidateNearby(v, input.Lat, input.Lng, input.RadiusKm, input.Limit); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	theatres, err := app.models.Theatres.Nearby(input.Lat, input.Lng, input.RadiusKm, input.Limit, input.WithShows)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"theatres": theatres}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// createTicketTransferHandler offers seats of a paid reservation to another
+// registered user. Leaving seat_ids out transfers every seat.
+func (app *application) createTicketTransferHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	reservation, err := app.models.Reservation.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if reservation.UserId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Email   string  `json:"email"`
+		SeatIds []int64 `json:"seat_ids"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if repository.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	recipient, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			v.AddError("email", "no registered user with this email address")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	held := make(map[int64]bool, len(reservation.Tickets))
+	for _, ticket := range reservation.Tickets {
+		held[ticket.SeatId] = true
+	}
+
+	if len(input.SeatIds) == 0 {
+		for _, ticket := range reservation.Tickets {
+			input.SeatIds = append(input.SeatIds, ticket.SeatId)
+		}
+	}
+
+	transfer := &entity.TicketTransfer{
+		ReservationId: reservation.ID,
+		FromUserId:    user.ID,
+		ToUserId:      recipient.ID,
+		SeatIds:       input.SeatIds,
+	}
+
+	v.Check(reservation.Status == "success", "status", "reservation has not been paid")
+	v.Check(reservation.CheckedInAt == nil, "checked_in_at", "reservation has already been checked in")
+
+	for _, seatId := range transfer.SeatIds {
+		if !held[seatId] {
+			v.AddError("seat_ids", "must only contain seats held by the reservation")
+			break
+		}
+	}
+
+	if repository.ValidateTicketTransfer(v, transfer); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	show, err := app.models.Show.Get(reservation.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !time.Now().Before(show.Showtime) {
+		v.AddError("show", "has already started")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TicketTransfers.Insert(transfer)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTransferPending):
+			v.AddError("seat_ids", "must not contain seats already being transferred")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrSeatListed):
+			v.AddError("seat_ids", "must not contain seats listed for resale")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"ticket_transfer": transfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTicketTransfersHandler returns the transfer history of one of the
+// user's reservations.
+func (app *application) listTicketTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	reservation, err := app.models.Reservation.GetById(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if reservation.UserId != user.ID {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	transfers, err := app.models.TicketTransfers.GetAllForReservation(reservation.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_transfers": transfers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listIncomingTransfersHandler returns the transfers waiting for the user to
+// accept or decline them.
+func (app *application) listIncomingTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	transfers, err := app.models.TicketTransfers.GetPendingForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_transfers": transfers}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// acceptTicketTransferHandler moves the seats of a transfer to a reservation
+// of the recipient. The recipient must be allowed to see the movie and to use
+// the ticket types being handed over, as if they had booked them.
+func (app *application) acceptTicketTransferHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	transfer, ok := app.readTicketTransfer(w, r, func(transfer *entity.TicketTransfer) bool {
+		return transfer.ToUserId == user.ID
+	})
+	if !ok {
+		return
+	}
+
+	reservation, err := app.models.Reservation.GetById(transfer.ReservationId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	show, err := app.models.Show.Get(reservation.ShowId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(show.MovieId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if message := ageRestrictionError(user, movie, show.Showtime); message != "" {
+		app.ageRestrictedResponse(w, r, message)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(time.Now().Before(show.Showtime), "show", "has already started")
+
+	transferred := make(map[int64]bool, len(transfer.SeatIds))
+	for _, seatId := range transfer.SeatIds {
+		transferred[seatId] = true
+	}
+
+	for _, ticket := range reservation.Tickets {
+		if !transferred[ticket.SeatId] {
+			continue
+		}
+		if message := ticketTypeError(user, movie, ticket.TicketType); message != "" {
+			v.AddError("ticket_type", message)
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TicketTransfers.Accept(transfer)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reservation, err = app.models.Reservation.GetById(*transfer.NewReservationId)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_transfer": transfer, "reservation": reservation}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) declineTicketTransferHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	app.closeTicketTransfer(w, r, entity.TransferDeclined, func(transfer *entity.TicketTransfer) bool {
+		return transfer.ToUserId == user.ID
+	})
+}
+
+func (app *application) cancelTicketTransferHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	app.closeTicketTransfer(w, r, entity.TransferCancelled, func(transfer *entity.TicketTransfer) bool {
+		return transfer.FromUserId == user.ID
+	})
+}
+
+func (app *application) closeTicketTransfer(w http.ResponseWriter, r *http.Request, status string, allowed func(*entity.TicketTransfer) bool) {
+	transfer, ok := app.readTicketTransfer(w, r, allowed)
+	if !ok {
+		return
+	}
+
+	err := app.models.TicketTransfers.UpdateStatus(transfer, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_transfer": transfer}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readTicketTransfer loads the transfer named in the URL. Transfers the user
+// is not allowed to act on are reported as not found. It writes the error
+// response itself and returns false if the request should stop.
+func (app *application) readTicketTransfer(w http.ResponseWriter, r *http.Request, allowed func(*entity.TicketTransfer) bool) (*entity.TicketTransfer, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+
+	transfer, err := app.models.TicketTransfers.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if !allowed(transfer) {
+		app.notFoundErrorResponse(w, r)
+		return nil, false
+	}
+
+	return transfer, true
+}
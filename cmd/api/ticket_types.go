@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+func (app *application) listTicketTypesHandler(w http.ResponseWriter, r *http.Request) {
+	ticketTypes, err := app.models.TicketTypes.GetAll()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_types": ticketTypes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateTicketTypeHandler(w http.ResponseWriter, r *http.Request) {
+	code := httprouter.ParamsFromContext(r.Context()).ByName("code")
+
+	ticketType, err := app.models.TicketTypes.Get(code)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		Name       *string `json:"name"`
+		Adjustment *string `json:"adjustment"`
+		Amount     *int64  `json:"amount"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.Name != nil {
+		ticketType.Name = *input.Name
+	}
+	if input.Adjustment != nil {
+		ticketType.Adjustment = *input.Adjustment
+	}
+	if input.Amount != nil {
+		ticketType.Amount = *input.Amount
+	}
+
+	v := validator.New()
+
+	v.Check(ticketType.Name != "", "name", "must be provided")
+	v.Check(len(ticketType.Name) <= 50, "name", "must not be more than 50 bytes long")
+
+	if repository.ValidateTicketType(v, ticketType); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TicketTypes.Update(ticketType)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_type": ticketType}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showShowTicketTypesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	ticketTypes, err := app.models.TicketTypes.GetForShow(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_types": ticketTypes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateShowTicketTypesHandler replaces the ticket types sold for a show and
+// their modifiers, e.g. {"ticket_types": [{"code": "adult", "adjustment":
+// "percent", "amount": 0}]}. An empty list restores the defaults.
+func (app *application) updateShowTicketTypesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundErrorResponse(w, r)
+		return
+	}
+
+	_, err = app.models.Show.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			app.notFoundErrorResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input struct {
+		TicketTypes []*entity.TicketType `json:"ticket_types"`
+	}
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	v.Check(input.TicketTypes != nil, "ticket_types", "must be provided")
+
+	codes := make([]string, 0, len(input.TicketTypes))
+	for _, ticketType := range input.TicketTypes {
+		repository.ValidateTicketType(v, ticketType)
+		codes = append(codes, ticketType.Code)
+	}
+	v.Check(validator.Unique(codes), "ticket_types", "must not contain the same ticket type twice")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.TicketTypes.SetForShow(id, input.TicketTypes)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrViolatesForeignKey):
+			app.violateForeignKeyResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	ticketTypes, err := app.models.TicketTypes.GetForShow(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"ticket_types": ticketTypes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/repository"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+// giftCardTransPrefix marks ZaloPay transactions paying for gift cards
+// rather than reservations.
+const giftCardTransPrefix = "gc"
+
+func generateGiftCardTransId(id int64) string {
+	now := time.Now()
+	return fmt.Sprintf("%02d%02d%02d_%s%v", now.Year()%100, int(now.Month()), now.Day(), giftCardTransPrefix, id)
+}
+
+func (app *application) listGiftCardsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		repository.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "amount", "-id", "-created_at", "-amount"}
+
+	if input.Status != "" {
+		v.Check(validator.In(input.Status, entity.GiftCardPending, entity.GiftCardActive, entity.GiftCardRedeemed), "status", "must be pending, active or redeemed")
+	}
+
+	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	cards, metadata, err := app.models.GiftCards.GetAll(input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"gift_cards": cards, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// issueGiftCardsHandler issues a batch of active gift cards of the same
+// amount, e.g. for a corporate order or a giveaway.
+func (app *application) issueGiftCardsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Count     int    `json:"count"`
+		Amount    int64  `json:"amount"`
+		ExpiresAt string `json:"expires_at"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	template := &entity.GiftCard{
+		Amount:    input.Amount,
+		Status:    entity.GiftCardActive,
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	v := validator.New()
+
+	v.Check(input.Count > 0, "count", "must be greater than zero")
+	v.Check(input.Count <= 500, "count", "must not be more than 500")
+
+	if repository.ValidateGiftCard(v, template); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	cards := make([]*entity.GiftCard, input.Count)
+	for i := range cards {
+		card := *template
+		cards[i] = &card
+	}
+
+	err = app.models.GiftCards.Insert(cards...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"gift_cards": cards}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purchaseGiftCardHandler sells a gift card through ZaloPay. The card is
+// pending, and cannot be redeemed, until the payment callback activates it.
+func (app *application) purchaseGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Amount int64 `json:"amount"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	card := &entity.GiftCard{
+		Amount:      input.Amount,
+		Status:      entity.GiftCardPending,
+		PurchasedBy: &user.ID,
+	}
+
+	v := validator.New()
+
+	if repository.ValidateGiftCard(v, card); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.GiftCards.Insert(card)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	param := Params{
+		AppUser:       user.Email,
+		ItemPrice:     strconv.FormatInt(card.Amount, 10),
+		ReservationId: generateGiftCardTransId(card.ID),
+	}
+
+	response, err := CreaterOrder(param)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"gift_card": card, "payment": response}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) redeemGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if repository.ValidateGiftCardCode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	card, transaction, err := app.models.GiftCards.Redeem(input.Code, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRecordNotFound):
+			v.AddError("code", "is not a valid gift card code")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrGiftCardUnavailable):
+			v.AddError("code", "has already been redeemed or is not paid for yet")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, repository.ErrGiftCardExpired):
+			v.AddError("code", "has expired")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"gift_card": card, "transaction": transaction}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showWalletHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	wallet, err := app.models.Wallets.Get(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"wallet": wallet}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWalletTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		repository.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-created_at")
+	input.Filters.SortSafelist = []string{"id", "created_at", "amount", "-id", "-created_at", "-amount"}
+
+	if repository.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	transactions, metadata, err := app.models.Wallets.GetTransactions(user.ID, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"transactions": transactions, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -108,9 +109,48 @@ func (app *application) zaloPayCallBackHandler(w http.ResponseWriter, r *http.Re
 		result["return_message"] = "success"
 
 		fmt.Println("Sending:", dataMap["app_trans_id"].(string))
-		value, _ := strconv.ParseInt(dataMap["app_trans_id"].(string)[7:], 10, 64)
-
-		app.transChannel <- value
+		transId := dataMap["app_trans_id"].(string)[7:]
+
+		// The paying ZaloPay account links reservations made from different
+		// accounts of the same buyer.
+		paymentIdentity, _ := dataMap["merchant_user_id"].(string)
+
+		switch {
+		case strings.HasPrefix(transId, giftCardTransPrefix):
+			value, _ := strconv.ParseInt(strings.TrimPrefix(transId, giftCardTransPrefix), 10, 64)
+
+			if err := app.models.GiftCards.Activate(value); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		case strings.HasPrefix(transId, seatChangeTransPrefix):
+			value, _ := strconv.ParseInt(strings.TrimPrefix(transId, seatChangeTransPrefix), 10, 64)
+
+			if err := app.models.SeatChanges.Complete(value); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		case strings.HasPrefix(transId, resaleTransPrefix):
+			value, _ := strconv.ParseInt(strings.TrimPrefix(transId, resaleTransPrefix), 10, 64)
+
+			if err := app.models.Resale.Complete(value); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		case strings.HasPrefix(transId, groupShareTransPrefix):
+			value, _ := strconv.ParseInt(strings.TrimPrefix(transId, groupShareTransPrefix), 10, 64)
+
+			if err := app.completeGroupShare(value, paymentIdentity); err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		default:
+			value, _ := strconv.ParseInt(transId, 10, 64)
+
+			if paymentIdentity != "" {
+				if err := app.models.Reservation.SetPaymentIdentity(value, paymentIdentity); err != nil {
+					app.logger.PrintError(err, nil)
+				}
+			}
+
+			app.transChannel <- value
+		}
 
 		var dataJSON map[string]interface{}
 		json.Unmarshal([]byte(dataStr), &dataJSON)
@@ -0,0 +1,80 @@
+// Command purge permanently deletes soft-deleted movies, theatres, screens,
+// seats and shows once they have been archived for longer than the retention
+// period. It only reports what would be removed unless run with -confirm.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"os"
+	"strconv"
+	"time"
+
+	_ "github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/jsonlog"
+	"greenlight.zuyanh.net/internal/repository"
+)
+
+// minRetention guards against a mistyped -retention wiping data that was
+// archived moments ago and may still need restoring.
+const minRetention = 30 * 24 * time.Hour
+
+func main() {
+	var (
+		dsn       string
+		retention time.Duration
+		confirm   bool
+	)
+
+	flag.StringVar(&dsn, "db-dsn", os.Getenv("GREENLIGHT_DB_DSN"), "PostgreSQL DSN")
+	flag.DurationVar(&retention, "retention", 365*24*time.Hour, "How long archived records are kept before purging")
+	flag.BoolVar(&confirm, "confirm", false, "Delete the records instead of only reporting them")
+	flag.Parse()
+
+	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
+
+	if retention < minRetention {
+		logger.PrintFatal(errors.New("retention must be at least 720h (30 days)"), map[string]string{
+			"retention": retention.String(),
+		})
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	before := time.Now().Add(-retention)
+
+	models := repository.NewModel(db)
+
+	purged, err := models.Archive.Purge(before, !confirm)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	properties := map[string]string{
+		"archived_before": before.Format(time.RFC3339),
+		"dry_run":         strconv.FormatBool(!confirm),
+	}
+	for archiveType, count := range purged {
+		properties[archiveType] = strconv.FormatInt(count, 10)
+	}
+
+	if confirm {
+		logger.PrintInfo("archived records purged", properties)
+	} else {
+		logger.PrintInfo("dry run, rerun with -confirm to purge these archived records", properties)
+	}
+}
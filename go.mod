@@ -6,17 +6,19 @@ require github.com/julienschmidt/httprouter v1.3.0
 
 require github.com/lib/pq v1.10.8
 
-require golang.org/x/time v0.6.0
+require (
+	github.com/go-mail/mail/v2 v2.3.0
+	github.com/stretchr/testify v1.9.0
+	github.com/zpmep/hmacutil v0.0.0-20190619043418-253bc927934c
+	golang.org/x/crypto v0.25.0
+	golang.org/x/time v0.6.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/go-mail/mail/v2 v2.3.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e // indirect
-	github.com/stretchr/testify v1.9.0 // indirect
-	github.com/zpmep/hmacutil v0.0.0-20190619043418-253bc927934c // indirect
-	golang.org/x/crypto v0.25.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
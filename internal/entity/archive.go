@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Archive types double as the names of the tables that support soft
+// deletion.
+const (
+	ArchiveMovie   = "movies"
+	ArchiveTheatre = "theatres"
+	ArchiveScreen  = "screens"
+	ArchiveSeat    = "seats"
+	ArchiveShow    = "shows"
+)
+
+var ArchiveTypes = []string{ArchiveMovie, ArchiveTheatre, ArchiveScreen, ArchiveSeat, ArchiveShow}
+
+type ArchivedItem struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
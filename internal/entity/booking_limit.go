@@ -0,0 +1,43 @@
+package entity
+
+import "time"
+
+// BookingLimits caps what one account may hold for a show. Default is set
+// when the show has no limits of its own and the server's apply.
+type BookingLimits struct {
+	ShowId          int64 `json:"show_id"`
+	MaxSeatsPerUser int32 `json:"max_seats_per_user"`
+	MaxPendingHolds int32 `json:"max_pending_holds"`
+	Default         bool  `json:"default"`
+}
+
+// BookingUsage is what a user already holds for a show. LinkedSeats are held
+// by other accounts that paid with the same payment identity, and IPSeats
+// were booked from the user's IP address within the velocity window by any
+// account.
+type BookingUsage struct {
+	SeatsHeld    int `json:"seats_held"`
+	PendingHolds int `json:"pending_holds"`
+	LinkedSeats  int `json:"linked_seats"`
+	IPSeats      int `json:"ip_seats"`
+}
+
+// Reasons an account was flagged.
+const (
+	FlagSharedPayment = "shared_payment"
+	FlagSharedIP      = "shared_ip"
+)
+
+// AccountFlag marks an account whose bookings tripped a velocity check.
+// PendingHolds is the number of unpaid reservations the account holds for
+// the show.
+type AccountFlag struct {
+	ID           int64      `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UserId       int64      `json:"user_id"`
+	Email        string     `json:"email"`
+	ShowId       *int64     `json:"show_id,omitempty"`
+	Reason       string     `json:"reason"`
+	PendingHolds int        `json:"pending_holds"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+}
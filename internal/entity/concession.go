@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// Concession is a food or drink item sold by a theatre alongside tickets.
+type Concession struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	TheatreId   int64     `json:"theatre_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Price       int64     `json:"price"`
+	Stock       int32     `json:"stock"`
+	Active      bool      `json:"active"`
+	Version     int32     `json:"version"`
+}
+
+// ConcessionItem is a line of concessions on a reservation. Price is the
+// unit price it was sold at.
+type ConcessionItem struct {
+	ConcessionId int64  `json:"concession_id"`
+	Name         string `json:"name"`
+	Quantity     int32  `json:"quantity"`
+	Price        int64  `json:"price"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+const (
+	RoleDirector = "director"
+	RoleActor    = "actor"
+	RoleWriter   = "writer"
+)
+
+type Credit struct {
+	ID           int64  `json:"id"`
+	MovieId      int64  `json:"movie_id"`
+	PersonId     int64  `json:"person_id"`
+	Role         string `json:"role"`
+	Character    string `json:"character,omitempty"`
+	BillingOrder int32  `json:"billing_order"`
+	PersonName   string `json:"person_name,omitempty"`
+	MovieTitle   string `json:"movie_title,omitempty"`
+	MovieYear    int32  `json:"movie_year,omitempty"`
+}
@@ -0,0 +1,19 @@
+package entity
+
+const (
+	Format2D   = "2d"
+	Format3D   = "3d"
+	FormatIMAX = "imax"
+	Format4DX  = "4dx"
+)
+
+// Formats are the presentation formats a screen can be equipped for. Each
+// has a row in the formats table holding its per-seat surcharge.
+var Formats = []string{Format2D, Format3D, FormatIMAX, Format4DX}
+
+type Format struct {
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Surcharge int64  `json:"surcharge"`
+	Version   int32  `json:"version"`
+}
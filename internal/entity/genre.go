@@ -0,0 +1,19 @@
+package entity
+
+type Genre struct {
+	ID         int64             `json:"id"`
+	Slug       string            `json:"slug"`
+	Name       string            `json:"name"`
+	Names      map[string]string `json:"names,omitempty"`
+	MovieCount int64             `json:"movie_count"`
+	Version    int32             `json:"version"`
+}
+
+// DisplayName returns the name localised for lang, falling back to the
+// default name when no translation exists.
+func (g *Genre) DisplayName(lang string) string {
+	if name, ok := g.Names[lang]; ok && name != "" {
+		return name
+	}
+	return g.Name
+}
@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+const (
+	SharePending = "pending"
+	SharePaying  = "paying"
+	SharePaid    = "paid"
+	ShareExpired = "expired"
+)
+
+// GroupBooking holds seats for a group until ExpiresAt while its members
+// each pay their share. Seats of shares left unpaid are released then.
+type GroupBooking struct {
+	ID          int64         `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	OrganizerId int64         `json:"organizer_id"`
+	ShowId      int64         `json:"show_id"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+	Shares      []*GroupShare `json:"shares,omitempty"`
+}
+
+// GroupShare is the part of a group booking one member pays for. Token is
+// the invitation the organizer hands to the member. A share is paying while
+// a member's payment is in progress, and once paid its reservation belongs
+// to MemberId.
+type GroupShare struct {
+	ID             int64      `json:"id"`
+	GroupBookingId int64      `json:"group_booking_id"`
+	ReservationId  *int64     `json:"reservation_id,omitempty"`
+	Token          string     `json:"token,omitempty"`
+	SeatIds        []int64    `json:"seat_ids"`
+	Amount         int64      `json:"amount"`
+	Status         string     `json:"status"`
+	MemberId       *int64     `json:"member_id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	ShowId         int64      `json:"show_id"`
+}
@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+const (
+	TierSilver  = "silver"
+	TierGold    = "gold"
+	TierDiamond = "diamond"
+)
+
+// LoyaltyTier is reached by spending at least MinSpend on paid reservations
+// over the last 365 days. Multiplier is the percentage of the base earn rate
+// its members get.
+type LoyaltyTier struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	MinSpend   int64  `json:"min_spend"`
+	Multiplier int32  `json:"multiplier"`
+	Version    int32  `json:"version"`
+}
+
+const (
+	LoyaltyEarn    = "earn"
+	LoyaltyRedeem  = "redeem"
+	LoyaltyRefund  = "refund"
+	LoyaltyReverse = "reverse"
+)
+
+// LoyaltyTransaction is one change to a member's points. Points is signed,
+// earned points are positive, and Balance is the points left after it.
+type LoyaltyTransaction struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UserId        int64     `json:"user_id"`
+	ReservationId *int64    `json:"reservation_id,omitempty"`
+	Kind          string    `json:"kind"`
+	Points        int64     `json:"points"`
+	Balance       int64     `json:"balance"`
+}
+
+// Loyalty is a member's standing: their points, the tier their annual spend
+// puts them in and how much more they need to spend to reach the next one.
+type Loyalty struct {
+	Points          int64        `json:"points"`
+	AnnualSpend     int64        `json:"annual_spend"`
+	Tier            *LoyaltyTier `json:"tier"`
+	NextTier        *LoyaltyTier `json:"next_tier,omitempty"`
+	SpendToNextTier int64        `json:"spend_to_next_tier,omitempty"`
+}
@@ -3,11 +3,54 @@ package entity
 import "time"
 
 type Movie struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID            int64     `json:"id"`
+	ExternalId    string    `json:"external_id,omitempty"`
+	CreatedAt     time.Time `json:"-"`
+	Title         string    `json:"title"`
+	Year          int32     `json:"year,omitempty"`
+	Runtime       Runtime   `json:"runtime,omitempty"`
+	Genres        []string  `json:"genres,omitempty"`
+	Img           string    `json:"img,omitempty"`
+	Language      string    `json:"language,omitempty"`
+	AgeRating     string    `json:"age_rating"`
+	AverageRating float64   `json:"average_rating"`
+	RatingCount   int32     `json:"rating_count"`
+	Version       int32     `json:"version"`
+}
+
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year,omitempty"`
+	Img   string `json:"img,omitempty"`
+}
+
+// Age ratings follow the Vietnamese film classification. C16 and C18 films
+// must not be sold to customers below the minimum age.
+const (
+	RatingGeneral = "P"
+	RatingC13     = "C13"
+	RatingC16     = "C16"
+	RatingC18     = "C18"
+)
+
+var AgeRatings = []string{RatingGeneral, RatingC13, RatingC16, RatingC18}
+
+func (m *Movie) MinimumAge() int {
+	switch m.AgeRating {
+	case RatingC13:
+		return 13
+	case RatingC16:
+		return 16
+	case RatingC18:
+		return 18
+	default:
+		return 0
+	}
+}
+
+// Restricted reports whether tickets for the movie may only be sold to
+// customers with a verified date of birth.
+func (m *Movie) Restricted() bool {
+	return m.AgeRating == RatingC16 || m.AgeRating == RatingC18
 }
@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+type Person struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"-"`
+	Name      string     `json:"name"`
+	Biography string     `json:"biography,omitempty"`
+	BirthDate *time.Time `json:"birth_date,omitempty"`
+	Img       string     `json:"img,omitempty"`
+	Version   int32      `json:"version"`
+}
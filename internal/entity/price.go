@@ -0,0 +1,15 @@
+package entity
+
+type PriceCategory struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Version int32  `json:"version"`
+}
+
+// ShowPrice is the effective price of a category for a show. Overridden
+// reports whether it comes from the show rather than the screen.
+type ShowPrice struct {
+	Category   string `json:"category"`
+	Price      int64  `json:"price"`
+	Overridden bool   `json:"overridden"`
+}
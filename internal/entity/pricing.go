@@ -0,0 +1,46 @@
+package entity
+
+const (
+	AdjustPercent = "percent"
+	AdjustAmount  = "amount"
+)
+
+// PricingRule adjusts seat prices for the shows matching all of its
+// conditions. ValidFrom and ValidTo are inclusive YYYY-MM-DD show dates;
+// empty means unbounded.
+type PricingRule struct {
+	ID         int64             `json:"id"`
+	Name       string            `json:"name"`
+	Priority   int32             `json:"priority"`
+	Conditions PricingConditions `json:"conditions"`
+	Adjustment string            `json:"adjustment"`
+	Amount     int64             `json:"amount"`
+	Stop       bool              `json:"stop"`
+	ValidFrom  string            `json:"valid_from,omitempty"`
+	ValidTo    string            `json:"valid_to,omitempty"`
+	Version    int32             `json:"version"`
+}
+
+// PricingConditions are the optional conditions of a rule. Unset conditions
+// always match. Times are HH:MM wall-clock times of the showtime, and an end
+// before the start wraps past midnight. Occupancy is the percentage of the
+// show's seats already booked.
+type PricingConditions struct {
+	Weekdays      []string `json:"weekdays,omitempty"`
+	StartTime     string   `json:"start_time,omitempty"`
+	EndTime       string   `json:"end_time,omitempty"`
+	Holidays      []string `json:"holidays,omitempty"`
+	MinOccupancy  *int     `json:"min_occupancy,omitempty"`
+	MaxOccupancy  *int     `json:"max_occupancy,omitempty"`
+	MinDaysBefore *int     `json:"min_days_before,omitempty"`
+	MaxDaysBefore *int     `json:"max_days_before,omitempty"`
+}
+
+// SeatPrice is the price of a seat for a show. It is the category price when
+// read from the catalogue and the final price once held in a reservation.
+type SeatPrice struct {
+	SeatId     int64  `json:"seat_id"`
+	Category   string `json:"category"`
+	TicketType string `json:"ticket_type"`
+	Price      int64  `json:"price"`
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+// PromoCode discounts a booking. DiscountType is AdjustPercent or
+// AdjustAmount. Empty restriction lists apply to every movie, theatre or
+// weekday, and nil limits are unlimited. ValidFrom and ValidTo are inclusive
+// YYYY-MM-DD booking dates.
+type PromoCode struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Code          string    `json:"code"`
+	Description   string    `json:"description,omitempty"`
+	DiscountType  string    `json:"discount_type"`
+	DiscountValue int64     `json:"discount_value"`
+	MinSpend      int64     `json:"min_spend"`
+	MaxDiscount   *int64    `json:"max_discount,omitempty"`
+	ValidFrom     string    `json:"valid_from,omitempty"`
+	ValidTo       string    `json:"valid_to,omitempty"`
+	MovieIds      []int64   `json:"movie_ids"`
+	TheatreIds    []int64   `json:"theatre_ids"`
+	Weekdays      []string  `json:"weekdays"`
+	UsageLimit    *int32    `json:"usage_limit,omitempty"`
+	PerUserLimit  *int32    `json:"per_user_limit,omitempty"`
+	TimesUsed     int32     `json:"times_used"`
+	Active        bool      `json:"active"`
+	Version       int32     `json:"version"`
+}
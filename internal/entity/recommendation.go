@@ -0,0 +1,10 @@
+package entity
+
+type Recommendation struct {
+	Movie             *Movie  `json:"movie"`
+	Score             float64 `json:"score"`
+	GenreScore        float64 `json:"genre_score"`
+	PeopleScore       float64 `json:"people_score"`
+	SimilarUsersScore float64 `json:"similar_users_score"`
+	Shows             []*Show `json:"shows"`
+}
@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+const (
+	ResaleListed    = "listed"
+	ResaleReserved  = "reserved"
+	ResaleSold      = "sold"
+	ResaleWithdrawn = "withdrawn"
+)
+
+// ResaleListing offers one seat of a paid reservation to other users at no
+// more than its FaceValue. A listing is reserved while a buyer pays for it.
+// Once sold the seat moves to NewReservationId and the seller's wallet is
+// credited with Price less Fee.
+type ResaleListing struct {
+	ID               int64      `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ReservationId    int64      `json:"reservation_id"`
+	SellerId         int64      `json:"seller_id"`
+	ShowId           int64      `json:"show_id"`
+	SeatId           int64      `json:"seat_id"`
+	Row              string     `json:"row"`
+	Number           int32      `json:"number"`
+	Category         string     `json:"category"`
+	TicketType       string     `json:"ticket_type"`
+	FaceValue        int64      `json:"face_value"`
+	Price            int64      `json:"price"`
+	Fee              int64      `json:"fee,omitempty"`
+	Status           string     `json:"status"`
+	BuyerId          *int64     `json:"buyer_id,omitempty"`
+	NewReservationId *int64     `json:"new_reservation_id,omitempty"`
+	SoldAt           *time.Time `json:"sold_at,omitempty"`
+	Version          int32      `json:"-"`
+}
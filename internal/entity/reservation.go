@@ -3,10 +3,23 @@ package entity
 import "time"
 
 type Reservation struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Amount    int64     `json:"amount"`
-	Status    string    `json:"status"`
-	UserId    int64     `json:"user_id"`
-	ShowId    int64     `json:"show_id"`
+	ID                int64             `json:"id"`
+	CreatedAt         time.Time         `json:"created_at"`
+	Amount            int64             `json:"amount"`
+	Discount          int64             `json:"discount,omitempty"`
+	WalletAmount      int64             `json:"wallet_amount,omitempty"`
+	PointsRedeemed    int64             `json:"points_redeemed,omitempty"`
+	PointsDiscount    int64             `json:"points_discount,omitempty"`
+	PointsEarned      int64             `json:"points_earned,omitempty"`
+	ConcessionsAmount int64             `json:"concessions_amount,omitempty"`
+	PickupCode        string            `json:"pickup_code,omitempty"`
+	Status            string            `json:"status"`
+	UserId            int64             `json:"user_id"`
+	ShowId            int64             `json:"show_id"`
+	CheckedInAt       *time.Time        `json:"checked_in_at,omitempty"`
+	TicketToken       string            `json:"ticket_token,omitempty"`
+	TransferredFrom   *int64            `json:"transferred_from,omitempty"`
+	ClientIP          string            `json:"-"`
+	Tickets           []*Ticket         `json:"tickets,omitempty"`
+	Concessions       []*ConcessionItem `json:"concessions,omitempty"`
 }
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+const (
+	ReviewVisible = "visible"
+	ReviewFlagged = "flagged"
+	ReviewHidden  = "hidden"
+)
+
+const (
+	ModerationHide   = "hide"
+	ModerationUnhide = "unhide"
+	ModerationFlag   = "flag"
+	ModerationDelete = "delete"
+)
+
+type Review struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	MovieId   int64     `json:"movie_id"`
+	UserId    int64     `json:"user_id"`
+	UserName  string    `json:"user_name,omitempty"`
+	Rating    int32     `json:"rating"`
+	Body      string    `json:"body,omitempty"`
+	Status    string    `json:"status"`
+	Version   int32     `json:"version"`
+}
+
+type ReviewModeration struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ReviewId    int64     `json:"review_id"`
+	ModeratorId *int64    `json:"moderator_id"`
+	Action      string    `json:"action"`
+	Reason      string    `json:"reason,omitempty"`
+}
@@ -1,7 +1,9 @@
 package entity
 
 type Screen struct {
-	ID         int64 `json:"id"`
-	Number     int32 `json:"number"`
-	Theatre_id int64 `json:"theatre_id"`
+	ID         int64    `json:"id"`
+	Number     int32    `json:"number"`
+	Theatre_id int64    `json:"theatre_id"`
+	Formats    []string `json:"formats"`
+	Version    int32    `json:"version"`
 }
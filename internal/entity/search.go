@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+const (
+	SearchMovie   = "movie"
+	SearchTheatre = "theatre"
+	SearchPerson  = "person"
+	SearchShow    = "show"
+)
+
+type SearchResult struct {
+	Type      string     `json:"type"`
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Subtitle  string     `json:"subtitle,omitempty"`
+	Highlight string     `json:"highlight"`
+	Showtime  *time.Time `json:"showtime,omitempty"`
+	Rank      float64    `json:"rank"`
+}
@@ -4,6 +4,8 @@ type Seat struct {
 	ID        int64  `json:"id"`
 	Row       string `json:"row"`
 	Number    int32  `json:"number"`
-	Price     int32  `json:"price"`
+	Category  string `json:"category"`
+	Price     int32  `json:"price,omitempty"`
 	Screen_id int64  `json:"screen_id"`
+	Version   int32  `json:"version"`
 }
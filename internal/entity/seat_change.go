@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+const (
+	SeatChangePending  = "pending"
+	SeatChangeSuccess  = "success"
+	SeatChangeExpired  = "expired"
+	SeatChangeRefunded = "refunded"
+)
+
+// SeatChange moves tickets of a paid reservation to other seats of the same
+// show. Amount is the price difference: positive amounts are paid before the
+// change is applied, negative ones are refunded to the wallet.
+type SeatChange struct {
+	ID            int64             `json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ReservationId int64             `json:"reservation_id"`
+	Items         []*SeatChangeItem `json:"items"`
+	Amount        int64             `json:"amount"`
+	Status        string            `json:"status"`
+}
+
+// SeatChangeItem moves one ticket, keeping its ticket type. Price is what the
+// ticket costs on the new seat.
+type SeatChangeItem struct {
+	FromSeatId int64  `json:"from_seat_id"`
+	ToSeatId   int64  `json:"to_seat_id"`
+	TicketType string `json:"ticket_type"`
+	Price      int64  `json:"price"`
+}
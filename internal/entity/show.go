@@ -3,8 +3,12 @@ package entity
 import "time"
 
 type Show struct {
-	ID       int64     `json:"id"`
-	Showtime time.Time `json:"showtime"`
-	MovieId  int64     `json:"movie_id"`
-	ScreenId int64     `json:"screen_id"`
+	ID               int64     `json:"id"`
+	Showtime         time.Time `json:"showtime"`
+	MovieId          int64     `json:"movie_id"`
+	ScreenId         int64     `json:"screen_id"`
+	Format           string    `json:"format"`
+	AudioLanguage    string    `json:"audio_language,omitempty"`
+	SubtitleLanguage string    `json:"subtitle_language,omitempty"`
+	Version          int32     `json:"version"`
 }
@@ -1,7 +1,25 @@
 package entity
 
 type Theatres struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
-	City string `json:"city"`
+	ID           int64             `json:"id"`
+	Name         string            `json:"name"`
+	City         string            `json:"city"`
+	Address      string            `json:"address,omitempty"`
+	Latitude     *float64          `json:"latitude,omitempty"`
+	Longitude    *float64          `json:"longitude,omitempty"`
+	Phone        string            `json:"phone,omitempty"`
+	OpeningHours map[string]string `json:"opening_hours,omitempty"`
+	Amenities    []string          `json:"amenities,omitempty"`
+	Version      int32             `json:"version"`
+}
+
+// Weekdays are the keys of Theatres.OpeningHours. Values look like
+// "09:00-23:30"; a closing time before the opening time means the theatre
+// closes after midnight.
+var Weekdays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+type NearbyTheatre struct {
+	Theatres
+	DistanceKm float64 `json:"distance_km"`
+	NextShows  []*Show `json:"next_shows,omitempty"`
 }
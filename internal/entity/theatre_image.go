@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+type TheatreImage struct {
+	ID           int64     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	TheatreId    int64     `json:"theatre_id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
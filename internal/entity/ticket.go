@@ -0,0 +1,35 @@
+package entity
+
+const (
+	TicketAdult   = "adult"
+	TicketChild   = "child"
+	TicketStudent = "student"
+	TicketSenior  = "senior"
+)
+
+// TicketTypes are the kinds of tickets a seat can be sold as. Their
+// eligibility rules are enforced when booking, so the codes are fixed.
+var TicketTypes = []string{TicketAdult, TicketChild, TicketStudent, TicketSenior}
+
+// TicketType adjusts the seat price for who is sitting in it. Adjustment is
+// AdjustPercent or AdjustAmount, like a pricing rule.
+type TicketType struct {
+	Code       string `json:"code"`
+	Name       string `json:"name"`
+	Adjustment string `json:"adjustment"`
+	Amount     int64  `json:"amount"`
+	Version    int32  `json:"version,omitempty"`
+}
+
+// Ticket is a seat held by a reservation with the price it was sold at.
+// PaidPrice is what was actually paid for it once the reservation's discounts
+// are shared out, and stays with the ticket when it is transferred.
+type Ticket struct {
+	SeatId     int64  `json:"seat_id"`
+	Row        string `json:"row"`
+	Number     int32  `json:"number"`
+	Category   string `json:"category"`
+	TicketType string `json:"ticket_type"`
+	Price      int64  `json:"price"`
+	PaidPrice  int64  `json:"paid_price"`
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
+// TicketTransfer hands seats of a paid reservation to another user. Once the
+// recipient accepts, the seats move to NewReservationId, a reservation of
+// their own, and both reservations get a new ticket token.
+type TicketTransfer struct {
+	ID               int64      `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ReservationId    int64      `json:"reservation_id"`
+	FromUserId       int64      `json:"from_user_id"`
+	ToUserId         int64      `json:"to_user_id"`
+	SeatIds          []int64    `json:"seat_ids"`
+	Status           string     `json:"status"`
+	NewReservationId *int64     `json:"new_reservation_id,omitempty"`
+	RespondedAt      *time.Time `json:"responded_at,omitempty"`
+}
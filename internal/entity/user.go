@@ -8,13 +8,16 @@ import (
 )
 
 type User struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  Password  `json:"-"`
-	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
+	ID              int64      `json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        Password   `json:"-"`
+	Activated       bool       `json:"activated"`
+	DateOfBirth     *time.Time `json:"date_of_birth,omitempty"`
+	DobVerified     bool       `json:"dob_verified"`
+	StudentVerified bool       `json:"student_verified"`
+	Version         int        `json:"-"`
 }
 
 var AnonymousUser = &User{}
@@ -23,6 +26,22 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// AgeAt returns the user's age in whole years at t, or -1 if the date of
+// birth is unknown.
+func (u *User) AgeAt(t time.Time) int {
+	if u.DateOfBirth == nil {
+		return -1
+	}
+
+	dob := *u.DateOfBirth
+	age := t.Year() - dob.Year()
+	if t.Month() < dob.Month() || (t.Month() == dob.Month() && t.Day() < dob.Day()) {
+		age--
+	}
+
+	return age
+}
+
 type Password struct {
 	Plaintext *string
 	Hash      []byte
@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+const (
+	GiftCardPending  = "pending"
+	GiftCardActive   = "active"
+	GiftCardRedeemed = "redeemed"
+)
+
+// GiftCard is credited to the wallet of the user redeeming it. Cards bought
+// by customers stay pending until ZaloPay confirms the payment. ExpiresAt is
+// the last YYYY-MM-DD day the card can be redeemed.
+type GiftCard struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Code        string     `json:"code"`
+	Amount      int64      `json:"amount"`
+	Status      string     `json:"status"`
+	PurchasedBy *int64     `json:"purchased_by,omitempty"`
+	RedeemedBy  *int64     `json:"redeemed_by,omitempty"`
+	RedeemedAt  *time.Time `json:"redeemed_at,omitempty"`
+	ExpiresAt   string     `json:"expires_at,omitempty"`
+	Version     int32      `json:"-"`
+}
+
+// Ledger accounts. Money moves between a user's wallet and one of the
+// counter accounts: gift card credit on redemption, sales when paying for
+// reservations.
+const (
+	AccountWallet    = "wallet"
+	AccountGiftCards = "gift_cards"
+	AccountSales     = "sales"
+)
+
+const (
+	WalletGiftCard = "gift_card"
+	WalletPayment  = "payment"
+	WalletRefund   = "refund"
+	WalletResale   = "resale"
+)
+
+type Wallet struct {
+	UserId  int64 `json:"user_id"`
+	Balance int64 `json:"balance"`
+}
+
+// WalletTransaction is one movement of a wallet. Amount is signed, credits
+// are positive, and Balance is the wallet balance after it.
+type WalletTransaction struct {
+	ID        int64          `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UserId    int64          `json:"user_id"`
+	Kind      string         `json:"kind"`
+	Reference string         `json:"reference,omitempty"`
+	Amount    int64          `json:"amount"`
+	Balance   int64          `json:"balance"`
+	Entries   []*WalletEntry `json:"entries,omitempty"`
+}
+
+type WalletEntry struct {
+	Account string `json:"account"`
+	UserId  *int64 `json:"user_id,omitempty"`
+	Amount  int64  `json:"amount"`
+}
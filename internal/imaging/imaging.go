@@ -0,0 +1,148 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+
+	// MaxPixels guards against decompression bombs: a small file can declare
+	// enormous dimensions and exhaust memory when decoded.
+	MaxPixels = 40_000_000
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrImageTooLarge     = errors.New("image dimensions are too large")
+)
+
+var contentTypes = map[string]string{
+	"image/jpeg": FormatJPEG,
+	"image/png":  FormatPNG,
+}
+
+// Decode sniffs the content type of data, rejects anything that is not a
+// JPEG or PNG image and returns the decoded image with its format.
+func Decode(data []byte) (image.Image, string, error) {
+	format, ok := contentTypes[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	var img image.Image
+
+	switch format {
+	case FormatJPEG:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case FormatPNG:
+		img, err = png.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, "", ErrUnsupportedFormat
+	}
+
+	return img, format, nil
+}
+
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case FormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case FormatPNG:
+		return png.Encode(w, img)
+	default:
+		return ErrUnsupportedFormat
+	}
+}
+
+func Extension(format string) string {
+	switch format {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatPNG:
+		return ".png"
+	default:
+		return ""
+	}
+}
+
+// Thumbnail scales img down so that it fits within maxWidth x maxHeight while
+// keeping its aspect ratio. Images that already fit are returned unchanged.
+// Each destination pixel is the average of the source pixels it covers, which
+// is cheap and avoids the aliasing of nearest-neighbour sampling.
+func Thumbnail(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	if srcW <= maxWidth && srcH <= maxHeight {
+		return img
+	}
+
+	dstW, dstH := maxWidth, srcH*maxWidth/srcW
+	if dstH > maxHeight {
+		dstW, dstH = srcW*maxHeight/srcH, maxHeight
+	}
+	if dstW < 1 {
+		dstW = 1
+	}
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := bounds.Min.Y + (y+1)*srcH/dstH
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := bounds.Min.X + (x+1)*srcW/dstW
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(b / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
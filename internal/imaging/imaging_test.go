@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailKeepsAspectRatio(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 600))
+
+	thumb := Thumbnail(img, 300, 300)
+
+	assert.Equal(t, 300, thumb.Bounds().Dx())
+	assert.Equal(t, 150, thumb.Bounds().Dy())
+}
+
+func TestThumbnailSmallImageUnchanged(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 80))
+
+	thumb := Thumbnail(img, 300, 300)
+
+	assert.Equal(t, img, thumb)
+}
+
+func TestDecodePNG(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(0, 0, color.White)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	decoded, format, err := Decode(buf.Bytes())
+
+	require.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+	assert.Equal(t, 4, decoded.Bounds().Dx())
+}
+
+func TestDecodeRejectsNonImage(t *testing.T) {
+	_, _, err := Decode([]byte("<html><body>not an image</body></html>"))
+
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
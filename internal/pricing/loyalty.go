@@ -0,0 +1,97 @@
+package pricing
+
+import (
+	"errors"
+	"sort"
+
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+const (
+	// SpendPerPoint is the spend in VND that earns one point at a 100%
+	// tier multiplier.
+	SpendPerPoint = 1000
+	// PointValue is the discount in VND a redeemed point is worth.
+	PointValue = 10
+	// FreeTicketPoints is the cost in points of one free ticket.
+	FreeTicketPoints = 10000
+)
+
+var (
+	ErrTooManyFreeTickets = errors.New("more free tickets than tickets booked")
+	ErrPointsExceedTotal  = errors.New("points are worth more than the amount left to pay")
+)
+
+// TierFor returns the tier reached with spend and the tier above it, or nil
+// if spend already reaches the top one. Spend below every tier gets the
+// lowest.
+func TierFor(tiers []*entity.LoyaltyTier, spend int64) (*entity.LoyaltyTier, *entity.LoyaltyTier) {
+	sorted := make([]*entity.LoyaltyTier, len(tiers))
+	copy(sorted, tiers)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].MinSpend < sorted[j].MinSpend
+	})
+
+	if len(sorted) == 0 {
+		return nil, nil
+	}
+
+	current := 0
+	for i, tier := range sorted {
+		if spend >= tier.MinSpend {
+			current = i
+		}
+	}
+
+	if current+1 < len(sorted) {
+		return sorted[current], sorted[current+1]
+	}
+	return sorted[current], nil
+}
+
+// EarnedPoints returns the points a member of tier earns for paying amount.
+func EarnedPoints(amount int64, tier *entity.LoyaltyTier) int64 {
+	multiplier := int64(100)
+	if tier != nil {
+		multiplier = int64(tier.Multiplier)
+	}
+
+	return amount / SpendPerPoint * multiplier / 100
+}
+
+// Redemption works out what redeeming points and free tickets takes off a
+// booking whose quote has lines and of which total is left to pay. Free
+// tickets cover the cheapest tickets first. It returns the discount and the
+// points spent on it.
+func Redemption(lines []*Line, total, points int64, freeTickets int) (int64, int64, error) {
+	if freeTickets > len(lines) {
+		return 0, 0, ErrTooManyFreeTickets
+	}
+
+	prices := make([]int64, len(lines))
+	for i, line := range lines {
+		prices[i] = line.Price
+	}
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i] < prices[j]
+	})
+
+	var discount, spent int64
+
+	for _, price := range prices[:freeTickets] {
+		discount += price
+		spent += FreeTicketPoints
+	}
+
+	if discount > total {
+		discount = total
+	}
+
+	if discount+points*PointValue > total {
+		return 0, 0, ErrPointsExceedTotal
+	}
+
+	return discount + points*PointValue, spent + points, nil
+}
@@ -0,0 +1,67 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+var tiers = []*entity.LoyaltyTier{
+	{Code: entity.TierDiamond, MinSpend: 10000000, Multiplier: 150},
+	{Code: entity.TierSilver, MinSpend: 0, Multiplier: 100},
+	{Code: entity.TierGold, MinSpend: 4000000, Multiplier: 125},
+}
+
+func TestTierFor(t *testing.T) {
+	tests := []struct {
+		spend int64
+		tier  string
+		next  string
+	}{
+		{0, entity.TierSilver, entity.TierGold},
+		{3999999, entity.TierSilver, entity.TierGold},
+		{4000000, entity.TierGold, entity.TierDiamond},
+		{25000000, entity.TierDiamond, ""},
+	}
+
+	for _, tt := range tests {
+		tier, next := TierFor(tiers, tt.spend)
+
+		assert.Equal(t, tt.tier, tier.Code)
+		if tt.next == "" {
+			assert.Nil(t, next)
+		} else {
+			assert.Equal(t, tt.next, next.Code)
+		}
+	}
+}
+
+func TestEarnedPointsAppliesTierMultiplier(t *testing.T) {
+	assert.Equal(t, int64(180), EarnedPoints(180500, tiers[1]))
+	assert.Equal(t, int64(270), EarnedPoints(180500, tiers[0]))
+	assert.Equal(t, int64(180), EarnedPoints(180500, nil))
+}
+
+func TestRedemptionFreeTicketsCoverCheapestFirst(t *testing.T) {
+	lines := []*Line{{Price: 150000}, {Price: 90000}, {Price: 120000}}
+
+	discount, spent, err := Redemption(lines, 360000, 500, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(90000+120000+5000), discount)
+	assert.Equal(t, int64(2*FreeTicketPoints+500), spent)
+}
+
+func TestRedemptionRejections(t *testing.T) {
+	lines := []*Line{{Price: 90000}}
+
+	_, _, err := Redemption(lines, 90000, 0, 2)
+	assert.ErrorIs(t, err, ErrTooManyFreeTickets)
+
+	_, _, err = Redemption(lines, 90000, 9001, 0)
+	assert.ErrorIs(t, err, ErrPointsExceedTotal)
+
+	_, _, err = Redemption(lines, 50000, 1, 1)
+	assert.ErrorIs(t, err, ErrPointsExceedTotal)
+}
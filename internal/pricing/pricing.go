@@ -0,0 +1,223 @@
+package pricing
+
+import (
+	"sort"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	timeLayout = "15:04"
+)
+
+// Context is what rules are evaluated against. Occupancy is the percentage
+// of the show's seats already booked. Location is the cinema's time zone,
+// which dates, weekdays and time bands are read in; UTC is used if it is
+// nil.
+type Context struct {
+	Showtime  time.Time
+	Now       time.Time
+	Occupancy float64
+	Location  *time.Location
+}
+
+// localShowtime returns the showtime on the cinema's clock.
+func (c Context) localShowtime() time.Time {
+	return inLocation(c.Showtime, c.Location)
+}
+
+// inLocation returns t on the clock of loc, or of UTC if loc is nil.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return t.UTC()
+	}
+	return t.In(loc)
+}
+
+// DaysBefore is the number of whole days left until the showtime.
+func (c Context) DaysBefore() int {
+	if !c.Showtime.After(c.Now) {
+		return 0
+	}
+	return int(c.Showtime.Sub(c.Now) / (24 * time.Hour))
+}
+
+type Line struct {
+	SeatId     int64    `json:"seat_id,omitempty"`
+	Category   string   `json:"category"`
+	TicketType string   `json:"ticket_type,omitempty"`
+	Base       int64    `json:"base"`
+	Surcharge  int64    `json:"surcharge"`
+	Price      int64    `json:"price"`
+	Rules      []string `json:"rules,omitempty"`
+}
+
+type Quote struct {
+	Lines []*Line `json:"lines"`
+	Total int64   `json:"total"`
+}
+
+// Engine prices seats with an ordered set of rules. Rules with a higher
+// priority are applied first, ties are broken by id, and every matching rule
+// adjusts the price left by the previous one until a rule with Stop set
+// matches.
+type Engine struct {
+	rules []*entity.PricingRule
+}
+
+func New(rules []*entity.PricingRule) *Engine {
+	sorted := make([]*entity.PricingRule, len(rules))
+	copy(sorted, rules)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return &Engine{rules: sorted}
+}
+
+// Price applies the matching rules to base and returns the adjusted price
+// with the names of the rules that were applied. Prices never go below 0.
+func (e *Engine) Price(base int64, c Context) (int64, []string) {
+	price := base
+	applied := []string{}
+
+	for _, rule := range e.rules {
+		if !Matches(rule, c) {
+			continue
+		}
+
+		price = adjust(price, rule.Adjustment, rule.Amount)
+		applied = append(applied, rule.Name)
+
+		if rule.Stop {
+			break
+		}
+	}
+
+	return price, applied
+}
+
+// Quote prices each seat: the rules adjust the category price, the modifier
+// of the seat's ticket type adjusts the result and the per-seat format
+// surcharge is added on top. Seats whose ticket type is missing from
+// ticketTypes get no modifier.
+func (e *Engine) Quote(seats []*entity.SeatPrice, ticketTypes map[string]*entity.TicketType, surcharge int64, c Context) *Quote {
+	quote := &Quote{Lines: make([]*Line, 0, len(seats))}
+
+	for _, seat := range seats {
+		price, applied := e.Price(seat.Price, c)
+
+		if ticketType, ok := ticketTypes[seat.TicketType]; ok {
+			price = adjust(price, ticketType.Adjustment, ticketType.Amount)
+		}
+
+		line := &Line{
+			SeatId:     seat.SeatId,
+			Category:   seat.Category,
+			TicketType: seat.TicketType,
+			Base:       seat.Price,
+			Surcharge:  surcharge,
+			Price:      price + surcharge,
+			Rules:      applied,
+		}
+
+		quote.Lines = append(quote.Lines, line)
+		quote.Total += line.Price
+	}
+
+	return quote
+}
+
+// Matches reports whether rule is in effect on the show's date and all of its
+// conditions hold.
+func Matches(rule *entity.PricingRule, c Context) bool {
+	showtime := c.localShowtime()
+	date := showtime.Format(dateLayout)
+
+	if rule.ValidFrom != "" && date < rule.ValidFrom {
+		return false
+	}
+	if rule.ValidTo != "" && date > rule.ValidTo {
+		return false
+	}
+
+	cond := rule.Conditions
+
+	if len(cond.Weekdays) > 0 && !contains(cond.Weekdays, weekday(showtime)) {
+		return false
+	}
+
+	if len(cond.Holidays) > 0 && !contains(cond.Holidays, date) {
+		return false
+	}
+
+	if (cond.StartTime != "" || cond.EndTime != "") && !inTimeBand(showtime.Format(timeLayout), cond.StartTime, cond.EndTime) {
+		return false
+	}
+
+	if cond.MinOccupancy != nil && c.Occupancy < float64(*cond.MinOccupancy) {
+		return false
+	}
+	if cond.MaxOccupancy != nil && c.Occupancy > float64(*cond.MaxOccupancy) {
+		return false
+	}
+
+	days := c.DaysBefore()
+
+	if cond.MinDaysBefore != nil && days < *cond.MinDaysBefore {
+		return false
+	}
+	if cond.MaxDaysBefore != nil && days > *cond.MaxDaysBefore {
+		return false
+	}
+
+	return true
+}
+
+func adjust(price int64, adjustment string, amount int64) int64 {
+	switch adjustment {
+	case entity.AdjustPercent:
+		price = (price*(100+amount) + 50) / 100
+	case entity.AdjustAmount:
+		price += amount
+	}
+
+	if price < 0 {
+		return 0
+	}
+	return price
+}
+
+// inTimeBand reports whether clock falls in [start, end). A missing bound is
+// open and an end before the start wraps past midnight.
+func inTimeBand(clock, start, end string) bool {
+	switch {
+	case start == "":
+		return clock < end
+	case end == "":
+		return clock >= start
+	case start <= end:
+		return clock >= start && clock < end
+	default:
+		return clock >= start || clock < end
+	}
+}
+
+func weekday(t time.Time) string {
+	return entity.Weekdays[(int(t.Weekday())+6)%7]
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// Saturday 2026-10-24, evening show booked five days ahead.
+var saturdayEvening = Context{
+	Showtime:  time.Date(2026, 10, 24, 20, 30, 0, 0, time.UTC),
+	Now:       time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+	Occupancy: 40,
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule entity.PricingRule
+		want bool
+	}{
+		{"no conditions", entity.PricingRule{}, true},
+		{"weekend", entity.PricingRule{Conditions: entity.PricingConditions{Weekdays: []string{"sat", "sun"}}}, true},
+		{"weekday", entity.PricingRule{Conditions: entity.PricingConditions{Weekdays: []string{"mon", "tue"}}}, false},
+		{"evening band", entity.PricingRule{Conditions: entity.PricingConditions{StartTime: "18:00", EndTime: "23:00"}}, true},
+		{"matinee band", entity.PricingRule{Conditions: entity.PricingConditions{EndTime: "17:00"}}, false},
+		{"band past midnight", entity.PricingRule{Conditions: entity.PricingConditions{StartTime: "20:00", EndTime: "02:00"}}, true},
+		{"holiday", entity.PricingRule{Conditions: entity.PricingConditions{Holidays: []string{"2026-10-24"}}}, true},
+		{"other holiday", entity.PricingRule{Conditions: entity.PricingConditions{Holidays: []string{"2026-12-25"}}}, false},
+		{"occupancy reached", entity.PricingRule{Conditions: entity.PricingConditions{MinOccupancy: intPtr(40)}}, true},
+		{"occupancy not reached", entity.PricingRule{Conditions: entity.PricingConditions{MinOccupancy: intPtr(80)}}, false},
+		{"early booking", entity.PricingRule{Conditions: entity.PricingConditions{MinDaysBefore: intPtr(3)}}, true},
+		{"last minute", entity.PricingRule{Conditions: entity.PricingConditions{MaxDaysBefore: intPtr(1)}}, false},
+		{"within validity", entity.PricingRule{ValidFrom: "2026-10-01", ValidTo: "2026-10-24"}, true},
+		{"expired", entity.PricingRule{ValidTo: "2026-10-23"}, false},
+		{"not yet valid", entity.PricingRule{ValidFrom: "2026-10-25"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Matches(&tt.rule, saturdayEvening))
+		})
+	}
+}
+
+func TestMatchesInCinemaTimeZone(t *testing.T) {
+	saigon := time.FixedZone("ICT", 7*60*60)
+
+	// 2026-10-24 18:30 UTC is Sunday 01:30 in Saigon.
+	c := Context{
+		Showtime: time.Date(2026, 10, 24, 18, 30, 0, 0, time.UTC),
+		Now:      time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		Location: saigon,
+	}
+
+	tests := []struct {
+		name string
+		rule entity.PricingRule
+		want bool
+	}{
+		{"local weekday", entity.PricingRule{Conditions: entity.PricingConditions{Weekdays: []string{"sun"}}}, true},
+		{"utc weekday", entity.PricingRule{Conditions: entity.PricingConditions{Weekdays: []string{"sat"}}}, false},
+		{"local late band", entity.PricingRule{Conditions: entity.PricingConditions{StartTime: "22:00", EndTime: "03:00"}}, true},
+		{"utc evening band", entity.PricingRule{Conditions: entity.PricingConditions{StartTime: "17:00", EndTime: "20:00"}}, false},
+		{"local holiday", entity.PricingRule{Conditions: entity.PricingConditions{Holidays: []string{"2026-10-25"}}}, true},
+		{"local validity", entity.PricingRule{ValidTo: "2026-10-24"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Matches(&tt.rule, c))
+		})
+	}
+}
+
+func TestPriceAppliesRulesInPriorityOrder(t *testing.T) {
+	rules := []*entity.PricingRule{
+		{ID: 1, Name: "weekend", Priority: 10, Adjustment: entity.AdjustPercent, Amount: 20,
+			Conditions: entity.PricingConditions{Weekdays: []string{"sat", "sun"}}},
+		{ID: 2, Name: "early bird", Priority: 1, Adjustment: entity.AdjustAmount, Amount: -10000,
+			Conditions: entity.PricingConditions{MinDaysBefore: intPtr(3)}},
+		{ID: 3, Name: "weekday", Priority: 5, Adjustment: entity.AdjustPercent, Amount: -50,
+			Conditions: entity.PricingConditions{Weekdays: []string{"mon"}}},
+	}
+
+	price, applied := New(rules).Price(100000, saturdayEvening)
+
+	assert.Equal(t, int64(110000), price)
+	assert.Equal(t, []string{"weekend", "early bird"}, applied)
+}
+
+func TestPriceStopsAtStopRule(t *testing.T) {
+	rules := []*entity.PricingRule{
+		{ID: 1, Name: "flat", Priority: 1, Adjustment: entity.AdjustAmount, Amount: 5000},
+		{ID: 2, Name: "holiday", Priority: 9, Adjustment: entity.AdjustPercent, Amount: 50, Stop: true},
+	}
+
+	price, applied := New(rules).Price(80000, saturdayEvening)
+
+	assert.Equal(t, int64(120000), price)
+	assert.Equal(t, []string{"holiday"}, applied)
+}
+
+func TestPriceNeverNegative(t *testing.T) {
+	rules := []*entity.PricingRule{
+		{ID: 1, Name: "free", Adjustment: entity.AdjustAmount, Amount: -200000},
+	}
+
+	price, _ := New(rules).Price(80000, saturdayEvening)
+
+	assert.Equal(t, int64(0), price)
+}
+
+func TestQuoteAddsSurchargeAfterRules(t *testing.T) {
+	rules := []*entity.PricingRule{
+		{ID: 1, Name: "weekend", Adjustment: entity.AdjustPercent, Amount: 10},
+	}
+	seats := []*entity.SeatPrice{
+		{SeatId: 1, Category: "standard", Price: 90000},
+		{SeatId: 2, Category: "vip", Price: 120000},
+	}
+
+	quote := New(rules).Quote(seats, nil, 30000, saturdayEvening)
+
+	assert.Len(t, quote.Lines, 2)
+	assert.Equal(t, int64(129000), quote.Lines[0].Price)
+	assert.Equal(t, int64(162000), quote.Lines[1].Price)
+	assert.Equal(t, int64(291000), quote.Total)
+}
+
+func TestQuoteAppliesTicketTypeBeforeSurcharge(t *testing.T) {
+	rules := []*entity.PricingRule{
+		{ID: 1, Name: "weekend", Adjustment: entity.AdjustPercent, Amount: 10},
+	}
+	ticketTypes := map[string]*entity.TicketType{
+		entity.TicketAdult: {Code: entity.TicketAdult, Adjustment: entity.AdjustPercent, Amount: 0},
+		entity.TicketChild: {Code: entity.TicketChild, Adjustment: entity.AdjustPercent, Amount: -50},
+	}
+	seats := []*entity.SeatPrice{
+		{SeatId: 1, Category: "standard", TicketType: entity.TicketAdult, Price: 100000},
+		{SeatId: 2, Category: "standard", TicketType: entity.TicketChild, Price: 100000},
+	}
+
+	quote := New(rules).Quote(seats, ticketTypes, 30000, saturdayEvening)
+
+	assert.Equal(t, int64(140000), quote.Lines[0].Price)
+	assert.Equal(t, int64(85000), quote.Lines[1].Price)
+	assert.Equal(t, entity.TicketChild, quote.Lines[1].TicketType)
+	assert.Equal(t, int64(225000), quote.Total)
+}
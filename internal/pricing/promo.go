@@ -0,0 +1,97 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+var (
+	ErrPromoInactive      = errors.New("this code is no longer active")
+	ErrPromoNotStarted    = errors.New("this code is not valid yet")
+	ErrPromoExpired       = errors.New("this code has expired")
+	ErrPromoNotApplicable = errors.New("this code cannot be used for this show")
+)
+
+// Order is the booking a promo code is applied to. Subtotal is the price of
+// all tickets before the discount. Location is the cinema's time zone, which
+// the code's dates and weekdays are read in like those of pricing rules; UTC
+// is used if it is nil.
+type Order struct {
+	MovieId   int64
+	TheatreId int64
+	Showtime  time.Time
+	Now       time.Time
+	Subtotal  int64
+	Location  *time.Location
+}
+
+// MinSpendError reports that an order is below the promo's minimum spend.
+type MinSpendError struct {
+	MinSpend int64
+}
+
+func (e *MinSpendError) Error() string {
+	return fmt.Sprintf("this code requires a minimum spend of %d", e.MinSpend)
+}
+
+// Discount checks that promo applies to order and returns the amount it
+// takes off the subtotal. Usage limits are not checked here since they need
+// the redemption history.
+func Discount(promo *entity.PromoCode, order Order) (int64, error) {
+	if !promo.Active {
+		return 0, ErrPromoInactive
+	}
+
+	today := inLocation(order.Now, order.Location).Format(dateLayout)
+
+	if promo.ValidFrom != "" && today < promo.ValidFrom {
+		return 0, ErrPromoNotStarted
+	}
+	if promo.ValidTo != "" && today > promo.ValidTo {
+		return 0, ErrPromoExpired
+	}
+
+	if len(promo.MovieIds) > 0 && !containsID(promo.MovieIds, order.MovieId) {
+		return 0, ErrPromoNotApplicable
+	}
+	if len(promo.TheatreIds) > 0 && !containsID(promo.TheatreIds, order.TheatreId) {
+		return 0, ErrPromoNotApplicable
+	}
+	if len(promo.Weekdays) > 0 && !contains(promo.Weekdays, weekday(inLocation(order.Showtime, order.Location))) {
+		return 0, ErrPromoNotApplicable
+	}
+
+	if order.Subtotal < promo.MinSpend {
+		return 0, &MinSpendError{MinSpend: promo.MinSpend}
+	}
+
+	var discount int64
+
+	switch promo.DiscountType {
+	case entity.AdjustPercent:
+		discount = (order.Subtotal*promo.DiscountValue + 50) / 100
+	case entity.AdjustAmount:
+		discount = promo.DiscountValue
+	}
+
+	if promo.MaxDiscount != nil && discount > *promo.MaxDiscount {
+		discount = *promo.MaxDiscount
+	}
+	if discount > order.Subtotal {
+		discount = order.Subtotal
+	}
+
+	return discount, nil
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
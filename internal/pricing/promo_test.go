@@ -0,0 +1,111 @@
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+func int64Ptr(i int64) *int64 {
+	return &i
+}
+
+var order = Order{
+	MovieId:   7,
+	TheatreId: 3,
+	Showtime:  saturdayEvening.Showtime,
+	Now:       saturdayEvening.Now,
+	Subtotal:  200000,
+}
+
+func TestDiscountPercentCappedByMaxDiscount(t *testing.T) {
+	promo := &entity.PromoCode{Active: true, DiscountType: entity.AdjustPercent, DiscountValue: 30, MaxDiscount: int64Ptr(50000)}
+
+	discount, err := Discount(promo, order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000), discount)
+}
+
+func TestDiscountAmountNeverExceedsSubtotal(t *testing.T) {
+	promo := &entity.PromoCode{Active: true, DiscountType: entity.AdjustAmount, DiscountValue: 500000}
+
+	discount, err := Discount(promo, order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(200000), discount)
+}
+
+func TestDiscountRejections(t *testing.T) {
+	tests := []struct {
+		name  string
+		promo entity.PromoCode
+		want  error
+	}{
+		{"inactive", entity.PromoCode{}, ErrPromoInactive},
+		{"not started", entity.PromoCode{Active: true, ValidFrom: "2026-10-20"}, ErrPromoNotStarted},
+		{"expired", entity.PromoCode{Active: true, ValidTo: "2026-10-18"}, ErrPromoExpired},
+		{"other movie", entity.PromoCode{Active: true, MovieIds: []int64{8}}, ErrPromoNotApplicable},
+		{"other theatre", entity.PromoCode{Active: true, TheatreIds: []int64{4}}, ErrPromoNotApplicable},
+		{"weekdays only", entity.PromoCode{Active: true, Weekdays: []string{"mon", "tue"}}, ErrPromoNotApplicable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.promo.DiscountType = entity.AdjustAmount
+			tt.promo.DiscountValue = 10000
+
+			_, err := Discount(&tt.promo, order)
+
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestDiscountMinSpend(t *testing.T) {
+	promo := &entity.PromoCode{Active: true, DiscountType: entity.AdjustAmount, DiscountValue: 10000, MinSpend: 250000}
+
+	_, err := Discount(promo, order)
+
+	var minSpend *MinSpendError
+	assert.ErrorAs(t, err, &minSpend)
+	assert.Equal(t, int64(250000), minSpend.MinSpend)
+}
+
+func TestDiscountInCinemaTimeZone(t *testing.T) {
+	saigon := time.FixedZone("ICT", 7*60*60)
+
+	// 2026-10-24 18:30 UTC is Sunday 01:30 in Saigon, booked on 2026-10-19
+	// 20:00 UTC, which is already 2026-10-20 there.
+	o := order
+	o.Showtime = time.Date(2026, 10, 24, 18, 30, 0, 0, time.UTC)
+	o.Now = time.Date(2026, 10, 19, 20, 0, 0, 0, time.UTC)
+	o.Location = saigon
+
+	tests := []struct {
+		name  string
+		promo entity.PromoCode
+		want  error
+	}{
+		{"local weekday", entity.PromoCode{Active: true, Weekdays: []string{"sun"}}, nil},
+		{"utc weekday", entity.PromoCode{Active: true, Weekdays: []string{"sat"}}, ErrPromoNotApplicable},
+		{"local start date", entity.PromoCode{Active: true, ValidFrom: "2026-10-20"}, nil},
+		{"local end date", entity.PromoCode{Active: true, ValidTo: "2026-10-19"}, ErrPromoExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.promo.DiscountType = entity.AdjustAmount
+			tt.promo.DiscountValue = 10000
+
+			_, err := Discount(&tt.promo, o)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
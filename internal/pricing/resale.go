@@ -0,0 +1,31 @@
+package pricing
+
+// ResaleFee returns the fee kept from a resale at price when percent of it
+// goes to the house. Fractions of a dong are rounded down in the seller's
+// favour.
+func ResaleFee(price int64, percent int) int64 {
+	if percent <= 0 {
+		return 0
+	}
+	if percent >= 100 {
+		return price
+	}
+
+	return price * int64(percent) / 100
+}
+
+// ResaleFaceValue returns what was actually paid for a ticket bought at price
+// on a reservation whose tickets came to ticketsTotal before discount was
+// taken off them. The discount is shared between the tickets in proportion
+// to their prices, so a ticket paid for entirely with a discount or points
+// has no face value.
+func ResaleFaceValue(price, ticketsTotal, discount int64) int64 {
+	if ticketsTotal <= 0 || discount <= 0 {
+		return price
+	}
+	if discount >= ticketsTotal {
+		return 0
+	}
+
+	return price * (ticketsTotal - discount) / ticketsTotal
+}
@@ -0,0 +1,49 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResaleFee(t *testing.T) {
+	tests := []struct {
+		price   int64
+		percent int
+		fee     int64
+	}{
+		{100000, 10, 10000},
+		{99999, 10, 9999},
+		{100000, 0, 0},
+		{100000, -5, 0},
+		{100000, 100, 100000},
+		{100000, 150, 100000},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.fee, ResaleFee(tt.price, tt.percent))
+	}
+}
+
+func TestResaleFaceValue(t *testing.T) {
+	tests := []struct {
+		name         string
+		price        int64
+		ticketsTotal int64
+		discount     int64
+		faceValue    int64
+	}{
+		{"no discount", 90000, 180000, 0, 90000},
+		{"discount shared by price", 120000, 200000, 50000, 90000},
+		{"rounded down", 100000, 300000, 100000, 66666},
+		{"fully discounted", 90000, 90000, 90000, 0},
+		{"discount above total", 90000, 90000, 100000, 0},
+		{"no tickets total", 90000, 0, 10000, 90000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.faceValue, ResaleFaceValue(tt.price, tt.ticketsTotal, tt.discount))
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrParentArchived = errors.New("parent record is archived")
+
+// archiveDependants lists, per archive type, the statements that archive or
+// restore the rows hidden together with a record: the shows of a movie, or
+// the screens, seats and shows of a theatre. Each statement takes the SET
+// clause and the deleted_at condition as format arguments.
+var archiveDependants = map[string][]string{
+	entity.ArchiveMovie: {
+		`UPDATE shows SET %s WHERE movie_id = $1 AND %s`,
+	},
+	entity.ArchiveTheatre: {
+		`UPDATE shows SET %s WHERE screen_id IN (SELECT id FROM screens WHERE theatre_id = $1) AND %s`,
+		`UPDATE seats SET %s WHERE screen_id IN (SELECT id FROM screens WHERE theatre_id = $1) AND %s`,
+		`UPDATE screens SET %s WHERE theatre_id = $1 AND %s`,
+	},
+	entity.ArchiveScreen: {
+		`UPDATE shows SET %s WHERE screen_id = $1 AND %s`,
+		`UPDATE seats SET %s WHERE screen_id = $1 AND %s`,
+	},
+}
+
+// archivedParent holds, per archive type, a query reporting whether a record
+// belongs to an archived parent and so cannot be restored on its own.
+var archivedParent = map[string]string{
+	entity.ArchiveScreen: `
+		SELECT t.deleted_at IS NOT NULL
+		FROM screens sc
+		INNER JOIN theatres t ON sc.theatre_id = t.id
+		WHERE sc.id = $1
+	`,
+	entity.ArchiveSeat: `
+		SELECT sc.deleted_at IS NOT NULL
+		FROM seats s
+		INNER JOIN screens sc ON s.screen_id = sc.id
+		WHERE s.id = $1
+	`,
+	entity.ArchiveShow: `
+		SELECT m.deleted_at IS NOT NULL OR sc.deleted_at IS NOT NULL
+		FROM shows s
+		INNER JOIN movies m ON s.movie_id = m.id
+		INNER JOIN screens sc ON s.screen_id = sc.id
+		WHERE s.id = $1
+	`,
+}
+
+// recentlyReserved holds, per archive type, a condition that is true while a
+// record has reservations made after the purge cutoff ($1).
+var recentlyReserved = map[string]string{
+	entity.ArchiveShow: `EXISTS (
+		SELECT 1 FROM reservations r
+		WHERE r.show_id = shows.id AND r.created_at > $1
+	)`,
+	entity.ArchiveSeat: `EXISTS (
+		SELECT 1 FROM reservation_seat rs
+		INNER JOIN reservations r ON rs.reservation_id = r.id
+		WHERE rs.seat_id = seats.id AND r.created_at > $1
+	)`,
+	entity.ArchiveScreen: `EXISTS (
+		SELECT 1 FROM reservations r
+		INNER JOIN shows s ON r.show_id = s.id
+		WHERE s.screen_id = screens.id AND r.created_at > $1
+	)`,
+	entity.ArchiveTheatre: `EXISTS (
+		SELECT 1 FROM reservations r
+		INNER JOIN shows s ON r.show_id = s.id
+		INNER JOIN screens sc ON s.screen_id = sc.id
+		WHERE sc.theatre_id = theatres.id AND r.created_at > $1
+	)`,
+	entity.ArchiveMovie: `EXISTS (
+		SELECT 1 FROM reservations r
+		INNER JOIN shows s ON r.show_id = s.id
+		WHERE s.movie_id = movies.id AND r.created_at > $1
+	)`,
+}
+
+// archive soft-deletes a record and its dependants. Every row archived in one
+// transaction shares the same deleted_at, which is how restore tells them
+// apart from dependants that were archived on their own earlier.
+func archive(ctx context.Context, tx *sql.Tx, archiveType string, id int64) error {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL
+	`, archiveType)
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	for _, stmt := range archiveDependants[archiveType] {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(stmt, "deleted_at = NOW()", "deleted_at IS NULL"), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type ArchiveModel struct {
+	DB *sql.DB
+}
+
+// GetAll lists the archived records of one type, most recently archived
+// first by default.
+func (m ArchiveModel) GetAll(archiveType string, filters Filters) ([]*entity.ArchivedItem, Metadata, error) {
+	names := map[string]string{
+		entity.ArchiveMovie:   `title`,
+		entity.ArchiveTheatre: `name || ', ' || city`,
+		entity.ArchiveScreen:  `'Screen ' || number || ' (theatre ' || theatre_id || ')'`,
+		entity.ArchiveSeat:    `row || number || ' (screen ' || screen_id || ')'`,
+		entity.ArchiveShow:    `'Show of movie ' || movie_id || ' at ' || to_char(showtime, 'YYYY-MM-DD HH24:MI')`,
+	}
+
+	name, ok := names[archiveType]
+	if !ok {
+		return nil, Metadata{}, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, %s, deleted_at
+		FROM %s
+		WHERE deleted_at IS NOT NULL
+		ORDER BY %s %s, id ASC
+		LIMIT $1 OFFSET $2
+	`, name, archiveType, filters.sortColumn(), filters.sortDirection(),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	items := []*entity.ArchivedItem{}
+	for rows.Next() {
+		item := entity.ArchivedItem{Type: archiveType}
+
+		err := rows.Scan(
+			&totalRecords,
+			&item.ID,
+			&item.Name,
+			&item.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return items, metadata, nil
+}
+
+// Restore brings an archived record back together with the dependants that
+// were archived with it. Records whose parent is still archived return
+// ErrParentArchived; restore the parent instead.
+func (m ArchiveModel) Restore(archiveType string, id int64) error {
+	if !validator.In(archiveType, entity.ArchiveTypes...) || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deletedAt time.Time
+
+	query := fmt.Sprintf(`
+		SELECT deleted_at
+		FROM %s
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE
+	`, archiveType)
+
+	err = tx.QueryRowContext(ctx, query, id).Scan(&deletedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if parentQuery, ok := archivedParent[archiveType]; ok {
+		var parentArchived bool
+
+		err = tx.QueryRowContext(ctx, parentQuery, id).Scan(&parentArchived)
+		if err != nil {
+			return err
+		}
+
+		if parentArchived {
+			return ErrParentArchived
+		}
+	}
+
+	query = fmt.Sprintf(`
+		UPDATE %s
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1
+	`, archiveType)
+
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range archiveDependants[archiveType] {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(stmt, "deleted_at = NULL", "deleted_at = $2"), id, deletedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently deletes records archived before the cutoff, cascading to
+// their reservations. Records with reservations made after the cutoff are
+// kept so recent booking history is never lost. With dryRun the counts are
+// reported but nothing is deleted.
+func (m ArchiveModel) Purge(before time.Time, dryRun bool) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Children first, so the counts are not hidden by cascading deletes.
+	order := []string{entity.ArchiveShow, entity.ArchiveSeat, entity.ArchiveScreen, entity.ArchiveTheatre, entity.ArchiveMovie}
+
+	purged := make(map[string]int64, len(order))
+
+	for _, archiveType := range order {
+		query := fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE deleted_at < $1 AND NOT %s
+		`, archiveType, recentlyReserved[archiveType])
+
+		result, err := tx.ExecContext(ctx, query, before)
+		if err != nil {
+			return nil, err
+		}
+
+		purged[archiveType], err = result.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return purged, nil
+	}
+
+	return purged, tx.Commit()
+}
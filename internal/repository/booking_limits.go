@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+type BookingLimitModel struct {
+	DB *sql.DB
+}
+
+// GetForShow returns the limits set for a show. It returns ErrRecordNotFound
+// if the show uses the server's defaults.
+func (m BookingLimitModel) GetForShow(showId int64) (*entity.BookingLimits, error) {
+	query := `
+		SELECT show_id, max_seats_per_user, max_pending_holds
+		FROM show_booking_limits
+		WHERE show_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var limits entity.BookingLimits
+
+	err := m.DB.QueryRowContext(ctx, query, showId).Scan(&limits.ShowId, &limits.MaxSeatsPerUser, &limits.MaxPendingHolds)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &limits, nil
+}
+
+// SetForShow replaces the limits of a show.
+func (m BookingLimitModel) SetForShow(limits *entity.BookingLimits) error {
+	query := `
+		INSERT INTO show_booking_limits (show_id, max_seats_per_user, max_pending_holds)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (show_id) DO UPDATE
+		SET max_seats_per_user = EXCLUDED.max_seats_per_user, max_pending_holds = EXCLUDED.max_pending_holds
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, limits.ShowId, limits.MaxSeatsPerUser, limits.MaxPendingHolds)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	return nil
+}
+
+// DeleteForShow makes a show use the server's default limits again.
+func (m BookingLimitModel) DeleteForShow(showId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `DELETE FROM show_booking_limits WHERE show_id = $1`, showId)
+	return err
+}
+
+// Usage returns what the user and the accounts linked to them already hold
+// for a show. Seats of group bookings count towards the organizer's seats,
+// but the unpaid shares of a group booking are not counted as holds. It
+// locks the user's bookings for the show until tx ends, so that a booking
+// inserted in tx is counted by any other booking checked after it.
+func (m BookingLimitModel) Usage(tx *sql.Tx, userId, showId int64, ip string, window time.Duration) (*entity.BookingUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Keys that collide only make unrelated bookings wait for each other.
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1::bigint # ($2::bigint << 32))`, userId, showId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			(SELECT count(*)
+			 FROM reservation_seat rs
+			 INNER JOIN reservations r ON r.id = rs.reservation_id
+			 WHERE r.user_id = $1 AND r.show_id = $2),
+			(SELECT count(*)
+			 FROM reservations r
+			 WHERE r.user_id = $1 AND r.show_id = $2 AND r.status = 'pending'
+			 AND NOT EXISTS (SELECT 1 FROM group_shares gs WHERE gs.reservation_id = r.id)),
+			(SELECT count(*)
+			 FROM reservation_seat rs
+			 INNER JOIN reservations r ON r.id = rs.reservation_id
+			 WHERE r.show_id = $2 AND r.user_id <> $1
+			 AND r.user_id IN (
+				SELECT o.user_id
+				FROM reservations o
+				INNER JOIN reservations p ON p.payment_identity = o.payment_identity
+				WHERE p.user_id = $1
+			 )),
+			(SELECT count(*)
+			 FROM reservation_seat rs
+			 INNER JOIN reservations r ON r.id = rs.reservation_id
+			 WHERE r.show_id = $2 AND r.client_ip = $3
+			 AND r.created_at > NOW() - make_interval(secs => $4))
+	`
+
+	var usage entity.BookingUsage
+
+	args := []interface{}{userId, showId, ip, window.Seconds()}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&usage.SeatsHeld,
+		&usage.PendingHolds,
+		&usage.LinkedSeats,
+		&usage.IPSeats,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// Flag records that the user tripped a velocity check for a show. An
+// account already flagged for the same show and reason is not flagged again
+// until that flag is resolved.
+func (m BookingLimitModel) Flag(userId, showId int64, reason string) error {
+	query := `
+		INSERT INTO account_flags (user_id, show_id, reason)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1
+			FROM account_flags
+			WHERE user_id = $1 AND show_id = $2 AND reason = $3 AND resolved_at IS NULL
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userId, showId, reason)
+	return err
+}
+
+const accountFlagColumns = `
+	f.id, f.created_at, f.user_id, u.email, f.show_id, f.reason,
+	(SELECT count(*) FROM reservations r WHERE r.user_id = f.user_id AND r.show_id = f.show_id AND r.status = 'pending'
+	 AND NOT EXISTS (SELECT 1 FROM group_shares gs WHERE gs.reservation_id = r.id)),
+	f.resolved_at
+`
+
+func (m BookingLimitModel) GetFlag(id int64) (*entity.AccountFlag, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT ` + accountFlagColumns + `
+		FROM account_flags f
+		INNER JOIN users u ON u.id = f.user_id
+		WHERE f.id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	flag, err := scanAccountFlag(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return flag, nil
+}
+
+// GetFlags returns flagged accounts. Resolved flags are only included when
+// resolved is set.
+func (m BookingLimitModel) GetFlags(resolved bool, filters Filters) ([]*entity.AccountFlag, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), `+accountFlagColumns+`
+		FROM account_flags f
+		INNER JOIN users u ON u.id = f.user_id
+		WHERE (f.resolved_at IS NULL OR $1)
+		ORDER BY f.%s %s, f.id ASC
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, resolved, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	flags := []*entity.AccountFlag{}
+	for rows.Next() {
+		var flag entity.AccountFlag
+
+		err := rows.Scan(
+			&totalRecords,
+			&flag.ID,
+			&flag.CreatedAt,
+			&flag.UserId,
+			&flag.Email,
+			&flag.ShowId,
+			&flag.Reason,
+			&flag.PendingHolds,
+			&flag.ResolvedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		flags = append(flags, &flag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return flags, metadata, nil
+}
+
+// GetPendingHolds returns the ids of the user's unpaid reservations for a
+// show. Unpaid shares of group bookings are left out since they belong to the
+// group.
+func (m BookingLimitModel) GetPendingHolds(userId, showId int64) ([]int64, error) {
+	query := `
+		SELECT r.id
+		FROM reservations r
+		WHERE r.user_id = $1 AND r.show_id = $2 AND r.status = 'pending'
+		AND NOT EXISTS (SELECT 1 FROM group_shares gs WHERE gs.reservation_id = r.id)
+		ORDER BY r.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userId, showId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ResolveFlag closes a flag once an admin has dealt with the account.
+func (m BookingLimitModel) ResolveFlag(flag *entity.AccountFlag) error {
+	query := `
+		UPDATE account_flags
+		SET resolved_at = NOW()
+		WHERE id = $1 AND resolved_at IS NULL
+		RETURNING resolved_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, flag.ID).Scan(&flag.ResolvedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanAccountFlag(row rowScanner) (*entity.AccountFlag, error) {
+	var flag entity.AccountFlag
+
+	err := row.Scan(
+		&flag.ID,
+		&flag.CreatedAt,
+		&flag.UserId,
+		&flag.Email,
+		&flag.ShowId,
+		&flag.Reason,
+		&flag.PendingHolds,
+		&flag.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &flag, nil
+}
+
+func ValidateBookingLimits(v *validator.Validator, limits *entity.BookingLimits) {
+	v.Check(limits.MaxSeatsPerUser > 0, "max_seats_per_user", "must be greater than zero")
+	v.Check(limits.MaxSeatsPerUser <= 100, "max_seats_per_user", "must not be more than 100")
+
+	v.Check(limits.MaxPendingHolds > 0, "max_pending_holds", "must be greater than zero")
+	v.Check(limits.MaxPendingHolds <= 20, "max_pending_holds", "must not be more than 20")
+}
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrOutOfStock = errors.New("concession out of stock")
+
+type ConcessionModel struct {
+	DB *sql.DB
+}
+
+func (m ConcessionModel) Insert(concession *entity.Concession) error {
+	query := `
+		INSERT INTO concessions (theatre_id, name, description, price, stock, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, version
+	`
+
+	args := []interface{}{
+		concession.TheatreId,
+		concession.Name,
+		concession.Description,
+		concession.Price,
+		concession.Stock,
+		concession.Active,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&concession.ID, &concession.CreatedAt, &concession.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m ConcessionModel) Get(id int64) (*entity.Concession, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, theatre_id, name, description, price, stock, active, version
+		FROM concessions
+		WHERE id = $1
+	`
+
+	var concession entity.Concession
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&concession.ID,
+		&concession.CreatedAt,
+		&concession.TheatreId,
+		&concession.Name,
+		&concession.Description,
+		&concession.Price,
+		&concession.Stock,
+		&concession.Active,
+		&concession.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &concession, nil
+}
+
+// GetAllForTheatre returns the theatre's catalog. With ids set only those
+// concessions are returned, and with activeOnly set items taken off sale are
+// left out.
+func (m ConcessionModel) GetAllForTheatre(theatreId int64, ids []int64, activeOnly bool) ([]*entity.Concession, error) {
+	query := `
+		SELECT id, created_at, theatre_id, name, description, price, stock, active, version
+		FROM concessions
+		WHERE theatre_id = $1
+		AND (id = ANY($2) OR cardinality($2::bigint[]) = 0)
+		AND (active OR NOT $3)
+		ORDER BY name ASC, id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, theatreId, pq.Array(ids), activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	concessions := []*entity.Concession{}
+	for rows.Next() {
+		var concession entity.Concession
+
+		err := rows.Scan(
+			&concession.ID,
+			&concession.CreatedAt,
+			&concession.TheatreId,
+			&concession.Name,
+			&concession.Description,
+			&concession.Price,
+			&concession.Stock,
+			&concession.Active,
+			&concession.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		concessions = append(concessions, &concession)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return concessions, nil
+}
+
+func (m ConcessionModel) Update(concession *entity.Concession) error {
+	query := `
+		UPDATE concessions
+		SET name = $1, description = $2, price = $3, stock = $4, active = $5, version = version + 1
+		WHERE id = $6 AND version = $7
+		RETURNING version
+	`
+
+	args := []interface{}{
+		concession.Name,
+		concession.Description,
+		concession.Price,
+		concession.Stock,
+		concession.Active,
+		concession.ID,
+		concession.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&concession.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a concession. Concessions already sold on a reservation
+// cannot be deleted and return ErrViolatesForeignKey; they should be made
+// inactive instead.
+func (m ConcessionModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM concessions
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Reserve takes the items of a reservation inserted in tx out of stock and
+// gives the reservation a pickup code. It returns ErrOutOfStock if any item
+// is short, in which case tx must be rolled back.
+func (m ConcessionModel) Reserve(tx *sql.Tx, reservation *entity.Reservation, items []*entity.ConcessionItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Rows are locked in id order so concurrent bookings cannot deadlock.
+	sorted := make([]*entity.ConcessionItem, len(items))
+	copy(sorted, items)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ConcessionId < sorted[j].ConcessionId
+	})
+
+	stockQuery := `
+		UPDATE concessions
+		SET stock = stock - $2
+		WHERE id = $1 AND active AND stock >= $2
+	`
+
+	insertQuery := `
+		INSERT INTO reservation_concessions (reservation_id, concession_id, quantity, price)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	for _, item := range sorted {
+		result, err := tx.ExecContext(ctx, stockQuery, item.ConcessionId, item.Quantity)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrOutOfStock
+		}
+
+		args := []interface{}{reservation.ID, item.ConcessionId, item.Quantity, item.Price}
+
+		_, err = tx.ExecContext(ctx, insertQuery, args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	code, err := generatePickupCode()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reservations SET pickup_code = $1 WHERE id = $2`, code, reservation.ID)
+	if err != nil {
+		return err
+	}
+
+	reservation.PickupCode = code
+	reservation.Concessions = items
+
+	return nil
+}
+
+// restoreConcessionStock puts the items of a reservation being removed in tx
+// back in stock.
+func restoreConcessionStock(ctx context.Context, tx *sql.Tx, reservationId int64) error {
+	query := `
+		UPDATE concessions c
+		SET stock = c.stock + rc.quantity
+		FROM reservation_concessions rc
+		WHERE rc.reservation_id = $1 AND c.id = rc.concession_id
+	`
+
+	_, err := tx.ExecContext(ctx, query, reservationId)
+	return err
+}
+
+// generatePickupCode returns a short random code staff match at the counter.
+func generatePickupCode() (string, error) {
+	randomBytes := make([]byte, 5)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+func ValidateConcession(v *validator.Validator, concession *entity.Concession) {
+	v.Check(concession.Name != "", "name", "must be provided")
+	v.Check(len(concession.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(concession.Description) <= 500, "description", "must not be more than 500 bytes long")
+
+	v.Check(concession.Price >= 0, "price", "must not be negative")
+	v.Check(concession.Price <= 10000000, "price", "must not be more than 10000000")
+
+	v.Check(concession.Stock >= 0, "stock", "must not be negative")
+}
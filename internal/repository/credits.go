@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+type CreditModel struct {
+	DB *sql.DB
+}
+
+func (m CreditModel) Insert(credit *entity.Credit) error {
+	query := `
+		INSERT INTO movie_credits(movie_id, person_id, role, character, billing_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	args := []interface{}{
+		credit.MovieId,
+		credit.PersonId,
+		credit.Role,
+		credit.Character,
+		credit.BillingOrder,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23503":
+				return ErrViolatesForeignKey
+			case "23505":
+				return ErrDuplicateConstraint
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m CreditModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM movie_credits
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m CreditModel) GetAllForMovie(movieId int64) ([]*entity.Credit, error) {
+	query := `
+		SELECT c.id, c.movie_id, c.person_id, c.role, c.character, c.billing_order, p.name
+		FROM movie_credits c
+		INNER JOIN people p ON c.person_id = p.id
+		WHERE c.movie_id = $1
+		ORDER BY c.billing_order ASC, c.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*entity.Credit{}
+	for rows.Next() {
+		var credit entity.Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieId,
+			&credit.PersonId,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+			&credit.PersonName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetAllForPerson returns the filmography of a person, newest movies first.
+func (m CreditModel) GetAllForPerson(personId int64) ([]*entity.Credit, error) {
+	query := `
+		SELECT c.id, c.movie_id, c.person_id, c.role, c.character, c.billing_order, m.title, m.year
+		FROM movie_credits c
+		INNER JOIN movies m ON c.movie_id = m.id
+		WHERE c.person_id = $1 AND m.deleted_at IS NULL
+		ORDER BY m.year DESC, m.id DESC, c.role ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*entity.Credit{}
+	for rows.Next() {
+		var credit entity.Credit
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieId,
+			&credit.PersonId,
+			&credit.Role,
+			&credit.Character,
+			&credit.BillingOrder,
+			&credit.MovieTitle,
+			&credit.MovieYear,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+func ValidateCredit(v *validator.Validator, credit *entity.Credit) {
+	v.Check(credit.MovieId > 0, "movie_id", "must be a positive integer")
+	v.Check(credit.PersonId > 0, "person_id", "must be a positive integer")
+	v.Check(validator.In(credit.Role, entity.RoleDirector, entity.RoleActor, entity.RoleWriter), "role", "must be one of director, actor or writer")
+	v.Check(len(credit.Character) <= 500, "character", "must not be more than 500 bytes long")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+
+	if credit.Role != entity.RoleActor {
+		v.Check(credit.Character == "", "character", "must only be provided for actors")
+	}
+}
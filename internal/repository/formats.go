@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+type FormatModel struct {
+	DB *sql.DB
+}
+
+func (m FormatModel) GetAll() ([]*entity.Format, error) {
+	query := `
+		SELECT code, name, surcharge, version
+		FROM formats
+		ORDER BY surcharge ASC, code ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	formats := []*entity.Format{}
+	for rows.Next() {
+		var format entity.Format
+
+		err := rows.Scan(
+			&format.Code,
+			&format.Name,
+			&format.Surcharge,
+			&format.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		formats = append(formats, &format)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return formats, nil
+}
+
+func (m FormatModel) Get(code string) (*entity.Format, error) {
+	query := `
+		SELECT code, name, surcharge, version
+		FROM formats
+		WHERE code = $1
+	`
+
+	var format entity.Format
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, code).Scan(
+		&format.Code,
+		&format.Name,
+		&format.Surcharge,
+		&format.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &format, nil
+}
+
+// Update changes the display name and per-seat surcharge of a format. The
+// codes themselves are fixed by entity.Formats.
+func (m FormatModel) Update(format *entity.Format) error {
+	query := `
+		UPDATE formats
+		SET name = $1, surcharge = $2, version = version + 1
+		WHERE code = $3 AND version = $4
+		RETURNING version
+	`
+
+	args := []interface{}{
+		format.Name,
+		format.Surcharge,
+		format.Code,
+		format.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&format.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ValidateFormat(v *validator.Validator, format *entity.Format) {
+	v.Check(format.Name != "", "name", "must be provided")
+	v.Check(len(format.Name) <= 50, "name", "must not be more than 50 bytes long")
+
+	v.Check(format.Surcharge >= 0, "surcharge", "must not be negative")
+	v.Check(format.Surcharge <= 1000000, "surcharge", "must not be more than 1000000")
+}
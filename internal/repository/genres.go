@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var (
+	ErrUnknownGenre = errors.New("unknown genre")
+
+	slugRX = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+type GenreModel struct {
+	DB *sql.DB
+}
+
+// setMovieGenres replaces the genres linked to a movie. Genres are looked up
+// by slug after running the values through genre_slug(), so "Hành động" or
+// "Action" both resolve to "action". It returns the canonical slugs.
+func setMovieGenres(ctx context.Context, tx *sql.Tx, movieId int64, genres []string) ([]string, error) {
+	unknownQuery := `
+		SELECT count(*)
+		FROM (SELECT DISTINCT genre_slug(x) AS slug FROM unnest($1::text[]) x) wanted
+		LEFT JOIN genres g ON g.slug = wanted.slug
+		WHERE g.id IS NULL
+	`
+
+	var unknown int
+
+	err := tx.QueryRowContext(ctx, unknownQuery, pq.Array(genres)).Scan(&unknown)
+	if err != nil {
+		return nil, err
+	}
+
+	if unknown > 0 {
+		return nil, ErrUnknownGenre
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_genres WHERE movie_id = $1`, movieId)
+	if err != nil {
+		return nil, err
+	}
+
+	insertQuery := `
+		INSERT INTO movie_genres (movie_id, genre_id)
+		SELECT DISTINCT $1::bigint, g.id
+		FROM unnest($2::text[]) x
+		INNER JOIN genres g ON g.slug = genre_slug(x)
+	`
+
+	_, err = tx.ExecContext(ctx, insertQuery, movieId, pq.Array(genres))
+	if err != nil {
+		return nil, err
+	}
+
+	var slugs []string
+
+	err = tx.QueryRowContext(ctx, `SELECT movie_genre_slugs($1)`, movieId).Scan(pq.Array(&slugs))
+	if err != nil {
+		return nil, err
+	}
+
+	return slugs, nil
+}
+
+func (m GenreModel) Insert(genre *entity.Genre) error {
+	names, err := json.Marshal(genre.Names)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO genres (slug, name, names)
+		VALUES (COALESCE(NULLIF($1, ''), genre_slug($2)), $2, $3)
+		RETURNING id, slug, version
+	`
+
+	args := []interface{}{genre.Slug, genre.Name, names}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.Slug, &genre.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m GenreModel) Get(id int64) (*entity.Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT g.id, g.slug, g.name, g.names, g.version,
+			(SELECT count(*) FROM movie_genres mg WHERE mg.genre_id = g.id)
+		FROM genres g
+		WHERE g.id = $1
+	`
+
+	var genre entity.Genre
+	var names []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.Slug,
+		&genre.Name,
+		&names,
+		&genre.Version,
+		&genre.MovieCount,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(names, &genre.Names)
+	if err != nil {
+		return nil, err
+	}
+
+	return &genre, nil
+}
+
+func (m GenreModel) Update(genre *entity.Genre) error {
+	names, err := json.Marshal(genre.Names)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE genres
+		SET slug = $1, name = $2, names = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
+	`
+
+	args := []interface{}{genre.Slug, genre.Name, names, genre.ID, genre.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a genre. Genres still linked to movies cannot be deleted and
+// return ErrViolatesForeignKey.
+func (m GenreModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM genres
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAll lists every genre with the number of movies linked to it.
+func (m GenreModel) GetAll() ([]*entity.Genre, error) {
+	query := `
+		SELECT g.id, g.slug, g.name, g.names, g.version, count(mg.movie_id)
+		FROM genres g
+		LEFT JOIN movie_genres mg ON mg.genre_id = g.id
+		GROUP BY g.id
+		ORDER BY g.name ASC, g.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*entity.Genre{}
+	for rows.Next() {
+		var genre entity.Genre
+		var names []byte
+
+		err := rows.Scan(
+			&genre.ID,
+			&genre.Slug,
+			&genre.Name,
+			&names,
+			&genre.Version,
+			&genre.MovieCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(names, &genre.Names)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func ValidateGenre(v *validator.Validator, genre *entity.Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	if genre.Slug != "" {
+		v.Check(validator.Matches(genre.Slug, slugRX), "slug", "must contain only lowercase letters, digits and single hyphens")
+		v.Check(len(genre.Slug) <= 100, "slug", "must not be more than 100 bytes long")
+	}
+
+	for lang, name := range genre.Names {
+		v.Check(len(lang) >= 2 && len(lang) <= 10, "names", "must be keyed by language code")
+		v.Check(name != "", "names", "must not contain empty names")
+		v.Check(len(name) <= 100, "names", "must not contain names more than 100 bytes long")
+	}
+}
@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var (
+	ErrGiftCardUnavailable = errors.New("gift card is not active")
+	ErrGiftCardExpired     = errors.New("gift card has expired")
+)
+
+type GiftCardModel struct {
+	DB *sql.DB
+}
+
+// generateGiftCardCode returns a random code of four groups of four
+// characters, e.g. 7KQ2-M4XD-P9ZA-3HRT.
+func generateGiftCardCode() (string, error) {
+	randomBytes := make([]byte, 10)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// Insert issues gift cards with freshly generated codes, all or none.
+func (m GiftCardModel) Insert(cards ...*entity.GiftCard) error {
+	query := `
+		INSERT INTO gift_cards (code, amount, status, purchased_by, expires_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::date)
+		RETURNING id, created_at, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, card := range cards {
+		card.Code, err = generateGiftCardCode()
+		if err != nil {
+			return err
+		}
+
+		args := []interface{}{card.Code, card.Amount, card.Status, card.PurchasedBy, card.ExpiresAt}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&card.ID, &card.CreatedAt, &card.Version)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrDuplicateConstraint
+			}
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (m GiftCardModel) GetAll(status string, filters Filters) ([]*entity.GiftCard, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, code, amount, status, purchased_by, redeemed_by, redeemed_at,
+			COALESCE(TO_CHAR(expires_at, 'YYYY-MM-DD'), ''), version
+		FROM gift_cards
+		WHERE (status = $1 OR $1 = '')
+		ORDER BY %s %s, id ASC
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{status, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	cards := []*entity.GiftCard{}
+	for rows.Next() {
+		var card entity.GiftCard
+
+		err := rows.Scan(
+			&totalRecords,
+			&card.ID,
+			&card.CreatedAt,
+			&card.Code,
+			&card.Amount,
+			&card.Status,
+			&card.PurchasedBy,
+			&card.RedeemedBy,
+			&card.RedeemedAt,
+			&card.ExpiresAt,
+			&card.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		cards = append(cards, &card)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return cards, metadata, nil
+}
+
+// Activate makes a purchased gift card redeemable once its payment has gone
+// through. Activating a card that is not pending is a no-op.
+func (m GiftCardModel) Activate(id int64) error {
+	query := `
+		UPDATE gift_cards
+		SET status = 'active', version = version + 1
+		WHERE id = $1 AND status = 'pending'
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// Redeem credits an active gift card to the user's wallet and marks it as
+// redeemed. It returns the wallet transaction crediting the card.
+func (m GiftCardModel) Redeem(code string, userId int64) (*entity.GiftCard, *entity.WalletTransaction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		SELECT id, created_at, code, amount, status, purchased_by, expires_at IS NOT NULL AND expires_at < CURRENT_DATE,
+			COALESCE(TO_CHAR(expires_at, 'YYYY-MM-DD'), ''), version
+		FROM gift_cards
+		WHERE code = $1
+		FOR UPDATE
+	`
+
+	var card entity.GiftCard
+	var expired bool
+
+	err = tx.QueryRowContext(ctx, query, strings.ToUpper(code)).Scan(
+		&card.ID,
+		&card.CreatedAt,
+		&card.Code,
+		&card.Amount,
+		&card.Status,
+		&card.PurchasedBy,
+		&expired,
+		&card.ExpiresAt,
+		&card.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	if card.Status != entity.GiftCardActive {
+		return nil, nil, ErrGiftCardUnavailable
+	}
+	if expired {
+		return nil, nil, ErrGiftCardExpired
+	}
+
+	updateQuery := `
+		UPDATE gift_cards
+		SET status = 'redeemed', redeemed_by = $1, redeemed_at = NOW(), version = version + 1
+		WHERE id = $2
+		RETURNING status, redeemed_by, redeemed_at, version
+	`
+
+	err = tx.QueryRowContext(ctx, updateQuery, userId, card.ID).Scan(&card.Status, &card.RedeemedBy, &card.RedeemedAt, &card.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reference := "gift_card:" + strconv.FormatInt(card.ID, 10)
+
+	transaction, err := postWalletTransaction(ctx, tx, userId, card.Amount, entity.AccountGiftCards, entity.WalletGiftCard, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &card, transaction, nil
+}
+
+func ValidateGiftCard(v *validator.Validator, card *entity.GiftCard) {
+	v.Check(card.Amount > 0, "amount", "must be greater than zero")
+	v.Check(card.Amount <= 10000000, "amount", "must not be more than 10000000")
+
+	if card.ExpiresAt != "" {
+		v.Check(isDate(card.ExpiresAt), "expires_at", "must be a date in yyyy-mm-dd format")
+		v.Check(card.ExpiresAt >= time.Now().Format("2006-01-02"), "expires_at", "must not be in the past")
+	}
+}
+
+func ValidateGiftCardCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 32, "code", "must not be more than 32 bytes long")
+}
@@ -0,0 +1,501 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+type GroupBookingModel struct {
+	DB *sql.DB
+}
+
+// Insert holds every seat of the booking's shares in tx and records the
+// booking. It returns ErrSeatUnavailable if a seat is already taken. The
+// shares themselves are added with AddShare once their reservations exist.
+func (m GroupBookingModel) Insert(tx *sql.Tx, booking *entity.GroupBooking) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var seatIds []int64
+	for _, share := range booking.Shares {
+		seatIds = append(seatIds, share.SeatIds...)
+	}
+
+	holdQuery := `
+		UPDATE seat_status
+		SET available = false
+		WHERE show_id = $1 AND seat_id = ANY($2) AND available
+	`
+
+	result, err := tx.ExecContext(ctx, holdQuery, booking.ShowId, pq.Array(seatIds))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(seatIds)) {
+		return ErrSeatUnavailable
+	}
+
+	query := `
+		INSERT INTO group_bookings (organizer_id, show_id, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	args := []interface{}{booking.OrganizerId, booking.ShowId, booking.ExpiresAt}
+
+	return tx.QueryRowContext(ctx, query, args...).Scan(&booking.ID, &booking.CreatedAt)
+}
+
+// AddShare records a share of a booking inserted in tx and issues its
+// invitation token.
+func (m GroupBookingModel) AddShare(tx *sql.Tx, share *entity.GroupShare) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	token, err := generateShareToken()
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO group_shares (group_booking_id, reservation_id, token, seat_ids, amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, status
+	`
+
+	args := []interface{}{share.GroupBookingId, share.ReservationId, token, pq.Array(share.SeatIds), share.Amount}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&share.ID, &share.Status)
+	if err != nil {
+		return err
+	}
+
+	share.Token = token
+
+	return nil
+}
+
+func (m GroupBookingModel) Get(id int64) (*entity.GroupBooking, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, organizer_id, show_id, expires_at
+		FROM group_bookings
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var booking entity.GroupBooking
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&booking.ID,
+		&booking.CreatedAt,
+		&booking.OrganizerId,
+		&booking.ShowId,
+		&booking.ExpiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	sharesQuery := `SELECT ` + groupShareColumns + groupShareFrom + `
+		WHERE gs.group_booking_id = $1
+		ORDER BY gs.id ASC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, sharesQuery, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		share, err := scanGroupShare(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		booking.Shares = append(booking.Shares, share)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &booking, nil
+}
+
+const groupShareColumns = `
+	gs.id, gs.group_booking_id, gs.reservation_id, gs.token, gs.seat_ids, gs.amount, gs.status, gs.member_id, gs.paid_at,
+	gb.expires_at, gb.show_id
+`
+
+const groupShareFrom = `
+	FROM group_shares gs
+	INNER JOIN group_bookings gb ON gb.id = gs.group_booking_id
+`
+
+func (m GroupBookingModel) GetShareByToken(token string) (*entity.GroupShare, error) {
+	query := `SELECT ` + groupShareColumns + groupShareFrom + `WHERE gs.token = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	share, err := scanGroupShare(m.DB.QueryRowContext(ctx, query, token))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return share, nil
+}
+
+// ReserveShare marks a share as being paid by a member so no one else can
+// pay for it meanwhile. It returns the id of the payment the member makes,
+// or ErrEditConflict if the share is not waiting for payment or the booking
+// has expired.
+func (m GroupBookingModel) ReserveShare(share *entity.GroupShare, memberId int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE group_shares gs
+		SET status = 'paying', member_id = $2
+		FROM group_bookings gb
+		WHERE gs.id = $1 AND gs.status = 'pending'
+		AND gb.id = gs.group_booking_id AND gb.expires_at > NOW()
+		RETURNING gs.status, gs.member_id
+	`
+
+	err = tx.QueryRowContext(ctx, query, share.ID, memberId).Scan(&share.Status, &share.MemberId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrEditConflict
+		default:
+			return 0, err
+		}
+	}
+
+	paymentQuery := `
+		INSERT INTO group_share_payments (share_id, member_id, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	var paymentId int64
+
+	err = tx.QueryRowContext(ctx, paymentQuery, share.ID, memberId, share.Amount).Scan(&paymentId)
+	if err != nil {
+		return 0, err
+	}
+
+	return paymentId, tx.Commit()
+}
+
+// ReleaseShare lets another member pay for a share whose payment was not
+// completed in time. Payments that are no longer pending are left alone.
+func (m GroupBookingModel) ReleaseShare(paymentId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	releaseQuery := `
+		UPDATE group_share_payments
+		SET status = 'released'
+		WHERE id = $1 AND status = 'pending'
+		RETURNING share_id, member_id
+	`
+
+	var shareId, memberId int64
+
+	err = tx.QueryRowContext(ctx, releaseQuery, paymentId).Scan(&shareId, &memberId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	query := `
+		UPDATE group_shares
+		SET status = 'pending', member_id = NULL
+		WHERE id = $1 AND status = 'paying' AND member_id = $2
+	`
+
+	_, err = tx.ExecContext(ctx, query, shareId, memberId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CompleteShare confirms a share once its member has paid: the share's
+// reservation becomes a paid reservation of the member. It returns the id
+// of that reservation, or 0 if the payment did not complete the share. A
+// payment that arrives after it was released or the booking expired is
+// refunded to the member's wallet.
+func (m GroupBookingModel) CompleteShare(paymentId int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	paymentQuery := `
+		SELECT share_id, member_id, amount, status
+		FROM group_share_payments
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var id, memberId, amount int64
+	var status string
+
+	err = tx.QueryRowContext(ctx, paymentQuery, paymentId).Scan(&id, &memberId, &amount, &status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	switch status {
+	case "released":
+		return 0, refundSharePayment(ctx, tx, paymentId, memberId, amount)
+	case "pending":
+	default:
+		return 0, nil
+	}
+
+	var share entity.GroupShare
+
+	selectQuery := `
+		SELECT reservation_id, status, member_id
+		FROM group_shares
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	err = tx.QueryRowContext(ctx, selectQuery, id).Scan(&share.ReservationId, &share.Status, &share.MemberId)
+	if err != nil {
+		return 0, err
+	}
+
+	if share.Status != entity.SharePaying || share.MemberId == nil || *share.MemberId != memberId || share.ReservationId == nil {
+		return 0, refundSharePayment(ctx, tx, paymentId, memberId, amount)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reservations SET user_id = $2, status = 'success' WHERE id = $1`, *share.ReservationId, memberId)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE group_shares SET status = 'paid', paid_at = NOW() WHERE id = $1`, id)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE group_share_payments SET status = 'paid' WHERE id = $1`, paymentId)
+	if err != nil {
+		return 0, err
+	}
+
+	return *share.ReservationId, tx.Commit()
+}
+
+// refundSharePayment gives a payment that can no longer complete its share
+// back to the member's wallet and commits tx. A payment is only refunded
+// once.
+func refundSharePayment(ctx context.Context, tx *sql.Tx, paymentId, memberId, amount int64) error {
+	reference := "group_share_payment:" + strconv.FormatInt(paymentId, 10)
+
+	_, err := postWalletTransaction(ctx, tx, memberId, amount, entity.AccountSales, entity.WalletRefund, reference)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE group_share_payments SET status = 'refunded' WHERE id = $1`, paymentId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ExpireBefore gives up the unpaid shares of bookings that have expired:
+// their seats are released and their reservations removed. It returns how
+// many shares expired.
+func (m GroupBookingModel) ExpireBefore(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	expireQuery := `
+		UPDATE group_shares gs
+		SET status = 'expired'
+		FROM group_bookings gb
+		WHERE gb.id = gs.group_booking_id AND gb.expires_at <= $1
+		AND gs.status IN ('pending', 'paying')
+		RETURNING gs.reservation_id, gs.seat_ids, gb.show_id
+	`
+
+	rows, err := tx.QueryContext(ctx, expireQuery, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var reservationIds []int64
+	var expired int64
+
+	type release struct {
+		showId  int64
+		seatIds []int64
+	}
+	var releases []release
+
+	for rows.Next() {
+		var reservationId sql.NullInt64
+		var r release
+
+		err := rows.Scan(&reservationId, (*pq.Int64Array)(&r.seatIds), &r.showId)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		if reservationId.Valid {
+			reservationIds = append(reservationIds, reservationId.Int64)
+		}
+		releases = append(releases, r)
+		expired++
+	}
+
+	if err = rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	for _, r := range releases {
+		_, err = tx.ExecContext(ctx, `UPDATE seat_status SET available = true WHERE show_id = $1 AND seat_id = ANY($2)`, r.showId, pq.Array(r.seatIds))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(reservationIds) > 0 {
+		_, err = tx.ExecContext(ctx, `DELETE FROM reservations WHERE id = ANY($1) AND status = 'pending'`, pq.Array(reservationIds))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Payments still under way for expired shares are refunded if they
+	// arrive.
+	releaseQuery := `
+		UPDATE group_share_payments p
+		SET status = 'released'
+		FROM group_shares gs
+		WHERE gs.id = p.share_id AND gs.status = 'expired' AND p.status = 'pending'
+	`
+
+	_, err = tx.ExecContext(ctx, releaseQuery)
+	if err != nil {
+		return 0, err
+	}
+
+	return expired, tx.Commit()
+}
+
+func scanGroupShare(row rowScanner) (*entity.GroupShare, error) {
+	var share entity.GroupShare
+
+	err := row.Scan(
+		&share.ID,
+		&share.GroupBookingId,
+		&share.ReservationId,
+		&share.Token,
+		(*pq.Int64Array)(&share.SeatIds),
+		&share.Amount,
+		&share.Status,
+		&share.MemberId,
+		&share.PaidAt,
+		&share.ExpiresAt,
+		&share.ShowId,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &share, nil
+}
+
+// generateShareToken returns a random token for the invitation link of a
+// group share.
+func generateShareToken() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+func ValidateShareToken(v *validator.Validator, token string) {
+	v.Check(len(token) == 26, "token", "must be 26 bytes long")
+}
@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrInsufficientPoints = errors.New("insufficient loyalty points")
+
+type LoyaltyModel struct {
+	DB *sql.DB
+}
+
+func (m LoyaltyModel) GetTiers() ([]*entity.LoyaltyTier, error) {
+	query := `
+		SELECT code, name, min_spend, multiplier, version
+		FROM loyalty_tiers
+		ORDER BY min_spend ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []*entity.LoyaltyTier{}
+	for rows.Next() {
+		var tier entity.LoyaltyTier
+
+		err := rows.Scan(
+			&tier.Code,
+			&tier.Name,
+			&tier.MinSpend,
+			&tier.Multiplier,
+			&tier.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tiers = append(tiers, &tier)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tiers, nil
+}
+
+func (m LoyaltyModel) GetTier(code string) (*entity.LoyaltyTier, error) {
+	query := `
+		SELECT code, name, min_spend, multiplier, version
+		FROM loyalty_tiers
+		WHERE code = $1
+	`
+
+	var tier entity.LoyaltyTier
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, code).Scan(
+		&tier.Code,
+		&tier.Name,
+		&tier.MinSpend,
+		&tier.Multiplier,
+		&tier.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tier, nil
+}
+
+// UpdateTier changes the name, spend threshold and earn multiplier of a
+// tier. The codes themselves are fixed.
+func (m LoyaltyModel) UpdateTier(tier *entity.LoyaltyTier) error {
+	query := `
+		UPDATE loyalty_tiers
+		SET name = $1, min_spend = $2, multiplier = $3, version = version + 1
+		WHERE code = $4 AND version = $5
+		RETURNING version
+	`
+
+	args := []interface{}{
+		tier.Name,
+		tier.MinSpend,
+		tier.Multiplier,
+		tier.Code,
+		tier.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&tier.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetPoints returns the user's points balance.
+func (m LoyaltyModel) GetPoints(userId int64) (int64, error) {
+	query := `
+		SELECT COALESCE((SELECT points FROM loyalty_accounts WHERE user_id = $1), 0)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var points int64
+
+	err := m.DB.QueryRowContext(ctx, query, userId).Scan(&points)
+	return points, err
+}
+
+// AnnualSpend returns what the user paid for reservations over the last 365
+// days, which decides their tier.
+func (m LoyaltyModel) AnnualSpend(userId int64) (int64, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0)
+		FROM reservations
+		WHERE user_id = $1 AND status = 'success' AND created_at > NOW() - INTERVAL '365 days'
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var spend int64
+
+	err := m.DB.QueryRowContext(ctx, query, userId).Scan(&spend)
+	return spend, err
+}
+
+func (m LoyaltyModel) GetTransactions(userId int64, filters Filters) ([]*entity.LoyaltyTransaction, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, user_id, reservation_id, kind, points, balance
+		FROM loyalty_transactions
+		WHERE user_id = $1
+		ORDER BY %s %s, id DESC
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{userId, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	transactions := []*entity.LoyaltyTransaction{}
+	for rows.Next() {
+		var transaction entity.LoyaltyTransaction
+
+		err := rows.Scan(
+			&totalRecords,
+			&transaction.ID,
+			&transaction.CreatedAt,
+			&transaction.UserId,
+			&transaction.ReservationId,
+			&transaction.Kind,
+			&transaction.Points,
+			&transaction.Balance,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		transactions = append(transactions, &transaction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return transactions, metadata, nil
+}
+
+// Redeem spends the points redeemed on a reservation inserted in tx. It
+// returns ErrInsufficientPoints if the user does not have enough.
+func (m LoyaltyModel) Redeem(tx *sql.Tx, reservation *entity.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := postLoyaltyTransaction(ctx, tx, reservation.UserId, reservation.ID, -reservation.PointsRedeemed, entity.LoyaltyRedeem)
+	return err
+}
+
+// Earn credits points for a paid reservation. Reservations that are not paid
+// or have already earned points are left alone, so it is safe to call again.
+func (m LoyaltyModel) Earn(reservationId, points int64) error {
+	if points <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE reservations
+		SET points_earned = $2
+		WHERE id = $1 AND status = 'success' AND points_earned = 0
+		RETURNING user_id
+	`
+
+	var userId int64
+
+	err = tx.QueryRowContext(ctx, query, reservationId, points).Scan(&userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	_, err = postLoyaltyTransaction(ctx, tx, userId, reservationId, points, entity.LoyaltyEarn)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// refundLoyaltyPoints gives back the points redeemed on a reservation being
+// removed in tx and takes back those it earned. Earned points the user has
+// already spent are only taken back as far as the balance allows.
+func refundLoyaltyPoints(ctx context.Context, tx *sql.Tx, userId, reservationId, redeemed, earned int64) error {
+	if redeemed > 0 {
+		_, err := postLoyaltyTransaction(ctx, tx, userId, reservationId, redeemed, entity.LoyaltyRefund)
+		if err != nil {
+			return err
+		}
+	}
+
+	if earned > 0 {
+		var balance int64
+
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE((SELECT points FROM loyalty_accounts WHERE user_id = $1), 0)`, userId).Scan(&balance)
+		if err != nil {
+			return err
+		}
+
+		if earned > balance {
+			earned = balance
+		}
+
+		if earned > 0 {
+			_, err = postLoyaltyTransaction(ctx, tx, userId, reservationId, -earned, entity.LoyaltyReverse)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// postLoyaltyTransaction adds points to the user's balance, or takes them
+// away when negative, and records the change.
+func postLoyaltyTransaction(ctx context.Context, tx *sql.Tx, userId, reservationId, points int64, kind string) (*entity.LoyaltyTransaction, error) {
+	var balance int64
+
+	if points >= 0 {
+		creditQuery := `
+			INSERT INTO loyalty_accounts (user_id, points)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET points = loyalty_accounts.points + EXCLUDED.points, version = loyalty_accounts.version + 1
+			RETURNING points
+		`
+
+		err := tx.QueryRowContext(ctx, creditQuery, userId, points).Scan(&balance)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		debitQuery := `
+			UPDATE loyalty_accounts
+			SET points = points + $2, version = version + 1
+			WHERE user_id = $1 AND points + $2 >= 0
+			RETURNING points
+		`
+
+		err := tx.QueryRowContext(ctx, debitQuery, userId, points).Scan(&balance)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return nil, ErrInsufficientPoints
+			default:
+				return nil, err
+			}
+		}
+	}
+
+	transaction := &entity.LoyaltyTransaction{
+		UserId:        userId,
+		ReservationId: &reservationId,
+		Kind:          kind,
+		Points:        points,
+		Balance:       balance,
+	}
+
+	query := `
+		INSERT INTO loyalty_transactions (user_id, reservation_id, kind, points, balance)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	args := []interface{}{userId, reservationId, kind, points, balance}
+
+	err := tx.QueryRowContext(ctx, query, args...).Scan(&transaction.ID, &transaction.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func ValidateLoyaltyTier(v *validator.Validator, tier *entity.LoyaltyTier) {
+	v.Check(tier.Name != "", "name", "must be provided")
+	v.Check(len(tier.Name) <= 50, "name", "must not be more than 50 bytes long")
+
+	v.Check(tier.MinSpend >= 0, "min_spend", "must not be negative")
+	v.Check(tier.MinSpend <= 1000000000, "min_spend", "must not be more than 1000000000")
+
+	v.Check(tier.Multiplier > 0, "multiplier", "must be greater than zero")
+	v.Check(tier.Multiplier <= 1000, "multiplier", "must not be more than 1000 percent")
+}
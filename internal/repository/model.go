@@ -13,6 +13,8 @@ var (
 	ErrEditConflict        = errors.New("edit conflict")
 	ErrDuplicateConstraint = errors.New("duplicate constraint")
 	ErrViolatesForeignKey  = errors.New("violates foreign key constraint")
+	ErrActiveReservations  = errors.New("has paid reservations for upcoming shows")
+	ErrUnsupportedFormat   = errors.New("format not supported by screen")
 )
 
 type Models struct {
@@ -22,7 +24,43 @@ type Models struct {
 		Get(id int64) (*entity.Movie, error)
 		Update(movie *entity.Movie) error
 		Delete(id int64) error
-		GetAll(title string, genres []string, filters Filters) ([]*entity.Movie, Metadata, error)
+		GetAll(search MovieSearch, filters Filters) ([]*entity.Movie, Metadata, error)
+		Autocomplete(prefix string, limit int) ([]*entity.MovieSuggestion, error)
+		Import(movies []*entity.Movie, dryRun bool) (ImportSummary, error)
+		Export(fn func(movie *entity.Movie) error) error
+	}
+	People interface {
+		Insert(person *entity.Person) error
+		Get(id int64) (*entity.Person, error)
+		Update(person *entity.Person) error
+		Delete(id int64) error
+		GetAll(name string, filters Filters) ([]*entity.Person, Metadata, error)
+	}
+	Credits interface {
+		Insert(credit *entity.Credit) error
+		Delete(id int64) error
+		GetAllForMovie(movieId int64) ([]*entity.Credit, error)
+		GetAllForPerson(personId int64) ([]*entity.Credit, error)
+	}
+	Genres interface {
+		Insert(genre *entity.Genre) error
+		Get(id int64) (*entity.Genre, error)
+		Update(genre *entity.Genre) error
+		Delete(id int64) error
+		GetAll() ([]*entity.Genre, error)
+	}
+	Reviews interface {
+		Insert(review *entity.Review) error
+		Get(id int64) (*entity.Review, error)
+		GetAll(movieId int64, statuses []string, filters Filters) ([]*entity.Review, Metadata, error)
+		Moderate(review *entity.Review, moderation *entity.ReviewModeration) error
+		GetModerations(reviewId int64) ([]*entity.ReviewModeration, error)
+	}
+	Recommendations interface {
+		GetForUser(userId int64, limit int) ([]*entity.Recommendation, error)
+	}
+	Search interface {
+		Search(q string, types []string, limit int) ([]*entity.SearchResult, error)
 	}
 	Users interface {
 		Insert(user *entity.User) error
@@ -42,48 +80,210 @@ type Models struct {
 	}
 	Theatres interface {
 		Insert(theatres *entity.Theatres) error
+		Get(id int64) (*entity.Theatres, error)
 		GetAll(city string, filters Filters) ([]*entity.Theatres, Metadata, error)
+		Nearby(lat, lng, radiusKm float64, limit int, withShows bool) ([]*entity.NearbyTheatre, error)
 		Update(theatres *entity.Theatres) error
 		Delete(theatreId int64) error
 	}
+	TheatreImages interface {
+		Insert(image *entity.TheatreImage) error
+		Get(id int64) (*entity.TheatreImage, error)
+		GetAllForTheatre(theatreId int64) ([]*entity.TheatreImage, error)
+		Delete(id int64) error
+	}
 	Screen interface {
 		Insert(screen *entity.Screen) error
+		Get(id int64) (*entity.Screen, error)
 		GetAll(theatreId int64, filters Filters) ([]*entity.Screen, Metadata, error)
 		Update(screen *entity.Screen) error
 		Delete(screenId int64) error
 	}
 	Seat interface {
 		Insert(seat *entity.Seat) error
+		Get(id int64) (*entity.Seat, error)
+		Update(seat *entity.Seat) error
+		Delete(id int64) error
 		InsertSeatStatus(showId int64, seatIds []int64) error
 		GetAllByScreenId(screenId int64) ([]*entity.Seat, error)
 		GetAllByShowId(showId int64, status string, filters Filters) ([]*entity.Seat, Metadata, error)
-		UpdateSeatStatus(status bool, showId int64, seatId []int64) (int64, error)
+		Occupancy(showId int64) (float64, error)
+		UpdateSeatStatus(status bool, showId int64, seatId []int64) error
+	}
+	Prices interface {
+		GetAllCategories() ([]*entity.PriceCategory, error)
+		GetCategory(code string) (*entity.PriceCategory, error)
+		InsertCategory(category *entity.PriceCategory) error
+		UpdateCategory(category *entity.PriceCategory) error
+		GetForScreen(screenId int64) (map[string]int64, error)
+		SetForScreen(screenId int64, prices map[string]int64) error
+		GetForShow(showId int64) ([]*entity.ShowPrice, error)
+		SetForShow(showId int64, prices map[string]int64) error
+		GetForSeats(showId int64, seatIds []int64) ([]*entity.SeatPrice, error)
+	}
+	PricingRules interface {
+		Insert(rule *entity.PricingRule) error
+		Get(id int64) (*entity.PricingRule, error)
+		GetAll() ([]*entity.PricingRule, error)
+		Update(rule *entity.PricingRule) error
+		Delete(id int64) error
+	}
+	TicketTypes interface {
+		GetAll() ([]*entity.TicketType, error)
+		Get(code string) (*entity.TicketType, error)
+		Update(ticketType *entity.TicketType) error
+		GetForShow(showId int64) ([]*entity.TicketType, error)
+		SetForShow(showId int64, ticketTypes []*entity.TicketType) error
+	}
+	PromoCodes interface {
+		Insert(promo *entity.PromoCode) error
+		Get(id int64) (*entity.PromoCode, error)
+		GetByCode(code string) (*entity.PromoCode, error)
+		GetAll() ([]*entity.PromoCode, error)
+		Update(promo *entity.PromoCode) error
+		Delete(id int64) error
+		Redeem(tx *sql.Tx, promo *entity.PromoCode, reservation *entity.Reservation) error
+	}
+	GiftCards interface {
+		Insert(cards ...*entity.GiftCard) error
+		GetAll(status string, filters Filters) ([]*entity.GiftCard, Metadata, error)
+		Activate(id int64) error
+		Redeem(code string, userId int64) (*entity.GiftCard, *entity.WalletTransaction, error)
+	}
+	Wallets interface {
+		Get(userId int64) (*entity.Wallet, error)
+		GetTransactions(userId int64, filters Filters) ([]*entity.WalletTransaction, Metadata, error)
+		Debit(tx *sql.Tx, userId, amount int64, kind, reference string) error
+	}
+	Loyalty interface {
+		GetTiers() ([]*entity.LoyaltyTier, error)
+		GetTier(code string) (*entity.LoyaltyTier, error)
+		UpdateTier(tier *entity.LoyaltyTier) error
+		GetPoints(userId int64) (int64, error)
+		AnnualSpend(userId int64) (int64, error)
+		GetTransactions(userId int64, filters Filters) ([]*entity.LoyaltyTransaction, Metadata, error)
+		Redeem(tx *sql.Tx, reservation *entity.Reservation) error
+		Earn(reservationId, points int64) error
+	}
+	Concessions interface {
+		Insert(concession *entity.Concession) error
+		Get(id int64) (*entity.Concession, error)
+		GetAllForTheatre(theatreId int64, ids []int64, activeOnly bool) ([]*entity.Concession, error)
+		Update(concession *entity.Concession) error
+		Delete(id int64) error
+		Reserve(tx *sql.Tx, reservation *entity.Reservation, items []*entity.ConcessionItem) error
 	}
 	Reservation interface {
-		Insert(tx *sql.Tx, reservation *entity.Reservation, seatId []int64) error
+		Insert(tx *sql.Tx, reservation *entity.Reservation, seats []*entity.SeatPrice) error
 		UpdateStatus(reservationId int64, status string) error
+		RefundCancelled(reservationId int64) error
+		SetPaymentIdentity(reservationId int64, identity string) error
 		GetById(id int64) (*entity.Reservation, error)
+		GetByTicketToken(token string) (*entity.Reservation, error)
+		CheckIn(reservation *entity.Reservation) error
+		HasAttended(userId, movieId int64) (bool, error)
 		Delete(id int64) error
 		GetAll(userId, showId int64, date time.Time, filters Filters) ([]*entity.Reservation, Metadata, error)
 	}
+	SeatChanges interface {
+		Insert(change *entity.SeatChange) error
+		Complete(id int64) error
+		Expire(id int64) error
+	}
+	TicketTransfers interface {
+		Insert(transfer *entity.TicketTransfer) error
+		Get(id int64) (*entity.TicketTransfer, error)
+		GetAllForReservation(reservationId int64) ([]*entity.TicketTransfer, error)
+		GetPendingForUser(userId int64) ([]*entity.TicketTransfer, error)
+		Accept(transfer *entity.TicketTransfer) error
+		UpdateStatus(transfer *entity.TicketTransfer, status string) error
+	}
+	Resale interface {
+		Insert(listing *entity.ResaleListing) error
+		Get(id int64) (*entity.ResaleListing, error)
+		GetAllForShow(showId int64) ([]*entity.ResaleListing, error)
+		GetAllForSeller(sellerId int64) ([]*entity.ResaleListing, error)
+		Withdraw(listing *entity.ResaleListing) error
+		WithdrawBefore(cutoff time.Duration) (int64, error)
+		Reserve(listing *entity.ResaleListing, buyerId, fee int64) (int64, error)
+		Release(purchaseId int64) error
+		Complete(purchaseId int64) error
+	}
+	GroupBookings interface {
+		Insert(tx *sql.Tx, booking *entity.GroupBooking) error
+		AddShare(tx *sql.Tx, share *entity.GroupShare) error
+		Get(id int64) (*entity.GroupBooking, error)
+		GetShareByToken(token string) (*entity.GroupShare, error)
+		ReserveShare(share *entity.GroupShare, memberId int64) (int64, error)
+		ReleaseShare(paymentId int64) error
+		CompleteShare(paymentId int64) (int64, error)
+		ExpireBefore(now time.Time) (int64, error)
+	}
+	BookingLimits interface {
+		GetForShow(showId int64) (*entity.BookingLimits, error)
+		SetForShow(limits *entity.BookingLimits) error
+		DeleteForShow(showId int64) error
+		Usage(tx *sql.Tx, userId, showId int64, ip string, window time.Duration) (*entity.BookingUsage, error)
+		Flag(userId, showId int64, reason string) error
+		GetFlag(id int64) (*entity.AccountFlag, error)
+		GetFlags(resolved bool, filters Filters) ([]*entity.AccountFlag, Metadata, error)
+		GetPendingHolds(userId, showId int64) ([]int64, error)
+		ResolveFlag(flag *entity.AccountFlag) error
+	}
+	Archive interface {
+		GetAll(archiveType string, filters Filters) ([]*entity.ArchivedItem, Metadata, error)
+		Restore(archiveType string, id int64) error
+		Purge(before time.Time, dryRun bool) (map[string]int64, error)
+	}
+	Formats interface {
+		GetAll() ([]*entity.Format, error)
+		Get(code string) (*entity.Format, error)
+		Update(format *entity.Format) error
+	}
 	Show interface {
 		Insert(show *entity.Show) error
-		GetAll(date string, title string, filters Filters) ([]*entity.Show, Metadata, error)
+		Get(id int64) (*entity.Show, error)
+		GetAll(search ShowSearch, filters Filters) ([]*entity.Show, Metadata, error)
+		GetUpcomingForPerson(personId int64) ([]*entity.Show, error)
+		Update(show *entity.Show) error
+		Delete(id int64) error
 	}
 }
 
 func NewModel(db *sql.DB) Models {
 	return Models{
-		DB:          db,
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Token:       TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
-		Theatres:    TheatresModel{DB: db},
-		Screen:      ScreenModel{DB: db},
-		Seat:        SeatModel{DB: db},
-		Reservation: ReservationModel{DB: db},
-		Show:        ShowModel{DB: db},
+		DB:              db,
+		Movies:          MovieModel{DB: db},
+		People:          PeopleModel{DB: db},
+		Credits:         CreditModel{DB: db},
+		Genres:          GenreModel{DB: db},
+		Reviews:         ReviewModel{DB: db},
+		Recommendations: RecommendationModel{DB: db},
+		Search:          SearchModel{DB: db},
+		Users:           UserModel{DB: db},
+		Token:           TokenModel{DB: db},
+		Permissions:     PermissionModel{DB: db},
+		Theatres:        TheatresModel{DB: db},
+		TheatreImages:   TheatreImageModel{DB: db},
+		Screen:          ScreenModel{DB: db},
+		Seat:            SeatModel{DB: db},
+		Prices:          PriceModel{DB: db},
+		PricingRules:    PricingRuleModel{DB: db},
+		TicketTypes:     TicketTypeModel{DB: db},
+		PromoCodes:      PromoCodeModel{DB: db},
+		GiftCards:       GiftCardModel{DB: db},
+		Wallets:         WalletModel{DB: db},
+		Loyalty:         LoyaltyModel{DB: db},
+		Concessions:     ConcessionModel{DB: db},
+		Reservation:     ReservationModel{DB: db},
+		SeatChanges:     SeatChangeModel{DB: db},
+		TicketTransfers: TicketTransferModel{DB: db},
+		Resale:          ResaleModel{DB: db},
+		GroupBookings:   GroupBookingModel{DB: db},
+		BookingLimits:   BookingLimitModel{DB: db},
+		Show:            ShowModel{DB: db},
+		Formats:         FormatModel{DB: db},
+		Archive:         ArchiveModel{DB: db},
 	}
 }
 
@@ -14,7 +14,7 @@ func TestInsertMovieSuccess(t *testing.T) {
 		Title:   "Avengers",
 		Year:    2021,
 		Runtime: entity.Runtime(102),
-		Genres:  []string{"actions", "superhero"},
+		Genres:  []string{"action", "adventure"},
 	}
 	err := store.Insert(&movie)
 
@@ -22,7 +22,7 @@ func TestInsertMovieSuccess(t *testing.T) {
 	assert.Equal(t, movie.Title, "Avengers")
 	assert.Equal(t, movie.Year, int32(2021))
 	assert.Equal(t, movie.Runtime, entity.Runtime(102))
-	assert.Equal(t, movie.Genres, []string{"actions", "superhero"})
+	assert.Equal(t, movie.Genres, []string{"action", "adventure"})
 
 	require.NotNil(t, movie.ID)
 	require.NotNil(t, movie.Version)
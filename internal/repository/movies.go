@@ -18,21 +18,39 @@ type MovieModel struct {
 
 func (m MovieModel) Insert(movie *entity.Movie) error {
 	query := `
-		INSERT INTO movies(title, year, runtime, genres)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, version
+		INSERT INTO movies(title, year, runtime, img, age_rating, language)
+		VALUES ($1, $2, $3, NULLIF($4, ''), COALESCE(NULLIF($5, ''), 'P'), $6)
+		RETURNING id, created_at, age_rating, version
 	`
 	args := []interface{}{
 		movie.Title,
 		movie.Year,
 		movie.Runtime,
-		pq.Array(movie.Genres),
+		movie.Img,
+		movie.AgeRating,
+		movie.Language,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.AgeRating, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	movie.Genres, err = setMovieGenres(ctx, tx, movie.ID, movie.Genres)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m MovieModel) Get(id int64) (*entity.Movie, error) {
@@ -40,9 +58,9 @@ func (m MovieModel) Get(id int64) (*entity.Movie, error) {
 		return nil, ErrRecordNotFound
 	}
 	query := `
-		SELECT id, created_at, title, year, runtime, genres, version
+		SELECT id, COALESCE(external_id, ''), created_at, title, year, runtime, movie_genre_slugs(id), COALESCE(img, ''), language, age_rating, rating_average, rating_count, version
 		FROM movies
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 	`
 	var movie entity.Movie
 
@@ -51,11 +69,17 @@ func (m MovieModel) Get(id int64) (*entity.Movie, error) {
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&movie.ID,
+		&movie.ExternalId,
 		&movie.CreatedAt,
 		&movie.Title,
 		&movie.Year,
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
+		&movie.Img,
+		&movie.Language,
+		&movie.AgeRating,
+		&movie.AverageRating,
+		&movie.RatingCount,
 		&movie.Version,
 	)
 
@@ -74,8 +98,8 @@ func (m MovieModel) Get(id int64) (*entity.Movie, error) {
 func (m MovieModel) Update(movie *entity.Movie) error {
 	query := `
 		UPDATE movies 
-		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		SET title = $1, year = $2, runtime = $3, img = NULLIF($4, ''), age_rating = $5, language = $6, version = version + 1
+		WHERE id = $7 AND version = $8
 		RETURNING version
 	`
 
@@ -83,7 +107,9 @@ func (m MovieModel) Update(movie *entity.Movie) error {
 		movie.Title,
 		movie.Year,
 		movie.Runtime,
-		pq.Array(movie.Genres),
+		movie.Img,
+		movie.AgeRating,
+		movie.Language,
 		movie.ID,
 		movie.Version,
 	}
@@ -91,7 +117,13 @@ func (m MovieModel) Update(movie *entity.Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -101,52 +133,114 @@ func (m MovieModel) Update(movie *entity.Movie) error {
 		}
 	}
 
-	return nil
+	movie.Genres, err = setMovieGenres(ctx, tx, movie.ID, movie.Genres)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// Delete archives a movie with its shows, keeping their reservations for
+// reporting. It returns ErrActiveReservations while an upcoming show of the
+// movie has paid bookings.
 func (m MovieModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
-	query := `
-		DELETE FROM movies
-		WHERE id = $1
-	`
+
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedMovie, id)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	if reserved {
+		return ErrActiveReservations
 	}
 
-	return nil
+	err = archive(ctx, tx, entity.ArchiveMovie, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MovieSearch holds the optional criteria accepted by MovieModel.GetAll. Zero
+// values mean "no restriction".
+type MovieSearch struct {
+	Title      string
+	Genres     []string
+	PersonId   int64
+	YearFrom   int
+	YearTo     int
+	RuntimeMin int
+	RuntimeMax int
+	Language   string
+	AgeRatings []string
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*entity.Movie, Metadata, error) {
+// GetAll searches the catalogue. Titles are matched accent- and
+// case-insensitively, either as a substring or by trigram word similarity
+// so that small typos still match. The computed relevance can be used as the
+// "relevance" sort column.
+func (m MovieModel) GetAll(search MovieSearch, filters Filters) ([]*entity.Movie, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+		SELECT count(*) OVER(), id, COALESCE(external_id, ''), created_at, title, year, runtime, movie_genre_slugs(id), COALESCE(img, ''), language, age_rating, rating_average, rating_count, version,
+			CASE WHEN $1 = '' THEN 0
+			ELSE word_similarity(immutable_unaccent(lower($1)), immutable_unaccent(lower(title)))
+				+ CASE WHEN immutable_unaccent(lower(title)) LIKE immutable_unaccent(lower($1)) || '%%' THEN 1 ELSE 0 END
+			END AS relevance
         FROM movies
-        WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-        AND (genres @> $2 OR $2 = '{}')     
+        WHERE deleted_at IS NULL
+        AND ($1 = ''
+            OR immutable_unaccent(lower(title)) LIKE '%%' || immutable_unaccent(lower($1)) || '%%'
+            OR word_similarity(immutable_unaccent(lower($1)), immutable_unaccent(lower(title))) >= 0.3)
+        AND (id IN (
+            SELECT mg.movie_id
+            FROM movie_genres mg
+            INNER JOIN genres g ON mg.genre_id = g.id
+            WHERE g.slug IN (SELECT genre_slug(x) FROM unnest($2::text[]) x)
+            GROUP BY mg.movie_id
+            HAVING count(*) = (SELECT count(DISTINCT genre_slug(x)) FROM unnest($2::text[]) x)
+        ) OR $2 = '{}')
+        AND (id IN (SELECT movie_id FROM movie_credits WHERE person_id = $3) OR $3 = 0)
+        AND (year >= $4 OR $4 = 0)
+        AND (year <= $5 OR $5 = 0)
+        AND (runtime >= $6 OR $6 = 0)
+        AND (runtime <= $7 OR $7 = 0)
+        AND (language = $8 OR $8 = '')
+        AND (age_rating = ANY($9) OR $9 = '{}')
         ORDER BY %s %s, id ASC
-        LIMIT $3 OFFSET $4
+        LIMIT $10 OFFSET $11
 	`, filters.sortColumn(), filters.sortDirection(),
 	)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []interface{}{
+		search.Title,
+		pq.Array(search.Genres),
+		search.PersonId,
+		search.YearFrom,
+		search.YearTo,
+		search.RuntimeMin,
+		search.RuntimeMax,
+		search.Language,
+		pq.Array(search.AgeRatings),
+		filters.limit(),
+		filters.offset(),
+	}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -159,16 +253,24 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*e
 	movies := []*entity.Movie{}
 	for rows.Next() {
 		var movie entity.Movie
+		var relevance float64
 
 		err := rows.Scan(
 			&totalRecords,
 			&movie.ID,
+			&movie.ExternalId,
 			&movie.CreatedAt,
 			&movie.Title,
 			&movie.Year,
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
+			&movie.Img,
+			&movie.Language,
+			&movie.AgeRating,
+			&movie.AverageRating,
+			&movie.RatingCount,
 			&movie.Version,
+			&relevance,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -185,6 +287,206 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*e
 	return movies, metadata, nil
 }
 
+// Autocomplete suggests titles where the prefix starts the title or any word
+// in it, ignoring case and accents. Titles starting with the prefix rank
+// first, then the most reviewed ones.
+func (m MovieModel) Autocomplete(prefix string, limit int) ([]*entity.MovieSuggestion, error) {
+	query := `
+		SELECT id, title, year, COALESCE(img, '')
+		FROM movies
+		WHERE deleted_at IS NULL
+		AND (immutable_unaccent(lower(title)) LIKE immutable_unaccent(lower($1)) || '%'
+			OR immutable_unaccent(lower(title)) LIKE '% ' || immutable_unaccent(lower($1)) || '%')
+		ORDER BY immutable_unaccent(lower(title)) LIKE immutable_unaccent(lower($1)) || '%' DESC,
+			rating_count DESC, title ASC, id ASC
+		LIMIT $2
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*entity.MovieSuggestion{}
+	for rows.Next() {
+		var suggestion entity.MovieSuggestion
+
+		err := rows.Scan(
+			&suggestion.ID,
+			&suggestion.Title,
+			&suggestion.Year,
+			&suggestion.Img,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// ImportError reports which movie of a bulk import the database rejected.
+// Index is the position of the movie in the slice passed to Import.
+type ImportError struct {
+	Index int
+	Err   error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("movie %d: %s", e.Index, e.Err)
+}
+
+func (e *ImportError) Unwrap() error {
+	return e.Err
+}
+
+type ImportSummary struct {
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	DryRun  bool `json:"dry_run"`
+}
+
+// Import upserts movies by external id in a single transaction, so either
+// every movie is written or none is. With dryRun the statements still run,
+// catching unknown genres and constraint violations, but the transaction is
+// rolled back. Posters are only replaced when the import provides one.
+func (m MovieModel) Import(movies []*entity.Movie, dryRun bool) (ImportSummary, error) {
+	query := `
+		INSERT INTO movies(external_id, title, year, runtime, img, age_rating, language)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), COALESCE(NULLIF($6, ''), 'P'), $7)
+		ON CONFLICT (external_id) DO UPDATE
+		SET title = EXCLUDED.title,
+			year = EXCLUDED.year,
+			runtime = EXCLUDED.runtime,
+			img = COALESCE(EXCLUDED.img, movies.img),
+			age_rating = EXCLUDED.age_rating,
+			language = EXCLUDED.language,
+			version = movies.version + 1
+		RETURNING id, created_at, COALESCE(img, ''), age_rating, version, xmax = 0
+	`
+
+	summary := ImportSummary{DryRun: dryRun}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	for i, movie := range movies {
+		args := []interface{}{
+			movie.ExternalId,
+			movie.Title,
+			movie.Year,
+			movie.Runtime,
+			movie.Img,
+			movie.AgeRating,
+			movie.Language,
+		}
+
+		var inserted bool
+
+		err := tx.QueryRowContext(ctx, query, args...).Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Img,
+			&movie.AgeRating,
+			&movie.Version,
+			&inserted,
+		)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23514" {
+				return summary, &ImportError{Index: i, Err: err}
+			}
+			return summary, err
+		}
+
+		movie.Genres, err = setMovieGenres(ctx, tx, movie.ID, movie.Genres)
+		if err != nil {
+			if errors.Is(err, ErrUnknownGenre) {
+				return summary, &ImportError{Index: i, Err: err}
+			}
+			return summary, err
+		}
+
+		if inserted {
+			summary.Created++
+		} else {
+			summary.Updated++
+		}
+	}
+
+	if dryRun {
+		return summary, nil
+	}
+
+	return summary, tx.Commit()
+}
+
+// Export calls fn for every movie in the catalogue, ordered by id, without
+// loading the whole catalogue into memory. It stops at the first error
+// returned by fn.
+func (m MovieModel) Export(fn func(movie *entity.Movie) error) error {
+	query := `
+		SELECT id, COALESCE(external_id, ''), created_at, title, year, runtime, movie_genre_slugs(id), COALESCE(img, ''), language, age_rating, rating_average, rating_count, version
+		FROM movies
+		WHERE deleted_at IS NULL
+		ORDER BY id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie entity.Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.ExternalId,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Img,
+			&movie.Language,
+			&movie.AgeRating,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&movie)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
 func ValidateMovie(v *validator.Validator, movie *entity.Movie) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 500, "title", "must not be more than 500 bytes long")
@@ -197,4 +499,31 @@ func ValidateMovie(v *validator.Validator, movie *entity.Movie) {
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
 	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
+	v.Check(validator.In(movie.AgeRating, entity.AgeRatings...), "age_rating", "must be one of P, C13, C16 or C18")
+	v.Check(len(movie.Language) <= 10, "language", "must not be more than 10 bytes long")
+}
+
+func ValidateMovieImport(v *validator.Validator, movie *entity.Movie) {
+	v.Check(movie.ExternalId != "", "external_id", "must be provided")
+	v.Check(len(movie.ExternalId) <= 100, "external_id", "must not be more than 100 bytes long")
+
+	ValidateMovie(v, movie)
+}
+
+func ValidateMovieSearch(v *validator.Validator, search MovieSearch) {
+	v.Check(search.YearFrom >= 0, "year_from", "must not be negative")
+	v.Check(search.YearTo >= 0, "year_to", "must not be negative")
+	if search.YearFrom > 0 && search.YearTo > 0 {
+		v.Check(search.YearFrom <= search.YearTo, "year_from", "must not be after year_to")
+	}
+
+	v.Check(search.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(search.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	if search.RuntimeMin > 0 && search.RuntimeMax > 0 {
+		v.Check(search.RuntimeMin <= search.RuntimeMax, "runtime_min", "must not be more than runtime_max")
+	}
+
+	for _, rating := range search.AgeRatings {
+		v.Check(validator.In(rating, entity.AgeRatings...), "age_rating", "must be one of P, C13, C16 or C18")
+	}
 }
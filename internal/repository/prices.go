@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var (
+	ErrUnknownPriceCategory = errors.New("unknown price category")
+	ErrUnpricedSeat         = errors.New("seat has no price for the show")
+)
+
+type PriceModel struct {
+	DB *sql.DB
+}
+
+func (m PriceModel) GetAllCategories() ([]*entity.PriceCategory, error) {
+	query := `
+		SELECT code, name, version
+		FROM price_categories
+		ORDER BY code ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []*entity.PriceCategory{}
+	for rows.Next() {
+		var category entity.PriceCategory
+
+		err := rows.Scan(
+			&category.Code,
+			&category.Name,
+			&category.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, &category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func (m PriceModel) GetCategory(code string) (*entity.PriceCategory, error) {
+	query := `
+		SELECT code, name, version
+		FROM price_categories
+		WHERE code = $1
+	`
+
+	var category entity.PriceCategory
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, code).Scan(
+		&category.Code,
+		&category.Name,
+		&category.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &category, nil
+}
+
+func (m PriceModel) InsertCategory(category *entity.PriceCategory) error {
+	query := `
+		INSERT INTO price_categories (code, name)
+		VALUES ($1, $2)
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, category.Code, category.Name).Scan(&category.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UpdateCategory renames a price category. Codes are referenced by seats and
+// prices, so they cannot change.
+func (m PriceModel) UpdateCategory(category *entity.PriceCategory) error {
+	query := `
+		UPDATE price_categories
+		SET name = $1, version = version + 1
+		WHERE code = $2 AND version = $3
+		RETURNING version
+	`
+
+	args := []interface{}{
+		category.Name,
+		category.Code,
+		category.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&category.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetForScreen returns the base price of each category priced on a screen.
+func (m PriceModel) GetForScreen(screenId int64) (map[string]int64, error) {
+	query := `
+		SELECT category, price
+		FROM screen_prices
+		WHERE screen_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, screenId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := map[string]int64{}
+	for rows.Next() {
+		var (
+			category string
+			price    int64
+		)
+
+		err := rows.Scan(&category, &price)
+		if err != nil {
+			return nil, err
+		}
+
+		prices[category] = price
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// SetForScreen replaces the base prices of a screen. Categories left out are
+// no longer priced on it.
+func (m PriceModel) SetForScreen(screenId int64, prices map[string]int64) error {
+	return m.replace(`screen_prices`, `screen_id`, screenId, prices)
+}
+
+// GetForShow returns the effective price of every category that is priced
+// for a show, either by an override or by the show's screen.
+func (m PriceModel) GetForShow(showId int64) ([]*entity.ShowPrice, error) {
+	query := `
+		SELECT c.code, COALESCE(shp.price, scp.price), shp.price IS NOT NULL
+		FROM shows sh
+		CROSS JOIN price_categories c
+		LEFT JOIN show_prices shp ON shp.show_id = sh.id AND shp.category = c.code
+		LEFT JOIN screen_prices scp ON scp.screen_id = sh.screen_id AND scp.category = c.code
+		WHERE sh.id = $1
+		AND COALESCE(shp.price, scp.price) IS NOT NULL
+		ORDER BY c.code ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, showId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prices := []*entity.ShowPrice{}
+	for rows.Next() {
+		var price entity.ShowPrice
+
+		err := rows.Scan(
+			&price.Category,
+			&price.Price,
+			&price.Overridden,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		prices = append(prices, &price)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return prices, nil
+}
+
+// SetForShow replaces the price overrides of a show. Categories left out fall
+// back to the screen's base price.
+func (m PriceModel) SetForShow(showId int64, prices map[string]int64) error {
+	return m.replace(`show_prices`, `show_id`, showId, prices)
+}
+
+// GetForSeats returns the category price of each seat for a show, before
+// pricing rules and the format surcharge. It returns ErrRecordNotFound when a
+// seat is not on the show's screen and ErrUnpricedSeat when a seat has no
+// price for the show.
+func (m PriceModel) GetForSeats(showId int64, seatIds []int64) ([]*entity.SeatPrice, error) {
+	query := `
+		SELECT st.id, st.category, show_seat_price(sh.id, st.id)
+		FROM seats st
+		INNER JOIN shows sh ON sh.screen_id = st.screen_id
+		WHERE sh.id = $1 AND st.id = ANY($2) AND st.deleted_at IS NULL
+		ORDER BY st.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, showId, pq.Array(seatIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seats := []*entity.SeatPrice{}
+	for rows.Next() {
+		var seat entity.SeatPrice
+		var price sql.NullInt64
+
+		err := rows.Scan(
+			&seat.SeatId,
+			&seat.Category,
+			&price,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if !price.Valid {
+			return nil, ErrUnpricedSeat
+		}
+		seat.Price = price.Int64
+
+		seats = append(seats, &seat)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(seats) != len(seatIds) {
+		return nil, ErrRecordNotFound
+	}
+
+	return seats, nil
+}
+
+// replace swaps the rows of a price table owned by ownerId for prices. It
+// returns ErrUnknownPriceCategory when a category does not exist.
+func (m PriceModel) replace(table, ownerColumn string, ownerId int64, prices map[string]int64) error {
+	categories := make([]string, 0, len(prices))
+	amounts := make([]int64, 0, len(prices))
+	for category, price := range prices {
+		categories = append(categories, category)
+		amounts = append(amounts, price)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	unknownQuery := `
+		SELECT count(*)
+		FROM unnest($1::text[]) x
+		LEFT JOIN price_categories c ON c.code = x
+		WHERE c.code IS NULL
+	`
+
+	var unknown int
+
+	err = tx.QueryRowContext(ctx, unknownQuery, pq.Array(categories)).Scan(&unknown)
+	if err != nil {
+		return err
+	}
+
+	if unknown > 0 {
+		return ErrUnknownPriceCategory
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE %s = $1`, table, ownerColumn), ownerId)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s (%s, category, price)
+		SELECT $1, x.category, x.price
+		FROM unnest($2::text[], $3::bigint[]) AS x(category, price)
+	`, table, ownerColumn)
+
+	_, err = tx.ExecContext(ctx, insertQuery, ownerId, pq.Array(categories), pq.Array(amounts))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ValidatePriceCategory(v *validator.Validator, category *entity.PriceCategory) {
+	v.Check(category.Code != "", "code", "must be provided")
+	v.Check(len(category.Code) <= 50, "code", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(category.Code, slugRX), "code", "must only contain lowercase letters, digits and dashes")
+
+	v.Check(category.Name != "", "name", "must be provided")
+	v.Check(len(category.Name) <= 50, "name", "must not be more than 50 bytes long")
+}
+
+func ValidatePrices(v *validator.Validator, prices map[string]int64) {
+	v.Check(prices != nil, "prices", "must be provided")
+	v.Check(len(prices) <= 20, "prices", "must not contain more than 20 categories")
+
+	for category, price := range prices {
+		v.Check(category != "", "prices", "must not contain an empty category")
+		v.Check(price > 0, "prices", "must only contain positive prices")
+		v.Check(price <= 10000000, "prices", "must not contain prices above 10000000")
+	}
+}
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"time"
+
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var clockRX = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+type PricingRuleModel struct {
+	DB *sql.DB
+}
+
+func (m PricingRuleModel) Insert(rule *entity.PricingRule) error {
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO pricing_rules (name, priority, conditions, adjustment, amount, stop, valid_from, valid_to)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::date, NULLIF($8, '')::date)
+		RETURNING id, version
+	`
+
+	args := []interface{}{
+		rule.Name,
+		rule.Priority,
+		conditions,
+		rule.Adjustment,
+		rule.Amount,
+		rule.Stop,
+		rule.ValidFrom,
+		rule.ValidTo,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&rule.ID, &rule.Version)
+}
+
+func (m PricingRuleModel) Get(id int64) (*entity.PricingRule, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, priority, conditions, adjustment, amount, stop,
+			COALESCE(TO_CHAR(valid_from, 'YYYY-MM-DD'), ''), COALESCE(TO_CHAR(valid_to, 'YYYY-MM-DD'), ''), version
+		FROM pricing_rules
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rule, err := scanPricingRule(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+// GetAll returns every rule in evaluation order, highest priority first.
+func (m PricingRuleModel) GetAll() ([]*entity.PricingRule, error) {
+	query := `
+		SELECT id, name, priority, conditions, adjustment, amount, stop,
+			COALESCE(TO_CHAR(valid_from, 'YYYY-MM-DD'), ''), COALESCE(TO_CHAR(valid_to, 'YYYY-MM-DD'), ''), version
+		FROM pricing_rules
+		ORDER BY priority DESC, id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*entity.PricingRule{}
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (m PricingRuleModel) Update(rule *entity.PricingRule) error {
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE pricing_rules
+		SET name = $1, priority = $2, conditions = $3, adjustment = $4, amount = $5, stop = $6,
+			valid_from = NULLIF($7, '')::date, valid_to = NULLIF($8, '')::date, version = version + 1
+		WHERE id = $9 AND version = $10
+		RETURNING version
+	`
+
+	args := []interface{}{
+		rule.Name,
+		rule.Priority,
+		conditions,
+		rule.Adjustment,
+		rule.Amount,
+		rule.Stop,
+		rule.ValidFrom,
+		rule.ValidTo,
+		rule.ID,
+		rule.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&rule.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PricingRuleModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM pricing_rules
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPricingRule(row rowScanner) (*entity.PricingRule, error) {
+	var rule entity.PricingRule
+	var conditions []byte
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&rule.Priority,
+		&conditions,
+		&rule.Adjustment,
+		&rule.Amount,
+		&rule.Stop,
+		&rule.ValidFrom,
+		&rule.ValidTo,
+		&rule.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(conditions, &rule.Conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+func isDate(value string) bool {
+	_, err := time.Parse("2006-01-02", value)
+	return err == nil
+}
+
+func ValidatePricingRule(v *validator.Validator, rule *entity.PricingRule) {
+	v.Check(rule.Name != "", "name", "must be provided")
+	v.Check(len(rule.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(rule.Priority >= -1000 && rule.Priority <= 1000, "priority", "must be between -1000 and 1000")
+
+	v.Check(validator.In(rule.Adjustment, entity.AdjustPercent, entity.AdjustAmount), "adjustment", "must be percent or amount")
+	if rule.Adjustment == entity.AdjustPercent {
+		v.Check(rule.Amount >= -100 && rule.Amount <= 500, "amount", "must be between -100 and 500 percent")
+	}
+	if rule.Adjustment == entity.AdjustAmount {
+		v.Check(rule.Amount >= -10000000 && rule.Amount <= 10000000, "amount", "must be between -10000000 and 10000000")
+	}
+
+	if rule.ValidFrom != "" {
+		v.Check(isDate(rule.ValidFrom), "valid_from", "must be a date in yyyy-mm-dd format")
+	}
+	if rule.ValidTo != "" {
+		v.Check(isDate(rule.ValidTo), "valid_to", "must be a date in yyyy-mm-dd format")
+	}
+	if rule.ValidFrom != "" && rule.ValidTo != "" {
+		v.Check(rule.ValidTo >= rule.ValidFrom, "valid_to", "must not be before valid_from")
+	}
+
+	cond := rule.Conditions
+
+	v.Check(validator.Unique(cond.Weekdays), "conditions.weekdays", "must not contain duplicate values")
+	for _, day := range cond.Weekdays {
+		v.Check(validator.In(day, entity.Weekdays...), "conditions.weekdays", "must only contain mon, tue, wed, thu, fri, sat or sun")
+	}
+
+	if cond.StartTime != "" {
+		v.Check(validator.Matches(cond.StartTime, clockRX), "conditions.start_time", "must be a time in HH:MM format")
+	}
+	if cond.EndTime != "" {
+		v.Check(validator.Matches(cond.EndTime, clockRX), "conditions.end_time", "must be a time in HH:MM format")
+	}
+
+	v.Check(len(cond.Holidays) <= 100, "conditions.holidays", "must not contain more than 100 dates")
+	for _, holiday := range cond.Holidays {
+		v.Check(isDate(holiday), "conditions.holidays", "must only contain dates in yyyy-mm-dd format")
+	}
+
+	checkRange := func(key string, min, max *int, upper int) {
+		if min != nil {
+			v.Check(*min >= 0 && *min <= upper, key, "must be within range")
+		}
+		if max != nil {
+			v.Check(*max >= 0 && *max <= upper, key, "must be within range")
+		}
+		if min != nil && max != nil {
+			v.Check(*min <= *max, key, "minimum must not be greater than maximum")
+		}
+	}
+
+	checkRange("conditions.occupancy", cond.MinOccupancy, cond.MaxOccupancy, 100)
+	checkRange("conditions.days_before", cond.MinDaysBefore, cond.MaxDaysBefore, 365)
+}
@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var (
+	ErrPromoUsageLimit = errors.New("promo code usage limit reached")
+	ErrPromoUserLimit  = errors.New("promo code per-user limit reached")
+)
+
+var promoCodeRX = regexp.MustCompile(`^[A-Z0-9_-]+$`)
+
+type PromoCodeModel struct {
+	DB *sql.DB
+}
+
+// promoCodeColumns is shared by the queries returning promo codes. times_used
+// only counts redemptions that have not been reversed.
+const promoCodeColumns = `
+	p.id, p.created_at, p.code, p.description, p.discount_type, p.discount_value, p.min_spend, p.max_discount,
+	COALESCE(TO_CHAR(p.valid_from, 'YYYY-MM-DD'), ''), COALESCE(TO_CHAR(p.valid_to, 'YYYY-MM-DD'), ''),
+	p.movie_ids, p.theatre_ids, p.weekdays, p.usage_limit, p.per_user_limit,
+	(SELECT count(*) FROM promo_redemptions pr WHERE pr.promo_code_id = p.id AND pr.reversed_at IS NULL),
+	p.active, p.version
+`
+
+func (m PromoCodeModel) Insert(promo *entity.PromoCode) error {
+	query := `
+		INSERT INTO promo_codes (code, description, discount_type, discount_value, min_spend, max_discount,
+			valid_from, valid_to, movie_ids, theatre_ids, weekdays, usage_limit, per_user_limit, active)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::date, NULLIF($8, '')::date, $9, $10, $11, $12, $13, $14)
+		RETURNING id, created_at, version
+	`
+
+	promo.Code = strings.ToUpper(promo.Code)
+
+	args := []interface{}{
+		promo.Code,
+		promo.Description,
+		promo.DiscountType,
+		promo.DiscountValue,
+		promo.MinSpend,
+		promo.MaxDiscount,
+		promo.ValidFrom,
+		promo.ValidTo,
+		pq.Array(promo.MovieIds),
+		pq.Array(promo.TheatreIds),
+		pq.Array(promo.Weekdays),
+		promo.UsageLimit,
+		promo.PerUserLimit,
+		promo.Active,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&promo.ID, &promo.CreatedAt, &promo.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m PromoCodeModel) Get(id int64) (*entity.PromoCode, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes p WHERE p.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	promo, err := scanPromoCode(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return promo, nil
+}
+
+// GetByCode looks a promo code up the way customers type it, ignoring case.
+func (m PromoCodeModel) GetByCode(code string) (*entity.PromoCode, error) {
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes p WHERE p.code = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	promo, err := scanPromoCode(m.DB.QueryRowContext(ctx, query, strings.ToUpper(code)))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return promo, nil
+}
+
+func (m PromoCodeModel) GetAll() ([]*entity.PromoCode, error) {
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes p ORDER BY p.id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	promos := []*entity.PromoCode{}
+	for rows.Next() {
+		promo, err := scanPromoCode(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		promos = append(promos, promo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promos, nil
+}
+
+func (m PromoCodeModel) Update(promo *entity.PromoCode) error {
+	query := `
+		UPDATE promo_codes
+		SET code = $1, description = $2, discount_type = $3, discount_value = $4, min_spend = $5, max_discount = $6,
+			valid_from = NULLIF($7, '')::date, valid_to = NULLIF($8, '')::date, movie_ids = $9, theatre_ids = $10,
+			weekdays = $11, usage_limit = $12, per_user_limit = $13, active = $14, version = version + 1
+		WHERE id = $15 AND version = $16
+		RETURNING version
+	`
+
+	promo.Code = strings.ToUpper(promo.Code)
+
+	args := []interface{}{
+		promo.Code,
+		promo.Description,
+		promo.DiscountType,
+		promo.DiscountValue,
+		promo.MinSpend,
+		promo.MaxDiscount,
+		promo.ValidFrom,
+		promo.ValidTo,
+		pq.Array(promo.MovieIds),
+		pq.Array(promo.TheatreIds),
+		pq.Array(promo.Weekdays),
+		promo.UsageLimit,
+		promo.PerUserLimit,
+		promo.Active,
+		promo.ID,
+		promo.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&promo.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m PromoCodeModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM promo_codes
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Redeem records the use of promo for a reservation inserted in tx. The promo
+// row is locked while the limits are checked so concurrent bookings cannot
+// both take the last use.
+func (m PromoCodeModel) Redeem(tx *sql.Tx, promo *entity.PromoCode, reservation *entity.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var usageLimit, perUserLimit sql.NullInt32
+
+	err := tx.QueryRowContext(ctx, `SELECT usage_limit, per_user_limit FROM promo_codes WHERE id = $1 FOR UPDATE`, promo.ID).
+		Scan(&usageLimit, &perUserLimit)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	countQuery := `
+		SELECT count(*), count(*) FILTER (WHERE user_id = $2)
+		FROM promo_redemptions
+		WHERE promo_code_id = $1 AND reversed_at IS NULL
+	`
+
+	var used, usedByUser int32
+
+	err = tx.QueryRowContext(ctx, countQuery, promo.ID, reservation.UserId).Scan(&used, &usedByUser)
+	if err != nil {
+		return err
+	}
+
+	if usageLimit.Valid && used >= usageLimit.Int32 {
+		return ErrPromoUsageLimit
+	}
+	if perUserLimit.Valid && usedByUser >= perUserLimit.Int32 {
+		return ErrPromoUserLimit
+	}
+
+	insertQuery := `
+		INSERT INTO promo_redemptions (promo_code_id, reservation_id, user_id, discount)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	args := []interface{}{promo.ID, reservation.ID, reservation.UserId, reservation.Discount}
+
+	_, err = tx.ExecContext(ctx, insertQuery, args...)
+	return err
+}
+
+func scanPromoCode(row rowScanner) (*entity.PromoCode, error) {
+	var promo entity.PromoCode
+
+	err := row.Scan(
+		&promo.ID,
+		&promo.CreatedAt,
+		&promo.Code,
+		&promo.Description,
+		&promo.DiscountType,
+		&promo.DiscountValue,
+		&promo.MinSpend,
+		&promo.MaxDiscount,
+		&promo.ValidFrom,
+		&promo.ValidTo,
+		(*pq.Int64Array)(&promo.MovieIds),
+		(*pq.Int64Array)(&promo.TheatreIds),
+		pq.Array(&promo.Weekdays),
+		&promo.UsageLimit,
+		&promo.PerUserLimit,
+		&promo.TimesUsed,
+		&promo.Active,
+		&promo.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &promo, nil
+}
+
+func ValidatePromoCode(v *validator.Validator, promo *entity.PromoCode) {
+	v.Check(promo.Code != "", "code", "must be provided")
+	v.Check(len(promo.Code) <= 32, "code", "must not be more than 32 bytes long")
+	v.Check(validator.Matches(strings.ToUpper(promo.Code), promoCodeRX), "code", "must only contain letters, digits, hyphens and underscores")
+
+	v.Check(len(promo.Description) <= 500, "description", "must not be more than 500 bytes long")
+
+	v.Check(validator.In(promo.DiscountType, entity.AdjustPercent, entity.AdjustAmount), "discount_type", "must be percent or amount")
+	if promo.DiscountType == entity.AdjustPercent {
+		v.Check(promo.DiscountValue > 0 && promo.DiscountValue <= 100, "discount_value", "must be between 1 and 100 percent")
+	}
+	if promo.DiscountType == entity.AdjustAmount {
+		v.Check(promo.DiscountValue > 0 && promo.DiscountValue <= 10000000, "discount_value", "must be between 1 and 10000000")
+	}
+
+	v.Check(promo.MinSpend >= 0, "min_spend", "must not be negative")
+	if promo.MaxDiscount != nil {
+		v.Check(*promo.MaxDiscount > 0, "max_discount", "must be greater than zero")
+	}
+
+	if promo.ValidFrom != "" {
+		v.Check(isDate(promo.ValidFrom), "valid_from", "must be a date in yyyy-mm-dd format")
+	}
+	if promo.ValidTo != "" {
+		v.Check(isDate(promo.ValidTo), "valid_to", "must be a date in yyyy-mm-dd format")
+	}
+	if promo.ValidFrom != "" && promo.ValidTo != "" {
+		v.Check(promo.ValidTo >= promo.ValidFrom, "valid_to", "must not be before valid_from")
+	}
+
+	v.Check(validator.Unique(promo.MovieIds), "movie_ids", "must not contain duplicate values")
+	v.Check(validator.Unique(promo.TheatreIds), "theatre_ids", "must not contain duplicate values")
+
+	v.Check(validator.Unique(promo.Weekdays), "weekdays", "must not contain duplicate values")
+	for _, day := range promo.Weekdays {
+		v.Check(validator.In(day, entity.Weekdays...), "weekdays", "must only contain mon, tue, wed, thu, fri, sat or sun")
+	}
+
+	if promo.UsageLimit != nil {
+		v.Check(*promo.UsageLimit > 0, "usage_limit", "must be greater than zero")
+	}
+	if promo.PerUserLimit != nil {
+		v.Check(*promo.PerUserLimit > 0, "per_user_limit", "must be greater than zero")
+	}
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+// Weights applied to the individual signals when ranking recommendations.
+// Genre affinity is counted once per matching genre of each booked movie, so
+// it grows quickly for heavy users; the other signals are rarer and weighted
+// up to compensate.
+const (
+	genreWeight        = 1.0
+	peopleWeight       = 2.0
+	similarUsersWeight = 1.5
+	popularityWeight   = 0.1
+
+	showsPerRecommendation = 5
+)
+
+type RecommendationModel struct {
+	DB *sql.DB
+}
+
+// GetForUser ranks movies with upcoming shows that the user has not booked
+// yet by how well they match the user's paid booking history.
+func (m RecommendationModel) GetForUser(userId int64, limit int) ([]*entity.Recommendation, error) {
+	query := `
+		WITH booked AS (
+			SELECT DISTINCT s.movie_id
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			WHERE r.user_id = $1 AND r.status = 'success'
+		),
+		genre_weights AS (
+			SELECT genre_id, COUNT(*) AS weight
+			FROM movie_genres
+			WHERE movie_id IN (SELECT movie_id FROM booked)
+			GROUP BY genre_id
+		),
+		booked_people AS (
+			SELECT DISTINCT person_id
+			FROM movie_credits
+			WHERE movie_id IN (SELECT movie_id FROM booked)
+		),
+		similar_users AS (
+			SELECT DISTINCT r.user_id
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			WHERE r.status = 'success' AND r.user_id <> $1
+			AND s.movie_id IN (SELECT movie_id FROM booked)
+		),
+		co_occurrence AS (
+			SELECT s.movie_id, COUNT(DISTINCT r.user_id) AS score
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			WHERE r.status = 'success' AND r.user_id IN (SELECT user_id FROM similar_users)
+			GROUP BY s.movie_id
+		),
+		popularity AS (
+			SELECT s.movie_id, COUNT(*) AS score
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			WHERE r.status = 'success' AND r.created_at > NOW() - INTERVAL '30 days'
+			GROUP BY s.movie_id
+		),
+		candidates AS (
+			SELECT DISTINCT movie_id
+			FROM shows
+			WHERE showtime > NOW() AND deleted_at IS NULL
+			AND movie_id NOT IN (SELECT movie_id FROM booked)
+		)
+		SELECT m.id, m.created_at, m.title, m.year, m.runtime, movie_genre_slugs(m.id), COALESCE(m.img, ''), m.language, m.age_rating,
+			m.rating_average, m.rating_count, m.version,
+			COALESCE((
+				SELECT SUM(gw.weight)
+				FROM genre_weights gw
+				INNER JOIN movie_genres mg ON mg.genre_id = gw.genre_id
+				WHERE mg.movie_id = m.id
+			), 0),
+			(
+				SELECT COUNT(*)
+				FROM movie_credits mc
+				WHERE mc.movie_id = m.id AND mc.person_id IN (SELECT person_id FROM booked_people)
+			),
+			COALESCE(co.score, 0),
+			COALESCE(p.score, 0)
+		FROM candidates c
+		INNER JOIN movies m ON c.movie_id = m.id AND m.deleted_at IS NULL
+		LEFT JOIN co_occurrence co ON co.movie_id = m.id
+		LEFT JOIN popularity p ON p.movie_id = m.id
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recommendations := []*entity.Recommendation{}
+	for rows.Next() {
+		var movie entity.Movie
+		var recommendation entity.Recommendation
+		var popularity float64
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Img,
+			&movie.Language,
+			&movie.AgeRating,
+			&movie.AverageRating,
+			&movie.RatingCount,
+			&movie.Version,
+			&recommendation.GenreScore,
+			&recommendation.PeopleScore,
+			&recommendation.SimilarUsersScore,
+			&popularity,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		recommendation.Movie = &movie
+		recommendation.Score = genreWeight*recommendation.GenreScore +
+			peopleWeight*recommendation.PeopleScore +
+			similarUsersWeight*recommendation.SimilarUsersScore +
+			popularityWeight*popularity
+
+		recommendations = append(recommendations, &recommendation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Movie.ID < recommendations[j].Movie.ID
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	err = m.attachShows(ctx, recommendations)
+	if err != nil {
+		return nil, err
+	}
+
+	return recommendations, nil
+}
+
+// attachShows loads the next few upcoming shows of each recommended movie.
+func (m RecommendationModel) attachShows(ctx context.Context, recommendations []*entity.Recommendation) error {
+	if len(recommendations) == 0 {
+		return nil
+	}
+
+	byMovie := make(map[int64]*entity.Recommendation, len(recommendations))
+	movieIds := make([]int64, 0, len(recommendations))
+
+	for _, recommendation := range recommendations {
+		recommendation.Shows = []*entity.Show{}
+		byMovie[recommendation.Movie.ID] = recommendation
+		movieIds = append(movieIds, recommendation.Movie.ID)
+	}
+
+	query := `
+		SELECT id, showtime, movie_id, screen_id, format, audio_language, subtitle_language, version
+		FROM (
+			SELECT id, showtime, movie_id, screen_id, format, audio_language, subtitle_language, version,
+				row_number() OVER (PARTITION BY movie_id ORDER BY showtime ASC, id ASC) AS n
+			FROM shows
+			WHERE movie_id = ANY($1) AND showtime > NOW() AND deleted_at IS NULL
+		) upcoming
+		WHERE n <= $2
+		ORDER BY showtime ASC, id ASC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIds), showsPerRecommendation)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var show entity.Show
+
+		err := rows.Scan(
+			&show.ID,
+			&show.Showtime,
+			&show.MovieId,
+			&show.ScreenId,
+			&show.Format,
+			&show.AudioLanguage,
+			&show.SubtitleLanguage,
+			&show.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		recommendation := byMovie[show.MovieId]
+		recommendation.Shows = append(recommendation.Shows, &show)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,560 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrSeatListed = errors.New("seat is listed for resale")
+
+type ResaleModel struct {
+	DB *sql.DB
+}
+
+const resaleListingColumns = `
+	rl.id, rl.created_at, rl.reservation_id, rl.seller_id, rl.show_id, rl.seat_id, st.row, st.number, st.category,
+	rs.ticket_type, rl.face_value, rl.price, rl.fee, rl.status, rl.buyer_id, rl.new_reservation_id, rl.sold_at, rl.version
+`
+
+// Resale listings join the seat and, while the seat is still held by the
+// seller, the ticket it was sold as.
+const resaleListingFrom = `
+	FROM resale_listings rl
+	INNER JOIN seats st ON st.id = rl.seat_id
+	LEFT JOIN reservation_seat rs ON rs.seat_id = rl.seat_id
+	AND rs.reservation_id = COALESCE(rl.new_reservation_id, rl.reservation_id)
+`
+
+// Insert lists a seat of a paid reservation for resale. It returns
+// ErrEditConflict if the reservation can no longer be resold from,
+// ErrTransferPending if the seat is being transferred and
+// ErrDuplicateConstraint if it is already listed.
+func (m ResaleModel) Insert(listing *entity.ResaleListing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	lockQuery := `
+		SELECT r.show_id
+		FROM reservations r
+		INNER JOIN reservation_seat rs ON rs.reservation_id = r.id
+		WHERE r.id = $1 AND rs.seat_id = $2 AND r.status = 'success' AND r.checked_in_at IS NULL
+		FOR UPDATE OF r
+	`
+
+	err = tx.QueryRowContext(ctx, lockQuery, listing.ReservationId, listing.SeatId).Scan(&listing.ShowId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	pending, err := hasPendingTransfer(ctx, tx, listing.ReservationId, []int64{listing.SeatId})
+	if err != nil {
+		return err
+	}
+
+	if pending {
+		return ErrTransferPending
+	}
+
+	query := `
+		INSERT INTO resale_listings (reservation_id, seller_id, show_id, seat_id, face_value, price)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, status, version
+	`
+
+	args := []interface{}{
+		listing.ReservationId,
+		listing.SellerId,
+		listing.ShowId,
+		listing.SeatId,
+		listing.FaceValue,
+		listing.Price,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&listing.ID, &listing.CreatedAt, &listing.Status, &listing.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ResaleModel) Get(id int64) (*entity.ResaleListing, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + resaleListingColumns + resaleListingFrom + `WHERE rl.id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	listing, err := scanResaleListing(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return listing, nil
+}
+
+// GetAllForShow returns the seats of a show currently on sale, cheapest
+// first.
+func (m ResaleModel) GetAllForShow(showId int64) ([]*entity.ResaleListing, error) {
+	query := `SELECT ` + resaleListingColumns + resaleListingFrom + `
+		WHERE rl.show_id = $1 AND rl.status = 'listed'
+		ORDER BY rl.price ASC, st.row ASC, st.number ASC
+	`
+
+	return m.getAll(query, showId)
+}
+
+// GetAllForSeller returns every listing the user has made, newest first.
+func (m ResaleModel) GetAllForSeller(sellerId int64) ([]*entity.ResaleListing, error) {
+	query := `SELECT ` + resaleListingColumns + resaleListingFrom + `
+		WHERE rl.seller_id = $1
+		ORDER BY rl.created_at DESC, rl.id DESC
+	`
+
+	return m.getAll(query, sellerId)
+}
+
+func (m ResaleModel) getAll(query string, id int64) ([]*entity.ResaleListing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	listings := []*entity.ResaleListing{}
+	for rows.Next() {
+		listing, err := scanResaleListing(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		listings = append(listings, listing)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return listings, nil
+}
+
+// Withdraw takes a listing off sale. It returns ErrEditConflict if the
+// listing is no longer on sale, including while a buyer is paying for it.
+func (m ResaleModel) Withdraw(listing *entity.ResaleListing) error {
+	query := `
+		UPDATE resale_listings
+		SET status = 'withdrawn', version = version + 1
+		WHERE id = $1 AND status = 'listed'
+		RETURNING status, version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, listing.ID).Scan(&listing.Status, &listing.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithdrawBefore takes every listing for a show starting within cutoff off
+// sale and returns how many there were. Listings being paid for are left to
+// complete or be released.
+func (m ResaleModel) WithdrawBefore(cutoff time.Duration) (int64, error) {
+	query := `
+		UPDATE resale_listings rl
+		SET status = 'withdrawn', version = rl.version + 1
+		FROM shows s
+		WHERE s.id = rl.show_id AND rl.status = 'listed'
+		AND s.showtime < NOW() + make_interval(secs => $1)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, cutoff.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// Reserve holds a listing for a buyer while they pay, recording the fee that
+// will be kept from the sale. It returns the id of the purchase the payment
+// is made for, or ErrEditConflict if the listing is no longer on sale.
+func (m ResaleModel) Reserve(listing *entity.ResaleListing, buyerId, fee int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE resale_listings
+		SET status = 'reserved', buyer_id = $2, fee = $3, version = version + 1
+		WHERE id = $1 AND status = 'listed'
+		RETURNING status, buyer_id, fee, version
+	`
+
+	err = tx.QueryRowContext(ctx, query, listing.ID, buyerId, fee).Scan(&listing.Status, &listing.BuyerId, &listing.Fee, &listing.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrEditConflict
+		default:
+			return 0, err
+		}
+	}
+
+	purchaseQuery := `
+		INSERT INTO resale_purchases (listing_id, buyer_id, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+
+	var purchaseId int64
+
+	err = tx.QueryRowContext(ctx, purchaseQuery, listing.ID, buyerId, listing.Price).Scan(&purchaseId)
+	if err != nil {
+		return 0, err
+	}
+
+	return purchaseId, tx.Commit()
+}
+
+// Release puts a listing whose buyer did not pay in time for a purchase back
+// on sale. Purchases that are no longer waiting for payment are left alone.
+func (m ResaleModel) Release(purchaseId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var listingId int64
+
+	releaseQuery := `
+		UPDATE resale_purchases
+		SET status = 'released'
+		WHERE id = $1 AND status = 'pending'
+		RETURNING listing_id
+	`
+
+	err = tx.QueryRowContext(ctx, releaseQuery, purchaseId).Scan(&listingId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	query := `
+		UPDATE resale_listings
+		SET status = 'listed', buyer_id = NULL, fee = 0, version = version + 1
+		WHERE id = $1 AND status = 'reserved'
+	`
+
+	_, err = tx.ExecContext(ctx, query, listingId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Complete finishes a sale once the buyer has paid for a purchase. The seat
+// moves to a new paid reservation of the buyer, the seller's reservation gets
+// a new ticket token and the seller's wallet is credited with the price less
+// the fee. The loyalty points the seller earned on the seat are taken back.
+// A payment for a purchase that was released before it arrived is
+// refunded to the buyer's wallet. Purchases already paid or refunded are
+// left alone.
+func (m ResaleModel) Complete(purchaseId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	purchaseQuery := `
+		SELECT listing_id, buyer_id, amount, status
+		FROM resale_purchases
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	var id, buyerId, amount int64
+	var status string
+
+	err = tx.QueryRowContext(ctx, purchaseQuery, purchaseId).Scan(&id, &buyerId, &amount, &status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil
+		default:
+			return err
+		}
+	}
+
+	switch status {
+	case "released":
+		reference := "resale_purchase:" + strconv.FormatInt(purchaseId, 10)
+
+		_, err = postWalletTransaction(ctx, tx, buyerId, amount, entity.AccountSales, entity.WalletRefund, reference)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE resale_purchases SET status = 'refunded' WHERE id = $1`, purchaseId)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	case "pending":
+	default:
+		return nil
+	}
+
+	// A pending purchase always holds its listing.
+	selectQuery := `
+		SELECT reservation_id, seller_id, show_id, seat_id, face_value, price, fee, buyer_id
+		FROM resale_listings
+		WHERE id = $1 AND status = 'reserved' AND buyer_id = $2
+		FOR UPDATE
+	`
+
+	var listing entity.ResaleListing
+
+	err = tx.QueryRowContext(ctx, selectQuery, id, buyerId).Scan(
+		&listing.ReservationId,
+		&listing.SellerId,
+		&listing.ShowId,
+		&listing.SeatId,
+		&listing.FaceValue,
+		&listing.Price,
+		&listing.Fee,
+		&listing.BuyerId,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	var paid, pointsEarned int64
+
+	err = tx.QueryRowContext(ctx, `SELECT amount, points_earned FROM reservations WHERE id = $1 FOR UPDATE`, listing.ReservationId).Scan(&paid, &pointsEarned)
+	if err != nil {
+		return err
+	}
+
+	// Points were earned on the whole reservation, so the seat's share of
+	// them is in proportion to what was paid for it.
+	if pointsEarned > 0 && paid > 0 {
+		points := pointsEarned * listing.FaceValue / paid
+		if points > pointsEarned {
+			points = pointsEarned
+		}
+
+		if points > 0 {
+			_, err = tx.ExecContext(ctx, `UPDATE reservations SET points_earned = points_earned - $2 WHERE id = $1`, listing.ReservationId, points)
+			if err != nil {
+				return err
+			}
+
+			err = refundLoyaltyPoints(ctx, tx, listing.SellerId, listing.ReservationId, 0, points)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	token, err := generateTicketToken()
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO reservations (user_id, amount, show_id, status, ticket_token, transferred_from)
+		VALUES ($1, $2, $3, 'success', $4, $5)
+		RETURNING id
+	`
+
+	args := []interface{}{*listing.BuyerId, listing.Price, listing.ShowId, token, listing.ReservationId}
+
+	var newReservationId int64
+
+	err = tx.QueryRowContext(ctx, insertQuery, args...).Scan(&newReservationId)
+	if err != nil {
+		return err
+	}
+
+	// The buyer paid the listing price for the seat, which is all it can be
+	// resold or refunded for from now on.
+	moveQuery := `
+		UPDATE reservation_seat
+		SET reservation_id = $2, paid_price = $4
+		WHERE reservation_id = $1 AND seat_id = $3
+	`
+
+	result, err := tx.ExecContext(ctx, moveQuery, listing.ReservationId, newReservationId, listing.SeatId, listing.Price)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	token, err = generateTicketToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reservations SET ticket_token = $2 WHERE id = $1`, listing.ReservationId, token)
+	if err != nil {
+		return err
+	}
+
+	if proceeds := listing.Price - listing.Fee; proceeds > 0 {
+		reference := "resale:" + strconv.FormatInt(id, 10)
+
+		_, err = postWalletTransaction(ctx, tx, listing.SellerId, proceeds, entity.AccountSales, entity.WalletResale, reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `
+		UPDATE resale_listings
+		SET status = 'sold', new_reservation_id = $2, sold_at = NOW(), version = version + 1
+		WHERE id = $1
+	`
+
+	_, err = tx.ExecContext(ctx, updateQuery, id, newReservationId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE resale_purchases SET status = 'paid' WHERE id = $1`, purchaseId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// hasActiveListing reports whether any of the seats of a reservation is on
+// sale or being paid for by a buyer.
+func hasActiveListing(ctx context.Context, tx *sql.Tx, reservationId int64, seatIds []int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM resale_listings
+			WHERE reservation_id = $1 AND seat_id = ANY($2) AND status IN ('listed', 'reserved')
+		)
+	`
+
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, query, reservationId, pq.Array(seatIds)).Scan(&exists)
+	return exists, err
+}
+
+func scanResaleListing(row rowScanner) (*entity.ResaleListing, error) {
+	var listing entity.ResaleListing
+	var ticketType sql.NullString
+
+	err := row.Scan(
+		&listing.ID,
+		&listing.CreatedAt,
+		&listing.ReservationId,
+		&listing.SellerId,
+		&listing.ShowId,
+		&listing.SeatId,
+		&listing.Row,
+		&listing.Number,
+		&listing.Category,
+		&ticketType,
+		&listing.FaceValue,
+		&listing.Price,
+		&listing.Fee,
+		&listing.Status,
+		&listing.BuyerId,
+		&listing.NewReservationId,
+		&listing.SoldAt,
+		&listing.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	listing.TicketType = ticketType.String
+
+	return &listing, nil
+}
+
+func ValidateResaleListing(v *validator.Validator, listing *entity.ResaleListing) {
+	v.Check(listing.Price > 0, "price", "must be greater than zero")
+	v.Check(listing.Price <= listing.FaceValue, "price", "must not be more than the price paid for the seat")
+}
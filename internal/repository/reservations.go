@@ -2,10 +2,15 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base32"
 	"errors"
 	"fmt"
 	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/pricing"
+	"greenlight.zuyanh.net/internal/validator"
+	"strconv"
 	"time"
 )
 
@@ -13,38 +18,109 @@ type ReservationModel struct {
 	DB *sql.DB
 }
 
-func (m ReservationModel) Insert(tx *sql.Tx, reservation *entity.Reservation, seatId []int64) error {
+// Conditions for hasUpcomingPaidReservations, selecting reservations by the
+// movie, show (s), screen (sc) or theatre they are for, or by a seat they hold.
+const (
+	reservedMovie   = `s.movie_id = $1`
+	reservedShow    = `s.id = $1`
+	reservedScreen  = `sc.id = $1`
+	reservedTheatre = `sc.theatre_id = $1`
+	reservedSeat    = `r.id IN (SELECT reservation_id FROM reservation_seat WHERE seat_id = $1)`
+)
+
+// hasUpcomingPaidReservations reports whether a paid reservation matching
+// condition exists for a show that has not started yet. Venues and shows with
+// such reservations must not be archived from under the customers.
+func hasUpcomingPaidReservations(ctx context.Context, tx *sql.Tx, condition string, id int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			INNER JOIN screens sc ON s.screen_id = sc.id
+			WHERE r.status = 'success' AND s.showtime > NOW()
+			AND ` + condition + `
+		)
+	`
+
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&exists)
+	return exists, err
+}
+
+// Insert holds seats for a reservation and issues its ticket token. The price
+// of each seat is stored with it so later price changes do not affect the
+// booking, along with its share of the total once the reservation's discounts
+// are taken off. A reservation
+// with nothing left to pay once its wallet payment is taken is inserted as
+// paid.
+func (m ReservationModel) Insert(tx *sql.Tx, reservation *entity.Reservation, seats []*entity.SeatPrice) error {
 	insertReservationQuery := `
-		INSERT INTO reservations (user_id, amount, show_id) 
-		VALUES ($1, $2, $3)
+		INSERT INTO reservations (user_id, amount, show_id, discount, wallet_amount, points_redeemed, points_discount, concessions_amount, ticket_token, client_ip) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''))
 		RETURNING id, created_at, status
 	`
 
-	args := []interface{}{reservation.UserId, reservation.Amount, reservation.ShowId}
+	token, err := generateTicketToken()
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{
+		reservation.UserId,
+		reservation.Amount,
+		reservation.ShowId,
+		reservation.Discount,
+		reservation.WalletAmount,
+		reservation.PointsRedeemed,
+		reservation.PointsDiscount,
+		reservation.ConcessionsAmount,
+		token,
+		reservation.ClientIP,
+	}
 
-	err := tx.QueryRow(insertReservationQuery, args...).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.Status)
+	err = tx.QueryRow(insertReservationQuery, args...).Scan(&reservation.ID, &reservation.CreatedAt, &reservation.Status)
 	if err != nil {
 		return err
 	}
 
+	reservation.TicketToken = token
+
+	if reservation.WalletAmount >= reservation.Amount {
+		err = tx.QueryRow(`UPDATE reservations SET status = $1 WHERE id = $2 RETURNING status`, "success", reservation.ID).Scan(&reservation.Status)
+		if err != nil {
+			return err
+		}
+	}
+
 	insertReservationSeatsQuery := `
-		INSERT INTO reservation_seat(reservation_id, seat_id)
-		VALUES ($1, $2)
+		INSERT INTO reservation_seat(reservation_id, seat_id, ticket_type, price, paid_price)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
 
-	errors := make(chan error, len(seatId))
+	var ticketsTotal int64
+	for _, seat := range seats {
+		ticketsTotal += seat.Price
+	}
+
+	discount := reservation.Discount + reservation.PointsDiscount
+
+	errors := make(chan error, len(seats))
 	defer close(errors)
 
-	for _, seatId := range seatId {
-		go func(seatId int64) {
-			args := []interface{}{reservation.ID, seatId}
+	for _, seat := range seats {
+		go func(seat *entity.SeatPrice) {
+			paidPrice := pricing.ResaleFaceValue(seat.Price, ticketsTotal, discount)
+
+			args := []interface{}{reservation.ID, seat.SeatId, seat.TicketType, seat.Price, paidPrice}
 			_, err := tx.Exec(insertReservationSeatsQuery, args...)
 			errors <- err
-		}(seatId)
+		}(seat)
 	}
 
-	for i := 0; i < len(seatId); i++ {
+	for i := 0; i < len(seats); i++ {
 		if err := <-errors; err != nil {
 			return err
 		}
@@ -52,11 +128,13 @@ func (m ReservationModel) Insert(tx *sql.Tx, reservation *entity.Reservation, se
 	return nil
 }
 
+// UpdateStatus moves a pending reservation to status. It returns
+// ErrEditConflict if the reservation is no longer pending or was cancelled.
 func (m ReservationModel) UpdateStatus(reservationId int64, status string) error {
 	query := `
 		UPDATE reservations 
 		SET status = $1
-		WHERE id = $2
+		WHERE id = $2 AND status = 'pending'
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -64,7 +142,93 @@ func (m ReservationModel) UpdateStatus(reservationId int64, status string) error
 
 	args := []interface{}{status, reservationId}
 
-	_, err := m.DB.ExecContext(ctx, query, args...)
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// RefundCancelled refunds a ZaloPay payment made for a reservation after it
+// was cancelled to the wallet. Payments for reservations that were never
+// cancelled, or were already refunded, are left alone.
+func (m ReservationModel) RefundCancelled(reservationId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE cancelled_reservations
+		SET refunded_at = NOW()
+		WHERE reservation_id = $1 AND refunded_at IS NULL
+		RETURNING user_id, amount
+	`
+
+	var userId, amount int64
+
+	err = tx.QueryRowContext(ctx, query, reservationId).Scan(&userId, &amount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	reference := "reservation:" + strconv.FormatInt(reservationId, 10)
+
+	_, err = postWalletTransaction(ctx, tx, userId, amount, entity.AccountSales, entity.WalletRefund, reference)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPaymentIdentity records who paid for a reservation, as identified by
+// the payment provider.
+func (m ReservationModel) SetPaymentIdentity(reservationId int64, identity string) error {
+	query := `
+		UPDATE reservations
+		SET payment_identity = $2
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, reservationId, identity)
+	return err
+}
+
+// CheckIn marks a paid reservation as used. It returns ErrEditConflict if the
+// reservation is not paid or has already been checked in.
+func (m ReservationModel) CheckIn(reservation *entity.Reservation) error {
+	query := `
+		UPDATE reservations
+		SET checked_in_at = NOW()
+		WHERE id = $1 AND status = 'success' AND checked_in_at IS NULL
+		RETURNING checked_in_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, reservation.ID).Scan(&reservation.CheckedInAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -77,13 +241,42 @@ func (m ReservationModel) UpdateStatus(reservationId int64, status string) error
 	return nil
 }
 
+// HasAttended reports whether the user holds a paid or checked-in reservation
+// for a show of the movie that has already started.
+func (m ReservationModel) HasAttended(userId, movieId int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM reservations r
+			INNER JOIN shows s ON r.show_id = s.id
+			WHERE r.user_id = $1
+			AND s.movie_id = $2
+			AND s.showtime < NOW()
+			AND (r.status = 'success' OR r.checked_in_at IS NOT NULL)
+		)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var attended bool
+
+	err := m.DB.QueryRowContext(ctx, query, userId, movieId).Scan(&attended)
+	if err != nil {
+		return false, err
+	}
+
+	return attended, nil
+}
+
 func (m ReservationModel) GetById(id int64) (*entity.Reservation, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
 
 	query := `
-	SELECT id, user_id, amount, show_id, status
+	SELECT id, created_at, user_id, amount, discount, wallet_amount, points_redeemed, points_discount, points_earned,
+		concessions_amount, COALESCE(pickup_code, ''), show_id, status, checked_in_at, COALESCE(ticket_token, ''), transferred_from
 	FROM reservations
 	WHERE id = $1
 `
@@ -94,10 +287,21 @@ func (m ReservationModel) GetById(id int64) (*entity.Reservation, error) {
 
 	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&reservation.ID,
+		&reservation.CreatedAt,
 		&reservation.UserId,
 		&reservation.Amount,
+		&reservation.Discount,
+		&reservation.WalletAmount,
+		&reservation.PointsRedeemed,
+		&reservation.PointsDiscount,
+		&reservation.PointsEarned,
+		&reservation.ConcessionsAmount,
+		&reservation.PickupCode,
 		&reservation.ShowId,
-		&reservation.Status)
+		&reservation.Status,
+		&reservation.CheckedInAt,
+		&reservation.TicketToken,
+		&reservation.TransferredFrom)
 
 	if err != nil {
 		switch {
@@ -107,21 +311,226 @@ func (m ReservationModel) GetById(id int64) (*entity.Reservation, error) {
 			return nil, err
 		}
 	}
+
+	reservation.Tickets, err = m.getTickets(ctx, reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation.Concessions, err = m.getConcessions(ctx, reservation.ID)
+	if err != nil {
+		return nil, err
+	}
+
 	return &reservation, nil
 }
 
+// GetByTicketToken returns the reservation a ticket token was issued for.
+// Tokens replaced by a transfer are no longer found.
+func (m ReservationModel) GetByTicketToken(token string) (*entity.Reservation, error) {
+	query := `
+		SELECT id
+		FROM reservations
+		WHERE ticket_token = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, query, token).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return m.GetById(id)
+}
+
+// getTickets returns the seats held by a reservation with the ticket type
+// and price each was sold at.
+func (m ReservationModel) getTickets(ctx context.Context, reservationId int64) ([]*entity.Ticket, error) {
+	query := `
+		SELECT st.id, st.row, st.number, st.category, rs.ticket_type, COALESCE(rs.price, 0), rs.paid_price
+		FROM reservation_seat rs
+		INNER JOIN seats st ON st.id = rs.seat_id
+		WHERE rs.reservation_id = $1
+		ORDER BY st.row ASC, st.number ASC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickets := []*entity.Ticket{}
+	for rows.Next() {
+		var ticket entity.Ticket
+
+		err := rows.Scan(
+			&ticket.SeatId,
+			&ticket.Row,
+			&ticket.Number,
+			&ticket.Category,
+			&ticket.TicketType,
+			&ticket.Price,
+			&ticket.PaidPrice,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		tickets = append(tickets, &ticket)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickets, nil
+}
+
+// getConcessions returns the food and drink items of a reservation with the
+// price each was sold at.
+func (m ReservationModel) getConcessions(ctx context.Context, reservationId int64) ([]*entity.ConcessionItem, error) {
+	query := `
+		SELECT rc.concession_id, c.name, rc.quantity, rc.price
+		FROM reservation_concessions rc
+		INNER JOIN concessions c ON c.id = rc.concession_id
+		WHERE rc.reservation_id = $1
+		ORDER BY c.name ASC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, reservationId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []*entity.ConcessionItem{}
+	for rows.Next() {
+		var item entity.ConcessionItem
+
+		err := rows.Scan(
+			&item.ConcessionId,
+			&item.Name,
+			&item.Quantity,
+			&item.Price,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Delete cancels an unpaid reservation. Any promo code redeemed for it is
+// reversed so the use no longer counts towards the code's limits, the part
+// paid from the wallet is refunded, loyalty points redeemed or earned on it
+// are given or taken back and its concessions go back in stock. The
+// reservation is recorded as cancelled so that a late payment of its ZaloPay
+// order can be refunded by RefundCancelled. It returns ErrEditConflict if the
+// reservation has been paid.
 func (m ReservationModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	reverseQuery := `
+		UPDATE promo_redemptions
+		SET reversed_at = NOW()
+		WHERE reservation_id = $1 AND reversed_at IS NULL
+	`
+
+	_, err = tx.ExecContext(ctx, reverseQuery, id)
+	if err != nil {
+		return err
+	}
+
+	var status string
+	var userId, amount, walletAmount, pointsRedeemed, pointsEarned int64
+
+	selectQuery := `
+		SELECT status, user_id, amount, wallet_amount, points_redeemed, points_earned
+		FROM reservations
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	err = tx.QueryRowContext(ctx, selectQuery, id).Scan(&status, &userId, &amount, &walletAmount, &pointsRedeemed, &pointsEarned)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if status != "pending" {
+		return ErrEditConflict
+	}
+
+	if walletAmount > 0 {
+		reference := "reservation:" + strconv.FormatInt(id, 10)
+
+		_, err = postWalletTransaction(ctx, tx, userId, walletAmount, entity.AccountSales, entity.WalletRefund, reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = refundLoyaltyPoints(ctx, tx, userId, id, pointsRedeemed, pointsEarned)
+	if err != nil {
+		return err
+	}
+
+	err = restoreConcessionStock(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	if amount > walletAmount {
+		cancelQuery := `
+			INSERT INTO cancelled_reservations (reservation_id, user_id, amount)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (reservation_id) DO NOTHING
+		`
+
+		_, err = tx.ExecContext(ctx, cancelQuery, id, userId, amount-walletAmount)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		DELETE FROM reservations
-		WHERE id = $1
+		WHERE id = $1 AND status = 'pending'
 	`
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -132,15 +541,16 @@ func (m ReservationModel) Delete(id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return ErrRecordNotFound
+		return ErrEditConflict
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func (m ReservationModel) GetAll(userId, showId int64, date time.Time, filters Filters) ([]*entity.Reservation, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, user_id, amount, show_id, status
+		SELECT count(*) OVER(), id, created_at, user_id, amount, discount, wallet_amount, points_redeemed, points_discount, points_earned,
+		concessions_amount, COALESCE(pickup_code, ''), show_id, status, checked_in_at, COALESCE(ticket_token, ''), transferred_from
         FROM reservations
         WHERE (created_at::DATE = $1 OR $1 IS NULL) 
         AND (show_id = $2 OR $2 = 0)
@@ -173,8 +583,18 @@ func (m ReservationModel) GetAll(userId, showId int64, date time.Time, filters F
 			&reservation.CreatedAt,
 			&reservation.UserId,
 			&reservation.Amount,
+			&reservation.Discount,
+			&reservation.WalletAmount,
+			&reservation.PointsRedeemed,
+			&reservation.PointsDiscount,
+			&reservation.PointsEarned,
+			&reservation.ConcessionsAmount,
+			&reservation.PickupCode,
 			&reservation.ShowId,
 			&reservation.Status,
+			&reservation.CheckedInAt,
+			&reservation.TicketToken,
+			&reservation.TransferredFrom,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -190,3 +610,21 @@ func (m ReservationModel) GetAll(userId, showId int64, date time.Time, filters F
 	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
 	return reservations, metadata, nil
 }
+
+// generateTicketToken returns a random token identifying the tickets of a
+// reservation at the door.
+func generateTicketToken() (string, error) {
+	randomBytes := make([]byte, 16)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+func ValidateTicketToken(v *validator.Validator, token string) {
+	v.Check(token != "", "ticket_token", "must be provided")
+	v.Check(len(token) == 26, "ticket_token", "must be 26 bytes long")
+}
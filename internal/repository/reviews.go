@@ -0,0 +1,304 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+type ReviewModel struct {
+	DB *sql.DB
+}
+
+// refreshMovieRating recalculates the denormalised rating aggregate of a
+// movie. Hidden reviews do not count towards it.
+func refreshMovieRating(ctx context.Context, tx *sql.Tx, movieId int64) error {
+	query := `
+		UPDATE movies
+		SET rating_average = COALESCE(r.average, 0), rating_count = r.count
+		FROM (
+			SELECT AVG(rating)::numeric(3, 2) AS average, COUNT(*) AS count
+			FROM reviews
+			WHERE movie_id = $1 AND status <> 'hidden'
+		) r
+		WHERE movies.id = $1
+	`
+
+	_, err := tx.ExecContext(ctx, query, movieId)
+	return err
+}
+
+func (m ReviewModel) Insert(review *entity.Review) error {
+	query := `
+		INSERT INTO reviews(movie_id, user_id, rating, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status, version
+	`
+
+	args := []interface{}{
+		review.MovieId,
+		review.UserId,
+		review.Rating,
+		review.Body,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.Status, &review.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23503":
+				return ErrViolatesForeignKey
+			case "23505":
+				return ErrDuplicateConstraint
+			}
+		}
+		return err
+	}
+
+	err = refreshMovieRating(ctx, tx, review.MovieId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ReviewModel) Get(id int64) (*entity.Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT r.id, r.created_at, r.movie_id, r.user_id, u.name, r.rating, r.body, r.status, r.version
+		FROM reviews r
+		INNER JOIN users u ON r.user_id = u.id
+		WHERE r.id = $1
+	`
+
+	var review entity.Review
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.MovieId,
+		&review.UserId,
+		&review.UserName,
+		&review.Rating,
+		&review.Body,
+		&review.Status,
+		&review.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+// GetAll lists reviews for a movie (or all movies when movieId is 0) with
+// one of the given statuses.
+func (m ReviewModel) GetAll(movieId int64, statuses []string, filters Filters) ([]*entity.Review, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), r.id, r.created_at, r.movie_id, r.user_id, u.name, r.rating, r.body, r.status, r.version
+		FROM reviews r
+		INNER JOIN users u ON r.user_id = u.id
+		WHERE (r.movie_id = $1 OR $1 = 0)
+		AND r.status = ANY($2)
+		ORDER BY r.%s %s, r.id ASC
+		LIMIT $3 OFFSET $4
+	`, filters.sortColumn(), filters.sortDirection(),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{movieId, pq.Array(statuses), filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	reviews := []*entity.Review{}
+	for rows.Next() {
+		var review entity.Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.MovieId,
+			&review.UserId,
+			&review.UserName,
+			&review.Rating,
+			&review.Body,
+			&review.Status,
+			&review.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return reviews, metadata, nil
+}
+
+// Moderate applies a moderation action to a review and records it in the
+// audit trail in the same transaction.
+func (m ReviewModel) Moderate(review *entity.Review, moderation *entity.ReviewModeration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	switch moderation.Action {
+	case entity.ModerationDelete:
+		result, err := tx.ExecContext(ctx, `DELETE FROM reviews WHERE id = $1 AND version = $2`, review.ID, review.Version)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrEditConflict
+		}
+	default:
+		status := map[string]string{
+			entity.ModerationHide:   entity.ReviewHidden,
+			entity.ModerationUnhide: entity.ReviewVisible,
+			entity.ModerationFlag:   entity.ReviewFlagged,
+		}[moderation.Action]
+
+		query := `
+			UPDATE reviews
+			SET status = $1, version = version + 1
+			WHERE id = $2 AND version = $3
+			RETURNING status, version
+		`
+
+		err := tx.QueryRowContext(ctx, query, status, review.ID, review.Version).Scan(&review.Status, &review.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+	}
+
+	query := `
+		INSERT INTO review_moderations(review_id, moderator_id, action, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+
+	args := []interface{}{review.ID, moderation.ModeratorId, moderation.Action, moderation.Reason}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&moderation.ID, &moderation.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = refreshMovieRating(ctx, tx, review.MovieId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ReviewModel) GetModerations(reviewId int64) ([]*entity.ReviewModeration, error) {
+	query := `
+		SELECT id, created_at, review_id, moderator_id, action, reason
+		FROM review_moderations
+		WHERE review_id = $1
+		ORDER BY id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, reviewId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderations := []*entity.ReviewModeration{}
+	for rows.Next() {
+		var moderation entity.ReviewModeration
+
+		err := rows.Scan(
+			&moderation.ID,
+			&moderation.CreatedAt,
+			&moderation.ReviewId,
+			&moderation.ModeratorId,
+			&moderation.Action,
+			&moderation.Reason,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		moderations = append(moderations, &moderation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return moderations, nil
+}
+
+func ValidateReview(v *validator.Validator, review *entity.Review) {
+	v.Check(review.Rating >= 1 && review.Rating <= 5, "rating", "must be between 1 and 5")
+	v.Check(len(review.Body) <= 5000, "body", "must not be more than 5000 bytes long")
+}
+
+func ValidateModeration(v *validator.Validator, moderation *entity.ReviewModeration) {
+	v.Check(validator.In(moderation.Action, entity.ModerationHide, entity.ModerationUnhide, entity.ModerationFlag, entity.ModerationDelete), "action", "must be one of hide, unhide, flag or delete")
+	v.Check(len(moderation.Reason) <= 1000, "reason", "must not be more than 1000 bytes long")
+}
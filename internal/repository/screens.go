@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"greenlight.zuyanh.net/internal/entity"
 	"time"
@@ -17,38 +18,73 @@ type ScreenModel struct {
 
 func (m ScreenModel) Insert(screen *entity.Screen) error {
 	query := `
-		INSERT INTO screens(number, theatre_id)
-		VALUES ($1, $2)
-		RETURNING id
+		INSERT INTO screens(number, theatre_id, formats)
+		VALUES ($1, $2, $3)
+		RETURNING id, version
 	`
 
 	args := []interface{}{
 		screen.Number,
 		screen.Theatre_id,
+		pq.Array(screen.Formats),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&screen.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&screen.ID, &screen.Version)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23503" {
-				return ErrViolatesForeignKey
-			}
-		} else {
-			return err
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
 		}
+		return err
 	}
 
 	return nil
 }
 
+func (m ScreenModel) Get(id int64) (*entity.Screen, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, number, theatre_id, formats, version
+		FROM screens
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var screen entity.Screen
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&screen.ID,
+		&screen.Number,
+		&screen.Theatre_id,
+		pq.Array(&screen.Formats),
+		&screen.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &screen, nil
+}
+
 func (m ScreenModel) GetAll(theatreId int64, filters Filters) ([]*entity.Screen, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, number, theatre_id
+		SELECT count(*) OVER(), id, number, theatre_id, formats, version
 		FROM screens
-		WHERE theatre_id = $1 OR $1 = 0
+		WHERE (theatre_id = $1 OR $1 = 0)
+		AND deleted_at IS NULL
 		ORDER BY %s %s, id ASC
 		LIMIT $2 OFFSET $3
 	`, filters.sortColumn(), filters.sortDirection(),
@@ -76,6 +112,8 @@ func (m ScreenModel) GetAll(theatreId int64, filters Filters) ([]*entity.Screen,
 			&screen.ID,
 			&screen.Number,
 			&screen.Theatre_id,
+			pq.Array(&screen.Formats),
+			&screen.Version,
 		)
 
 		if err != nil {
@@ -93,11 +131,14 @@ func (m ScreenModel) GetAll(theatreId int64, filters Filters) ([]*entity.Screen,
 	return screens, metadata, nil
 }
 
+// Update changes a screen. It returns ErrUnsupportedFormat when a format is
+// dropped while upcoming shows on the screen are still scheduled in it.
 func (m ScreenModel) Update(screen *entity.Screen) error {
 	query := `
 		UPDATE screens
-		SET number = $1, theatre_id = $2
-		WHERE id = $3
+		SET number = $1, theatre_id = $2, formats = $3, version = version + 1
+		WHERE id = $4 AND version = $5
+		RETURNING version
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -106,39 +147,86 @@ func (m ScreenModel) Update(screen *entity.Screen) error {
 	args := []interface{}{
 		screen.Number,
 		screen.Theatre_id,
+		pq.Array(screen.Formats),
 		screen.ID,
+		screen.Version,
+	}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	formatInUseQuery := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM shows
+			WHERE screen_id = $1
+			AND deleted_at IS NULL
+			AND showtime > NOW()
+			AND NOT (format = ANY($2))
+		)
+	`
 
-	_, err := m.DB.ExecContext(ctx, query, args)
+	var inUse bool
 
+	err = tx.QueryRowContext(ctx, formatInUseQuery, screen.ID, pq.Array(screen.Formats)).Scan(&inUse)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if inUse {
+		return ErrUnsupportedFormat
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&screen.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
+// Delete archives a screen with its seats and shows. It returns
+// ErrActiveReservations while any of its upcoming shows has paid bookings.
 func (m ScreenModel) Delete(screenId int64) error {
-	query := `
-		DELETE FROM screens
-		WHERE id = $1
-	`
+	if screenId < 1 {
+		return ErrRecordNotFound
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, screenId)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedScreen, screenId)
+	if err != nil {
+		return err
+	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	if reserved {
+		return ErrActiveReservations
 	}
 
-	return nil
+	err = archive(ctx, tx, entity.ArchiveScreen, screenId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func ValidateScreens(v *validator.Validator, screen *entity.Screen) {
@@ -146,4 +234,10 @@ func ValidateScreens(v *validator.Validator, screen *entity.Screen) {
 
 	v.Check(screen.Number > 0, "number", "must be a positive integer")
 	v.Check(screen.Theatre_id > 0, "theatre_id", "must be a positive integer")
+
+	v.Check(len(screen.Formats) >= 1, "formats", "must contain at least 1 format")
+	v.Check(validator.Unique(screen.Formats), "formats", "must not contain duplicate values")
+	for _, format := range screen.Formats {
+		v.Check(validator.In(format, entity.Formats...), "formats", "must only contain 2d, 3d, imax or 4dx")
+	}
 }
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+// headlineOptions wraps matched fragments in <b> tags for the search bar.
+const headlineOptions = "StartSel=<b>, StopSel=</b>, HighlightAll=true"
+
+type SearchModel struct {
+	DB *sql.DB
+}
+
+// prefixQuery turns free text into a tsquery matching every word as a
+// prefix, so "aven end" finds "Avengers: Endgame" while the user is still
+// typing. Only letters and digits are kept to avoid tsquery syntax errors.
+func prefixQuery(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i := range words {
+		words[i] += ":*"
+	}
+
+	return strings.Join(words, " & ")
+}
+
+// Search looks for q across movies, theatres, people and upcoming shows,
+// returning at most limit results of each of the requested types ordered by
+// rank.
+func (m SearchModel) Search(q string, types []string, limit int) ([]*entity.SearchResult, error) {
+	results := []*entity.SearchResult{}
+
+	tsquery := prefixQuery(q)
+	if tsquery == "" {
+		return results, nil
+	}
+
+	queries := map[string]string{
+		entity.SearchMovie: `
+			SELECT id, title, year::text,
+				ts_headline('simple', title, to_tsquery('simple', $1), $3),
+				NULL::timestamp,
+				ts_rank(to_tsvector('simple', title), to_tsquery('simple', $1))
+			FROM movies
+			WHERE to_tsvector('simple', title) @@ to_tsquery('simple', $1)
+			AND deleted_at IS NULL
+			ORDER BY 6 DESC, rating_count DESC, id ASC
+			LIMIT $2
+		`,
+		entity.SearchTheatre: `
+			SELECT id, name, city,
+				ts_headline('simple', name || ' ' || city, to_tsquery('simple', $1), $3),
+				NULL::timestamp,
+				ts_rank(to_tsvector('simple', name || ' ' || city), to_tsquery('simple', $1))
+			FROM theatres
+			WHERE to_tsvector('simple', name || ' ' || city) @@ to_tsquery('simple', $1)
+			AND deleted_at IS NULL
+			ORDER BY 6 DESC, id ASC
+			LIMIT $2
+		`,
+		entity.SearchPerson: `
+			SELECT id, name, '',
+				ts_headline('simple', name, to_tsquery('simple', $1), $3),
+				NULL::timestamp,
+				ts_rank(to_tsvector('simple', name), to_tsquery('simple', $1))
+			FROM people
+			WHERE to_tsvector('simple', name) @@ to_tsquery('simple', $1)
+			ORDER BY 6 DESC, id ASC
+			LIMIT $2
+		`,
+		entity.SearchShow: `
+			SELECT s.id, m.title, t.name,
+				ts_headline('simple', m.title, to_tsquery('simple', $1), $3),
+				s.showtime,
+				ts_rank(to_tsvector('simple', m.title), to_tsquery('simple', $1))
+			FROM shows s
+			INNER JOIN movies m ON s.movie_id = m.id
+			INNER JOIN screens sc ON s.screen_id = sc.id
+			INNER JOIN theatres t ON sc.theatre_id = t.id
+			WHERE to_tsvector('simple', m.title) @@ to_tsquery('simple', $1)
+			AND s.showtime > NOW()
+			AND s.deleted_at IS NULL
+			ORDER BY 6 DESC, s.showtime ASC, s.id ASC
+			LIMIT $2
+		`,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, resultType := range types {
+		query, ok := queries[resultType]
+		if !ok {
+			continue
+		}
+
+		rows, err := m.DB.QueryContext(ctx, query, tsquery, limit, headlineOptions)
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			result := entity.SearchResult{Type: resultType}
+
+			err := rows.Scan(
+				&result.ID,
+				&result.Title,
+				&result.Subtitle,
+				&result.Highlight,
+				&result.Showtime,
+				&result.Rank,
+			)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			results = append(results, &result)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+
+	return results, nil
+}
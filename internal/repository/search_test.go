@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixQuery(t *testing.T) {
+	assert.Equal(t, "aven:* & end:*", prefixQuery("Aven end"))
+	assert.Equal(t, "lật:* & mặt:*", prefixQuery("  Lật   Mặt! "))
+	assert.Equal(t, "o:* & brien:*", prefixQuery("O'Brien"))
+	assert.Equal(t, "", prefixQuery("&|!():*"))
+}
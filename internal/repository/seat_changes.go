@@ -0,0 +1,382 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+var (
+	ErrSeatUnavailable   = errors.New("seat not available")
+	ErrSeatChangePending = errors.New("seat already has a pending seat change")
+)
+
+type SeatChangeModel struct {
+	DB *sql.DB
+}
+
+// Insert holds the new seats of a change. Changes that cost nothing extra
+// are applied straight away, with any difference refunded to the wallet;
+// the others stay pending until Complete or Expire. It returns
+// ErrSeatUnavailable if a new seat is already taken, ErrSeatListed if a seat
+// being moved is listed for resale, ErrSeatChangePending if it is already
+// being changed and ErrTransferPending if it is being transferred.
+func (m SeatChangeModel) Insert(change *entity.SeatChange) error {
+	items, err := json.Marshal(change.Items)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var showId int64
+
+	err = tx.QueryRowContext(ctx, `SELECT show_id FROM reservations WHERE id = $1 FOR UPDATE`, change.ReservationId).Scan(&showId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	fromSeatIds := make([]int64, len(change.Items))
+	toSeatIds := make([]int64, len(change.Items))
+	for i, item := range change.Items {
+		fromSeatIds[i] = item.FromSeatId
+		toSeatIds[i] = item.ToSeatId
+	}
+
+	listed, err := hasActiveListing(ctx, tx, change.ReservationId, fromSeatIds)
+	if err != nil {
+		return err
+	}
+
+	if listed {
+		return ErrSeatListed
+	}
+
+	changing, err := hasPendingSeatChange(ctx, tx, change.ReservationId, fromSeatIds)
+	if err != nil {
+		return err
+	}
+
+	if changing {
+		return ErrSeatChangePending
+	}
+
+	transferring, err := hasPendingTransfer(ctx, tx, change.ReservationId, fromSeatIds)
+	if err != nil {
+		return err
+	}
+
+	if transferring {
+		return ErrTransferPending
+	}
+
+	holdQuery := `
+		UPDATE seat_status
+		SET available = false
+		WHERE show_id = $1 AND seat_id = ANY($2) AND available
+	`
+
+	result, err := tx.ExecContext(ctx, holdQuery, showId, pq.Array(toSeatIds))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(toSeatIds)) {
+		return ErrSeatUnavailable
+	}
+
+	query := `
+		INSERT INTO seat_changes (reservation_id, items, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, status
+	`
+
+	args := []interface{}{change.ReservationId, items, change.Amount}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&change.ID, &change.CreatedAt, &change.Status)
+	if err != nil {
+		return err
+	}
+
+	if change.Amount <= 0 {
+		err = applySeatChange(ctx, tx, change, showId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Complete applies a pending change once its difference has been paid. A
+// payment that arrives after the change expired, or for a change whose seats
+// were moved by something else in the meantime, is refunded to the wallet
+// instead. Changes that were already applied or refunded are left alone.
+func (m SeatChangeModel) Complete(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statusQuery := `
+		SELECT sc.status, sc.amount, r.user_id
+		FROM seat_changes sc
+		INNER JOIN reservations r ON r.id = sc.reservation_id
+		WHERE sc.id = $1
+		FOR UPDATE OF sc
+	`
+
+	var status string
+	var amount, userId int64
+
+	err = tx.QueryRowContext(ctx, statusQuery, id).Scan(&status, &amount, &userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	if status == entity.SeatChangeExpired {
+		err = refundSeatChange(ctx, tx, id, userId, amount)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	}
+
+	change, showId, err := lockPendingSeatChange(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `SAVEPOINT apply_seat_change`)
+	if err != nil {
+		return err
+	}
+
+	err = applySeatChange(ctx, tx, change, showId)
+	if err != nil {
+		if !errors.Is(err, ErrEditConflict) {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT apply_seat_change`)
+		if err != nil {
+			return err
+		}
+
+		err = releaseSeatChange(ctx, tx, change, showId)
+		if err != nil {
+			return err
+		}
+
+		err = refundSeatChange(ctx, tx, id, userId, amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Expire gives up a change that was not paid in time and releases the seats
+// it held. Changes that are no longer pending are left alone.
+func (m SeatChangeModel) Expire(id int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	change, showId, err := lockPendingSeatChange(ctx, tx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	err = releaseSeatChange(ctx, tx, change, showId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// releaseSeatChange frees the seats held by a pending change and marks it
+// expired.
+func releaseSeatChange(ctx context.Context, tx *sql.Tx, change *entity.SeatChange, showId int64) error {
+	toSeatIds := make([]int64, len(change.Items))
+	for i, item := range change.Items {
+		toSeatIds[i] = item.ToSeatId
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE seat_status SET available = true WHERE show_id = $1 AND seat_id = ANY($2)`, showId, pq.Array(toSeatIds))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE seat_changes SET status = 'expired' WHERE id = $1`, change.ID)
+	return err
+}
+
+// refundSeatChange pays the difference paid for a change that cannot be
+// applied back to the wallet and marks the change so it is only refunded
+// once.
+func refundSeatChange(ctx context.Context, tx *sql.Tx, id, userId, amount int64) error {
+	reference := "seat_change:" + strconv.FormatInt(id, 10)
+
+	_, err := postWalletTransaction(ctx, tx, userId, amount, entity.AccountSales, entity.WalletRefund, reference)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE seat_changes SET status = 'refunded' WHERE id = $1`, id)
+	return err
+}
+
+// hasPendingSeatChange reports whether any of the seats of a reservation is
+// being moved by a change that is waiting for payment.
+func hasPendingSeatChange(ctx context.Context, tx *sql.Tx, reservationId int64, seatIds []int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM seat_changes sc, jsonb_array_elements(sc.items) item
+			WHERE sc.reservation_id = $1 AND sc.status = 'pending'
+			AND (item->>'from_seat_id')::bigint = ANY($2)
+		)
+	`
+
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, query, reservationId, pq.Array(seatIds)).Scan(&exists)
+	return exists, err
+}
+
+func lockPendingSeatChange(ctx context.Context, tx *sql.Tx, id int64) (*entity.SeatChange, int64, error) {
+	query := `
+		SELECT sc.id, sc.created_at, sc.reservation_id, sc.items, sc.amount, sc.status, r.show_id
+		FROM seat_changes sc
+		INNER JOIN reservations r ON r.id = sc.reservation_id
+		WHERE sc.id = $1 AND sc.status = 'pending'
+		FOR UPDATE
+	`
+
+	var change entity.SeatChange
+	var items []byte
+	var showId int64
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&change.ID,
+		&change.CreatedAt,
+		&change.ReservationId,
+		&items,
+		&change.Amount,
+		&change.Status,
+		&showId,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = json.Unmarshal(items, &change.Items)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &change, showId, nil
+}
+
+// applySeatChange moves the tickets to their new seats at the new prices,
+// frees the old seats and adjusts the amount of the reservation. A negative
+// difference is refunded to the wallet. What was paid for each ticket moves by
+// its price difference, which is never refunded below zero.
+func applySeatChange(ctx context.Context, tx *sql.Tx, change *entity.SeatChange, showId int64) error {
+	moveQuery := `
+		UPDATE reservation_seat
+		SET seat_id = $3, price = $4, paid_price = GREATEST(paid_price + $4 - COALESCE(price, 0), 0)
+		WHERE reservation_id = $1 AND seat_id = $2
+	`
+
+	fromSeatIds := make([]int64, len(change.Items))
+
+	for i, item := range change.Items {
+		args := []interface{}{change.ReservationId, item.FromSeatId, item.ToSeatId, item.Price}
+
+		result, err := tx.ExecContext(ctx, moveQuery, args...)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected == 0 {
+			return ErrEditConflict
+		}
+
+		fromSeatIds[i] = item.FromSeatId
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE seat_status SET available = true WHERE show_id = $1 AND seat_id = ANY($2)`, showId, pq.Array(fromSeatIds))
+	if err != nil {
+		return err
+	}
+
+	var userId int64
+
+	err = tx.QueryRowContext(ctx, `UPDATE reservations SET amount = amount + $2 WHERE id = $1 RETURNING user_id`, change.ReservationId, change.Amount).Scan(&userId)
+	if err != nil {
+		return err
+	}
+
+	if change.Amount < 0 {
+		reference := "seat_change:" + strconv.FormatInt(change.ID, 10)
+
+		_, err = postWalletTransaction(ctx, tx, userId, -change.Amount, entity.AccountSales, entity.WalletRefund, reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, `UPDATE seat_changes SET status = 'success' WHERE id = $1 RETURNING status`, change.ID).Scan(&change.Status)
+	return err
+}
@@ -20,14 +20,15 @@ type SeatModel struct {
 
 func (m SeatModel) Insert(seat *entity.Seat) error {
 	query := `
-		INSERT INTO seats(row, number, price, screen_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
+		INSERT INTO seats(row, number, category, price, screen_id)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+		RETURNING id, version
 	`
 
 	args := []interface{}{
 		seat.Row,
 		seat.Number,
+		seat.Category,
 		seat.Price,
 		seat.Screen_id,
 	}
@@ -35,13 +36,96 @@ func (m SeatModel) Insert(seat *entity.Seat) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&seat.ID)
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&seat.ID, &seat.Version)
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23503" {
+			switch pqErr.Code {
+			case "23503":
 				return ErrViolatesForeignKey
+			case "23505":
+				return ErrDuplicateConstraint
 			}
-		} else {
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m SeatModel) Get(id int64) (*entity.Seat, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, row, number, category, COALESCE(price, 0), screen_id, version
+		FROM seats
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var seat entity.Seat
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&seat.ID,
+		&seat.Row,
+		&seat.Number,
+		&seat.Category,
+		&seat.Price,
+		&seat.Screen_id,
+		&seat.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &seat, nil
+}
+
+// Update changes the label, category and price of a seat. Moving a seat to
+// another screen is not supported; delete it and create a new one instead.
+func (m SeatModel) Update(seat *entity.Seat) error {
+	query := `
+		UPDATE seats
+		SET row = $1, number = $2, category = $3, price = NULLIF($4, 0), version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING version
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{
+		seat.Row,
+		seat.Number,
+		seat.Category,
+		seat.Price,
+		seat.ID,
+		seat.Version,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&seat.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok {
+			switch pqErr.Code {
+			case "23503":
+				return ErrViolatesForeignKey
+			case "23505":
+				return ErrDuplicateConstraint
+			}
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
 			return err
 		}
 	}
@@ -49,6 +133,39 @@ func (m SeatModel) Insert(seat *entity.Seat) error {
 	return nil
 }
 
+// Delete archives a seat. It returns ErrActiveReservations while the seat is
+// held by a paid booking for an upcoming show.
+func (m SeatModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedSeat, id)
+	if err != nil {
+		return err
+	}
+
+	if reserved {
+		return ErrActiveReservations
+	}
+
+	err = archive(ctx, tx, entity.ArchiveSeat, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (m SeatModel) InsertSeatStatus(showId int64, seatIds []int64) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -89,9 +206,10 @@ func (m SeatModel) InsertSeatStatus(showId int64, seatIds []int64) error {
 
 func (m SeatModel) GetAllByScreenId(screenId int64) ([]*entity.Seat, error) {
 	query := `
-		SELECT id, row, number, price, screen_id
+		SELECT id, row, number, category, COALESCE(price, 0), screen_id, version
 		FROM seats
-		WHERE screen_id = $1
+		WHERE screen_id = $1 AND deleted_at IS NULL
+		ORDER BY row ASC, number ASC
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -111,8 +229,10 @@ func (m SeatModel) GetAllByScreenId(screenId int64) ([]*entity.Seat, error) {
 			&seat.ID,
 			&seat.Row,
 			&seat.Number,
+			&seat.Category,
 			&seat.Price,
 			&seat.Screen_id,
+			&seat.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -128,19 +248,27 @@ func (m SeatModel) GetAllByScreenId(screenId int64) ([]*entity.Seat, error) {
 	return seats, nil
 }
 
+// GetAllByShowId lists the seats of a show with their effective price for
+// it, before the format surcharge.
 func (m SeatModel) GetAllByShowId(showId int64, status string, filters Filters) ([]*entity.Seat, Metadata, error) {
 	query := fmt.Sprintf(`
 		SELECT COUNT(*) OVER(),
 			s.id,
 			s.row,
 			s.number,
-			s.price,
-			s.screen_id
-		FROM seats s 
+			s.category,
+			COALESCE(s.price, 0),
+			s.screen_id,
+			s.version
+		FROM (
+			SELECT id, row, number, category, show_seat_price($1, id) AS price, screen_id, version, deleted_at
+			FROM seats
+		) s
 		INNER JOIN shows sh ON s.screen_id = sh.screen_id
-		INNER JOIN seat_status sst ON s.id = sst.seat_id
-		WHERE sh.show_id = $1 AND (sst.status = $2 OR $2 = '')
-		ORDER BY %s %s, id ASC
+		INNER JOIN seat_status sst ON s.id = sst.seat_id AND sst.show_id = sh.id
+		WHERE sh.id = $1 AND s.deleted_at IS NULL
+		AND (($2 = 'available' AND sst.available) OR ($2 = 'booked' AND NOT sst.available) OR $2 = '')
+		ORDER BY s.%s %s, s.id ASC
 		LIMIT $3 OFFSET $4
 	`, filters.sortColumn(), filters.sortDirection())
 
@@ -166,8 +294,10 @@ func (m SeatModel) GetAllByShowId(showId int64, status string, filters Filters)
 			&seat.ID,
 			&seat.Row,
 			&seat.Number,
+			&seat.Category,
 			&seat.Price,
 			&seat.Screen_id,
+			&seat.Version,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -185,18 +315,43 @@ func (m SeatModel) GetAllByShowId(showId int64, status string, filters Filters)
 
 }
 
-func (m SeatModel) UpdateSeatStatus(status bool, showId int64, seatId []int64) (int64, error) {
+// Occupancy returns the percentage of a show's seats that are booked.
+func (m SeatModel) Occupancy(showId int64) (float64, error) {
+	query := `
+		SELECT COALESCE(100.0 * count(*) FILTER (WHERE NOT available) / NULLIF(count(*), 0), 0)
+		FROM seat_status
+		WHERE show_id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var occupancy float64
+
+	err := m.DB.QueryRowContext(ctx, query, showId).Scan(&occupancy)
+	if err != nil {
+		return 0, err
+	}
+
+	return occupancy, nil
+}
+
+// UpdateSeatStatus frees the seats of a show, or takes them when status is
+// false. Taking seats returns ErrSeatUnavailable, leaving every seat as it
+// was, if any of them is already taken.
+func (m SeatModel) UpdateSeatStatus(status bool, showId int64, seatId []int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 	defer cancel()
 
 	if len(seatId) == 0 {
-		return 0, errors.New("no seat IDs provided")
+		return errors.New("no seat IDs provided")
 	}
 
 	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, err
+		return err
 	}
+	defer tx.Rollback()
 
 	seatLockQuery := `
 		SELECT *
@@ -205,65 +360,44 @@ func (m SeatModel) UpdateSeatStatus(status bool, showId int64, seatId []int64) (
 		FOR UPDATE
 	`
 
-	priceCalQuery := `
-		SELECT SUM(price)
-		FROM seats
-		WHERE id = ANY($1)
-	`
-
-	type result struct {
-		price int64
-		err   error
-	}
-
-	results := make(chan result, 2)
-	defer close(results)
-
-	go func() {
-		_, err := tx.ExecContext(ctx, seatLockQuery, pq.Array(seatId))
-		results <- result{err: err}
-	}()
-
-	var total int64
-
-	go func() {
-		err := tx.QueryRowContext(ctx, priceCalQuery, pq.Array(seatId)).Scan(&total)
-		results <- result{price: total, err: err}
-	}()
-
-	for i := 0; i < 2; i++ {
-		res := <-results
-		if res.err != nil {
-			tx.Rollback()
-			return 0, res.err
-		}
+	_, err = tx.ExecContext(ctx, seatLockQuery, pq.Array(seatId))
+	if err != nil {
+		return err
 	}
 
 	queryUpdateSeatStatus := `
 		UPDATE seat_status
 		SET available = $1 
-		WHERE show_id = $2 AND seat_id = ANY($3)
+		WHERE show_id = $2 AND seat_id = ANY($3) AND ($1 OR available)
 	`
 
-	_, err = tx.ExecContext(ctx, queryUpdateSeatStatus, status, showId, pq.Array(seatId))
+	result, err := tx.ExecContext(ctx, queryUpdateSeatStatus, status, showId, pq.Array(seatId))
 	if err != nil {
-		tx.Rollback()
-		return 0, err
+		return err
 	}
 
-	err = tx.Commit()
-	if err != nil {
-		return 0, err
+	if !status {
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rowsAffected != int64(len(seatId)) {
+			return ErrSeatUnavailable
+		}
 	}
 
-	return total, nil
+	return tx.Commit()
 }
 
 func ValidateSeat(v *validator.Validator, seat *entity.Seat) {
 	v.Check(seat.Row != "", "row", "must be provided")
-	v.Check(unicode.IsLetter(rune(seat.Row[0])), "row", "must be a alphabet")
+	if seat.Row != "" {
+		v.Check(unicode.IsLetter(rune(seat.Row[0])), "row", "must be a alphabet")
+	}
 
 	v.Check(seat.Number > 0, "number", "must be a positive integer")
-	v.Check(seat.Price > 0, "price", "must be a positive integer")
+	v.Check(seat.Category != "", "category", "must be provided")
+	v.Check(seat.Price >= 0, "price", "must not be negative")
 	v.Check(seat.Screen_id > 0, "screen_id", "must be a positive integer")
 }
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"greenlight.zuyanh.net/internal/entity"
 	"time"
@@ -15,55 +16,133 @@ type ShowModel struct {
 	DB *sql.DB
 }
 
+// Insert schedules a show. It returns ErrUnsupportedFormat when the screen
+// is not equipped for the show's format.
 func (m ShowModel) Insert(show *entity.Show) error {
 	insertShowQuery := `
-		INSERT INTO shows(showtime, movie_id, screen_id)
-		VALUES ($1, $2, $3)
-		RETURNING id
+		INSERT INTO shows(showtime, movie_id, screen_id, format, audio_language, subtitle_language)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, version
 	`
 
 	args := []interface{}{
 		show.Showtime,
 		show.MovieId,
 		show.ScreenId,
+		show.Format,
+		show.AudioLanguage,
+		show.SubtitleLanguage,
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, insertShowQuery, args...).Scan(&show.ID)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23503" {
-				return ErrViolatesForeignKey
-			}
-		} else {
-			return err
+		return err
+	}
+	defer tx.Rollback()
+
+	err = screenSupportsFormat(ctx, tx, show.ScreenId, show.Format)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, insertShowQuery, args...).Scan(&show.ID, &show.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
 		}
+		return err
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+func (m ShowModel) Get(id int64) (*entity.Show, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, showtime, movie_id, screen_id, format, audio_language, subtitle_language, version
+		FROM shows
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var show entity.Show
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&show.ID,
+		&show.Showtime,
+		&show.MovieId,
+		&show.ScreenId,
+		&show.Format,
+		&show.AudioLanguage,
+		&show.SubtitleLanguage,
+		&show.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &show, nil
+}
+
+// ShowSearch holds the optional criteria for listing shows. Empty fields
+// match every show.
+type ShowSearch struct {
+	Date             string
+	Title            string
+	Format           string
+	AudioLanguage    string
+	SubtitleLanguage string
 }
 
-func (m ShowModel) GetAll(date string, title string, filters Filters) ([]*entity.Show, Metadata, error) {
+func (m ShowModel) GetAll(search ShowSearch, filters Filters) ([]*entity.Show, Metadata, error) {
 	query := fmt.Sprintf(`
 		SELECT COUNT(*) OVER(), 
                s.id, 
                s.showtime, 
                s.movie_id,
-               s.screen_id
-		FROM show s
+               s.screen_id,
+               s.format,
+               s.audio_language,
+               s.subtitle_language,
+               s.version
+		FROM shows s
 		INNER JOIN movies m ON s.movie_id = m.id
-		WHERE (to_tsvector('simple', m.title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+		WHERE s.deleted_at IS NULL
+		  AND (to_tsvector('simple', m.title) @@ plainto_tsquery('simple', $1) OR $1 = '')
 		  AND (TO_CHAR(s.showtime::date, 'YYYY-MM-DD') = $2 OR $2 = '')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4;
+		  AND (s.format = $3 OR $3 = '')
+		  AND (s.audio_language = $4 OR $4 = '')
+		  AND (s.subtitle_language = $5 OR $5 = '')
+		ORDER BY s.%s %s, s.id ASC
+		LIMIT $6 OFFSET $7;
 	`, filters.sortColumn(), filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, date, filters.limit(), filters.offset()}
+	args := []interface{}{
+		search.Title,
+		search.Date,
+		search.Format,
+		search.AudioLanguage,
+		search.SubtitleLanguage,
+		filters.limit(),
+		filters.offset(),
+	}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -83,6 +162,10 @@ func (m ShowModel) GetAll(date string, title string, filters Filters) ([]*entity
 			&show.Showtime,
 			&show.MovieId,
 			&show.ScreenId,
+			&show.Format,
+			&show.AudioLanguage,
+			&show.SubtitleLanguage,
+			&show.Version,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -99,14 +182,216 @@ func (m ShowModel) GetAll(date string, title string, filters Filters) ([]*entity
 	return shows, metadata, nil
 }
 
+func (m ShowModel) GetUpcomingForPerson(personId int64) ([]*entity.Show, error) {
+	query := `
+		SELECT s.id, s.showtime, s.movie_id, s.screen_id, s.format, s.audio_language, s.subtitle_language, s.version
+		FROM shows s
+		WHERE s.movie_id IN (SELECT movie_id FROM movie_credits WHERE person_id = $1)
+		  AND s.deleted_at IS NULL
+		  AND s.showtime > NOW()
+		ORDER BY s.showtime ASC, s.id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shows := []*entity.Show{}
+	for rows.Next() {
+		var show entity.Show
+
+		err := rows.Scan(
+			&show.ID,
+			&show.Showtime,
+			&show.MovieId,
+			&show.ScreenId,
+			&show.Format,
+			&show.AudioLanguage,
+			&show.SubtitleLanguage,
+			&show.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		shows = append(shows, &show)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shows, nil
+}
+
+// Update reschedules a show. Moving it to another screen regenerates the
+// seat map, so it returns ErrActiveReservations while the show has paid
+// bookings that hold seats on the old screen. It returns
+// ErrUnsupportedFormat when the screen is not equipped for the format.
+func (m ShowModel) Update(show *entity.Show) error {
+	query := `
+		UPDATE shows
+		SET showtime = $1, movie_id = $2, screen_id = $3, format = $4, audio_language = $5, subtitle_language = $6, version = version + 1
+		WHERE id = $7 AND version = $8
+		RETURNING version
+	`
+
+	args := []interface{}{
+		show.Showtime,
+		show.MovieId,
+		show.ScreenId,
+		show.Format,
+		show.AudioLanguage,
+		show.SubtitleLanguage,
+		show.ID,
+		show.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var screenId int64
+
+	err = tx.QueryRowContext(ctx, `SELECT screen_id FROM shows WHERE id = $1 FOR UPDATE`, show.ID).Scan(&screenId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if screenId != show.ScreenId {
+		reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedShow, show.ID)
+		if err != nil {
+			return err
+		}
+
+		if reserved {
+			return ErrActiveReservations
+		}
+	}
+
+	err = screenSupportsFormat(ctx, tx, show.ScreenId, show.Format)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&show.Version)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if screenId != show.ScreenId {
+		_, err = tx.ExecContext(ctx, `DELETE FROM seat_status WHERE show_id = $1`, show.ID)
+		if err != nil {
+			return err
+		}
+
+		seatStatusQuery := `
+			INSERT INTO seat_status(seat_id, show_id)
+			SELECT id, $1
+			FROM seats
+			WHERE screen_id = $2
+		`
+
+		_, err = tx.ExecContext(ctx, seatStatusQuery, show.ID, show.ScreenId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Delete archives a show. It returns ErrActiveReservations while the show has
+// not started and has paid bookings.
+func (m ShowModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedShow, id)
+	if err != nil {
+		return err
+	}
+
+	if reserved {
+		return ErrActiveReservations
+	}
+
+	err = archive(ctx, tx, entity.ArchiveShow, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// screenSupportsFormat returns ErrViolatesForeignKey when the screen does not
+// exist and ErrUnsupportedFormat when it is not equipped for format.
+func screenSupportsFormat(ctx context.Context, tx *sql.Tx, screenId int64, format string) error {
+	var supported bool
+
+	err := tx.QueryRowContext(ctx, `SELECT $2 = ANY(formats) FROM screens WHERE id = $1 AND deleted_at IS NULL`, screenId, format).Scan(&supported)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrViolatesForeignKey
+		default:
+			return err
+		}
+	}
+
+	if !supported {
+		return ErrUnsupportedFormat
+	}
+
+	return nil
+}
+
 func ValidateShow(v *validator.Validator, show *entity.Show) {
 	v.Check(show.MovieId > 0, "movie_id", "must be a positive integer")
 	v.Check(show.ScreenId > 0, "screen_id", "must be a positive integer")
+
+	v.Check(validator.In(show.Format, entity.Formats...), "format", "must be one of 2d, 3d, imax or 4dx")
+	v.Check(len(show.AudioLanguage) <= 10, "audio_language", "must not be more than 10 bytes long")
+	v.Check(len(show.SubtitleLanguage) <= 10, "subtitle_language", "must not be more than 10 bytes long")
+	v.Check(show.SubtitleLanguage == "" || show.SubtitleLanguage != show.AudioLanguage, "subtitle_language", "must differ from audio_language")
 }
 
 func ValidateDateFormat(v *validator.Validator, date string) {
 	v.Check(date != "", "date", "must be provided")
 
 	_, err := time.Parse("2006-01-02", date)
-	v.Check(err != nil, "date", "date format should be yyyy-mm-dd")
+	v.Check(err == nil, "date", "date format should be yyyy-mm-dd")
 }
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+type TheatreImageModel struct {
+	DB *sql.DB
+}
+
+func (m TheatreImageModel) Insert(image *entity.TheatreImage) error {
+	query := `
+		INSERT INTO theatre_images(theatre_id, url, thumbnail_url)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+
+	args := []interface{}{
+		image.TheatreId,
+		image.URL,
+		image.ThumbnailURL,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m TheatreImageModel) Get(id int64) (*entity.TheatreImage, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, theatre_id, url, thumbnail_url
+		FROM theatre_images
+		WHERE id = $1
+	`
+
+	var image entity.TheatreImage
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&image.ID,
+		&image.CreatedAt,
+		&image.TheatreId,
+		&image.URL,
+		&image.ThumbnailURL,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+func (m TheatreImageModel) GetAllForTheatre(theatreId int64) ([]*entity.TheatreImage, error) {
+	query := `
+		SELECT id, created_at, theatre_id, url, thumbnail_url
+		FROM theatre_images
+		WHERE theatre_id = $1
+		ORDER BY id ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, theatreId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := []*entity.TheatreImage{}
+	for rows.Next() {
+		var image entity.TheatreImage
+
+		err := rows.Scan(
+			&image.ID,
+			&image.CreatedAt,
+			&image.TheatreId,
+			&image.URL,
+			&image.ThumbnailURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		images = append(images, &image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+func (m TheatreImageModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM theatre_images
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -3,51 +3,124 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"greenlight.zuyanh.net/internal/entity"
+	"regexp"
 	"time"
 
 	"github.com/lib/pq"
 	"greenlight.zuyanh.net/internal/validator"
 )
 
+const (
+	// earthRadiusKm and kmPerDegreeLatitude are used by the haversine
+	// distance and the latitude band of nearby searches.
+	earthRadiusKm       = 6371.0
+	kmPerDegreeLatitude = 111.045
+
+	nextShowsPerTheatre = 3
+)
+
+var (
+	openingHoursRX = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]-([01][0-9]|2[0-3]):[0-5][0-9]$`)
+	phoneRX        = regexp.MustCompile(`^\+?[0-9 ().-]{6,20}$`)
+)
+
 type TheatresModel struct {
 	DB *sql.DB
 }
 
 func (m TheatresModel) Insert(theatres *entity.Theatres) error {
+	openingHours, err := json.Marshal(theatres.OpeningHours)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO theatres(name, city)
-		VALUES ($1, $2)
-		RETURNING id
+		INSERT INTO theatres(name, city, address, latitude, longitude, phone, opening_hours, amenities)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, version
 	`
 	args := []interface{}{
 		theatres.Name,
 		theatres.City,
+		theatres.Address,
+		theatres.Latitude,
+		theatres.Longitude,
+		theatres.Phone,
+		openingHours,
+		pq.Array(theatres.Amenities),
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&theatres.ID)
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&theatres.ID, &theatres.Version)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			if pqErr.Code == "23505" {
-				return ErrDuplicateConstraint
-			}
-		} else {
-			return err
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
 		}
+		return err
 	}
 
 	return nil
 }
 
+func (m TheatresModel) Get(id int64) (*entity.Theatres, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, name, city, address, latitude, longitude, phone, opening_hours, amenities, version
+		FROM theatres
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+
+	var theatre entity.Theatres
+	var openingHours []byte
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&theatre.ID,
+		&theatre.Name,
+		&theatre.City,
+		&theatre.Address,
+		&theatre.Latitude,
+		&theatre.Longitude,
+		&theatre.Phone,
+		&openingHours,
+		pq.Array(&theatre.Amenities),
+		&theatre.Version,
+	)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(openingHours, &theatre.OpeningHours)
+	if err != nil {
+		return nil, err
+	}
+
+	return &theatre, nil
+}
+
 func (m TheatresModel) GetAll(city string, filters Filters) ([]*entity.Theatres, Metadata, error) {
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, name, city
+		SELECT count(*) OVER(), id, name, city, address, latitude, longitude, phone, opening_hours, amenities, version
 		FROM theatres
 		WHERE (to_tsvector('simple', city) @@ plainto_tsquery('simple', $1) OR $1 = '') 
+		AND deleted_at IS NULL
 		ORDER BY %s %s, id ASC
 		LIMIT $2 OFFSET $3
 	`, filters.sortColumn(), filters.sortDirection(),
@@ -69,18 +142,31 @@ func (m TheatresModel) GetAll(city string, filters Filters) ([]*entity.Theatres,
 	theatres := []*entity.Theatres{}
 	for rows.Next() {
 		var theatre entity.Theatres
+		var openingHours []byte
 
 		err := rows.Scan(
 			&totalRecords,
 			&theatre.ID,
 			&theatre.Name,
 			&theatre.City,
+			&theatre.Address,
+			&theatre.Latitude,
+			&theatre.Longitude,
+			&theatre.Phone,
+			&openingHours,
+			pq.Array(&theatre.Amenities),
+			&theatre.Version,
 		)
 
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 
+		err = json.Unmarshal(openingHours, &theatre.OpeningHours)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
 		theatres = append(theatres, &theatre)
 	}
 
@@ -92,11 +178,161 @@ func (m TheatresModel) GetAll(city string, filters Filters) ([]*entity.Theatres,
 	return theatres, metadata, nil
 }
 
+// Nearby returns up to limit theatres within radiusKm of the given point,
+// nearest first, using the haversine great-circle distance. Theatres without
+// coordinates are never returned. With withShows, each theatre carries its
+// next few shows of the day.
+func (m TheatresModel) Nearby(lat, lng, radiusKm float64, limit int, withShows bool) ([]*entity.NearbyTheatre, error) {
+	query := `
+		SELECT id, name, city, address, latitude, longitude, phone, opening_hours, amenities, version, distance
+		FROM (
+			SELECT *,
+				2 * $5::float8 * asin(LEAST(1, sqrt(
+					power(sin(radians(latitude - $1::float8) / 2), 2)
+					+ cos(radians($1::float8)) * cos(radians(latitude)) * power(sin(radians(longitude - $2::float8) / 2), 2)
+				))) AS distance
+			FROM theatres
+			WHERE deleted_at IS NULL
+			AND latitude BETWEEN $6::float8 AND $7::float8
+		) t
+		WHERE distance <= $3::float8
+		ORDER BY distance ASC, id ASC
+		LIMIT $4
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// The latitude band narrows the scan before distances are computed.
+	band := radiusKm / kmPerDegreeLatitude
+
+	args := []interface{}{lat, lng, radiusKm, limit, earthRadiusKm, lat - band, lat + band}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	theatres := []*entity.NearbyTheatre{}
+	for rows.Next() {
+		var theatre entity.NearbyTheatre
+		var openingHours []byte
+
+		err := rows.Scan(
+			&theatre.ID,
+			&theatre.Name,
+			&theatre.City,
+			&theatre.Address,
+			&theatre.Latitude,
+			&theatre.Longitude,
+			&theatre.Phone,
+			&openingHours,
+			pq.Array(&theatre.Amenities),
+			&theatre.Version,
+			&theatre.DistanceKm,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(openingHours, &theatre.OpeningHours)
+		if err != nil {
+			return nil, err
+		}
+
+		theatres = append(theatres, &theatre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if withShows {
+		err = m.attachNextShows(ctx, theatres)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return theatres, nil
+}
+
+// attachNextShows loads the next few shows of today for each theatre.
+func (m TheatresModel) attachNextShows(ctx context.Context, theatres []*entity.NearbyTheatre) error {
+	if len(theatres) == 0 {
+		return nil
+	}
+
+	byTheatre := make(map[int64]*entity.NearbyTheatre, len(theatres))
+	theatreIds := make([]int64, 0, len(theatres))
+
+	for _, theatre := range theatres {
+		theatre.NextShows = []*entity.Show{}
+		byTheatre[theatre.ID] = theatre
+		theatreIds = append(theatreIds, theatre.ID)
+	}
+
+	query := `
+		SELECT theatre_id, id, showtime, movie_id, screen_id, format, audio_language, subtitle_language, version
+		FROM (
+			SELECT sc.theatre_id, s.id, s.showtime, s.movie_id, s.screen_id, s.format, s.audio_language, s.subtitle_language, s.version,
+				row_number() OVER (PARTITION BY sc.theatre_id ORDER BY s.showtime ASC, s.id ASC) AS n
+			FROM shows s
+			INNER JOIN screens sc ON s.screen_id = sc.id
+			WHERE sc.theatre_id = ANY($1)
+			AND s.deleted_at IS NULL
+			AND s.showtime > NOW()
+			AND s.showtime::date = CURRENT_DATE
+		) today
+		WHERE n <= $2
+		ORDER BY showtime ASC, id ASC
+	`
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(theatreIds), nextShowsPerTheatre)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var theatreId int64
+		var show entity.Show
+
+		err := rows.Scan(
+			&theatreId,
+			&show.ID,
+			&show.Showtime,
+			&show.MovieId,
+			&show.ScreenId,
+			&show.Format,
+			&show.AudioLanguage,
+			&show.SubtitleLanguage,
+			&show.Version,
+		)
+		if err != nil {
+			return err
+		}
+
+		theatre := byTheatre[theatreId]
+		theatre.NextShows = append(theatre.NextShows, &show)
+	}
+
+	return rows.Err()
+}
+
 func (m TheatresModel) Update(theatres *entity.Theatres) error {
+	openingHours, err := json.Marshal(theatres.OpeningHours)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE theatres
-		SET name = $1, city = $2
-		WHERE id = $3
+		SET name = $1, city = $2, address = $3, latitude = $4, longitude = $5, phone = $6,
+			opening_hours = $7, amenities = $8, version = version + 1
+		WHERE id = $9 AND version = $10
+		RETURNING version
 	`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -105,39 +341,63 @@ func (m TheatresModel) Update(theatres *entity.Theatres) error {
 	args := []interface{}{
 		theatres.Name,
 		theatres.City,
+		theatres.Address,
+		theatres.Latitude,
+		theatres.Longitude,
+		theatres.Phone,
+		openingHours,
+		pq.Array(theatres.Amenities),
 		theatres.ID,
+		theatres.Version,
 	}
 
-	_, err := m.DB.ExecContext(ctx, query, args)
-
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&theatres.Version)
 	if err != nil {
-		return err
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return ErrDuplicateConstraint
+		}
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
 	}
 
 	return nil
 }
 
+// Delete archives a theatre with its screens, seats and shows. It returns
+// ErrActiveReservations while any of its upcoming shows has paid bookings.
 func (m TheatresModel) Delete(theatreId int64) error {
-	query := `
-		DELETE FROM theatres
-		WHERE id = $1
-	`
+	if theatreId < 1 {
+		return ErrRecordNotFound
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, theatreId)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	reserved, err := hasUpcomingPaidReservations(ctx, tx, reservedTheatre, theatreId)
+	if err != nil {
+		return err
+	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	if reserved {
+		return ErrActiveReservations
 	}
 
-	return nil
+	err = archive(ctx, tx, entity.ArchiveTheatre, theatreId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func ValidateTheatres(v *validator.Validator, theatres *entity.Theatres) {
@@ -146,4 +406,39 @@ func ValidateTheatres(v *validator.Validator, theatres *entity.Theatres) {
 
 	v.Check(theatres.City != "", "city", "must be provided")
 	v.Check(len(theatres.City) < 100, "city", "must not be more than 100 characters")
+
+	v.Check(len(theatres.Address) <= 500, "address", "must not be more than 500 characters")
+
+	v.Check((theatres.Latitude == nil) == (theatres.Longitude == nil), "latitude", "must be provided together with longitude")
+	if theatres.Latitude != nil {
+		v.Check(*theatres.Latitude >= -90 && *theatres.Latitude <= 90, "latitude", "must be between -90 and 90")
+	}
+	if theatres.Longitude != nil {
+		v.Check(*theatres.Longitude >= -180 && *theatres.Longitude <= 180, "longitude", "must be between -180 and 180")
+	}
+
+	if theatres.Phone != "" {
+		v.Check(validator.Matches(theatres.Phone, phoneRX), "phone", "must be a valid phone number")
+	}
+
+	for day, hours := range theatres.OpeningHours {
+		v.Check(validator.In(day, entity.Weekdays...), "opening_hours", "must be keyed by mon, tue, wed, thu, fri, sat or sun")
+		v.Check(validator.Matches(hours, openingHoursRX), "opening_hours", "must look like 09:00-23:30")
+	}
+
+	v.Check(len(theatres.Amenities) <= 20, "amenities", "must not contain more than 20 amenities")
+	v.Check(validator.Unique(theatres.Amenities), "amenities", "must not contain duplicate values")
+	for _, amenity := range theatres.Amenities {
+		v.Check(amenity != "", "amenities", "must not contain empty values")
+		v.Check(len(amenity) <= 50, "amenities", "must not contain values more than 50 characters")
+	}
+}
+
+func ValidateNearby(v *validator.Validator, lat, lng, radiusKm float64, limit int) {
+	v.Check(lat >= -90 && lat <= 90, "lat", "must be between -90 and 90")
+	v.Check(lng >= -180 && lng <= 180, "lng", "must be between -180 and 180")
+	v.Check(radiusKm > 0, "radius_km", "must be greater than zero")
+	v.Check(radiusKm <= 100, "radius_km", "must be a maximum of 100")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 50, "limit", "must be a maximum of 50")
 }
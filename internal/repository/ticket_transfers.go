@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrTransferPending = errors.New("seat already has a pending transfer")
+
+type TicketTransferModel struct {
+	DB *sql.DB
+}
+
+// Insert offers seats of a reservation to another user. It returns
+// ErrEditConflict if the reservation no longer holds one of the seats,
+// ErrTransferPending if one is already being transferred and ErrSeatListed
+// if one is listed for resale.
+func (m TicketTransferModel) Insert(transfer *entity.TicketTransfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `SELECT id FROM reservations WHERE id = $1 FOR UPDATE`, transfer.ReservationId)
+	if err != nil {
+		return err
+	}
+
+	var held int
+
+	heldQuery := `
+		SELECT count(*)
+		FROM reservation_seat
+		WHERE reservation_id = $1 AND seat_id = ANY($2)
+	`
+
+	err = tx.QueryRowContext(ctx, heldQuery, transfer.ReservationId, pq.Array(transfer.SeatIds)).Scan(&held)
+	if err != nil {
+		return err
+	}
+
+	if held != len(transfer.SeatIds) {
+		return ErrEditConflict
+	}
+
+	pending, err := hasPendingTransfer(ctx, tx, transfer.ReservationId, transfer.SeatIds)
+	if err != nil {
+		return err
+	}
+
+	if pending {
+		return ErrTransferPending
+	}
+
+	listed, err := hasActiveListing(ctx, tx, transfer.ReservationId, transfer.SeatIds)
+	if err != nil {
+		return err
+	}
+
+	if listed {
+		return ErrSeatListed
+	}
+
+	query := `
+		INSERT INTO ticket_transfers (reservation_id, from_user_id, to_user_id, seat_ids)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status
+	`
+
+	args := []interface{}{transfer.ReservationId, transfer.FromUserId, transfer.ToUserId, pq.Array(transfer.SeatIds)}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&transfer.ID, &transfer.CreatedAt, &transfer.Status)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m TicketTransferModel) Get(id int64) (*entity.TicketTransfer, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, reservation_id, from_user_id, to_user_id, seat_ids, status, new_reservation_id, responded_at
+		FROM ticket_transfers
+		WHERE id = $1
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	transfer, err := scanTicketTransfer(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return transfer, nil
+}
+
+// GetAllForReservation returns the transfer history of a reservation: the
+// transfers out of it and the one it was created by, newest first.
+func (m TicketTransferModel) GetAllForReservation(reservationId int64) ([]*entity.TicketTransfer, error) {
+	query := `
+		SELECT id, created_at, reservation_id, from_user_id, to_user_id, seat_ids, status, new_reservation_id, responded_at
+		FROM ticket_transfers
+		WHERE reservation_id = $1 OR new_reservation_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+
+	return m.getAll(query, reservationId)
+}
+
+// GetPendingForUser returns the transfers waiting for the user to accept or
+// decline them.
+func (m TicketTransferModel) GetPendingForUser(userId int64) ([]*entity.TicketTransfer, error) {
+	query := `
+		SELECT id, created_at, reservation_id, from_user_id, to_user_id, seat_ids, status, new_reservation_id, responded_at
+		FROM ticket_transfers
+		WHERE to_user_id = $1 AND status = 'pending'
+		ORDER BY created_at DESC, id DESC
+	`
+
+	return m.getAll(query, userId)
+}
+
+func (m TicketTransferModel) getAll(query string, id int64) ([]*entity.TicketTransfer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []*entity.TicketTransfer{}
+	for rows.Next() {
+		transfer, err := scanTicketTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		transfers = append(transfers, transfer)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// Accept moves the seats of a pending transfer to a new paid reservation
+// owned by the recipient. The new reservation carries no amount since the
+// sender paid for the seats. Both reservations get a new ticket token so the
+// one the sender held no longer admits anyone. It returns ErrEditConflict if
+// the transfer is no longer pending or the seats cannot be moved.
+func (m TicketTransferModel) Accept(transfer *entity.TicketTransfer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+
+	err = tx.QueryRowContext(ctx, `SELECT status FROM ticket_transfers WHERE id = $1 FOR UPDATE`, transfer.ID).Scan(&status)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if status != entity.TransferPending {
+		return ErrEditConflict
+	}
+
+	// Seats cannot move while the reservation is being used or a seat change
+	// on it is waiting for payment.
+	lockQuery := `
+		SELECT show_id
+		FROM reservations r
+		WHERE id = $1 AND status = 'success' AND checked_in_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM seat_changes WHERE reservation_id = r.id AND status = 'pending')
+		FOR UPDATE
+	`
+
+	var showId int64
+
+	err = tx.QueryRowContext(ctx, lockQuery, transfer.ReservationId).Scan(&showId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	token, err := generateTicketToken()
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO reservations (user_id, amount, show_id, status, ticket_token, transferred_from)
+		VALUES ($1, 0, $2, 'success', $3, $4)
+		RETURNING id
+	`
+
+	var newReservationId int64
+
+	err = tx.QueryRowContext(ctx, insertQuery, transfer.ToUserId, showId, token, transfer.ReservationId).Scan(&newReservationId)
+	if err != nil {
+		return err
+	}
+
+	moveQuery := `
+		UPDATE reservation_seat
+		SET reservation_id = $2
+		WHERE reservation_id = $1 AND seat_id = ANY($3)
+	`
+
+	result, err := tx.ExecContext(ctx, moveQuery, transfer.ReservationId, newReservationId, pq.Array(transfer.SeatIds))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(transfer.SeatIds)) {
+		return ErrEditConflict
+	}
+
+	token, err = generateTicketToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reservations SET ticket_token = $2 WHERE id = $1`, transfer.ReservationId, token)
+	if err != nil {
+		return err
+	}
+
+	updateQuery := `
+		UPDATE ticket_transfers
+		SET status = 'accepted', new_reservation_id = $2, responded_at = NOW()
+		WHERE id = $1
+		RETURNING status, new_reservation_id, responded_at
+	`
+
+	err = tx.QueryRowContext(ctx, updateQuery, transfer.ID, newReservationId).Scan(&transfer.Status, &transfer.NewReservationId, &transfer.RespondedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus closes a pending transfer as declined or cancelled. It
+// returns ErrEditConflict if the transfer is no longer pending.
+func (m TicketTransferModel) UpdateStatus(transfer *entity.TicketTransfer, status string) error {
+	query := `
+		UPDATE ticket_transfers
+		SET status = $2, responded_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+		RETURNING status, responded_at
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, transfer.ID, status).Scan(&transfer.Status, &transfer.RespondedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasPendingTransfer reports whether any of the seats of a reservation is
+// offered in a transfer that has not been answered yet.
+func hasPendingTransfer(ctx context.Context, tx *sql.Tx, reservationId int64, seatIds []int64) (bool, error) {
+	query := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM ticket_transfers
+			WHERE reservation_id = $1 AND status = 'pending' AND seat_ids && $2
+		)
+	`
+
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, query, reservationId, pq.Array(seatIds)).Scan(&exists)
+	return exists, err
+}
+
+func scanTicketTransfer(row rowScanner) (*entity.TicketTransfer, error) {
+	var transfer entity.TicketTransfer
+
+	err := row.Scan(
+		&transfer.ID,
+		&transfer.CreatedAt,
+		&transfer.ReservationId,
+		&transfer.FromUserId,
+		&transfer.ToUserId,
+		(*pq.Int64Array)(&transfer.SeatIds),
+		&transfer.Status,
+		&transfer.NewReservationId,
+		&transfer.RespondedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transfer, nil
+}
+
+func ValidateTicketTransfer(v *validator.Validator, transfer *entity.TicketTransfer) {
+	v.Check(transfer.ToUserId != transfer.FromUserId, "email", "must not be your own email address")
+
+	v.Check(len(transfer.SeatIds) > 0, "seat_ids", "must contain at least 1 seat")
+	v.Check(validator.Unique(transfer.SeatIds), "seat_ids", "must not contain duplicate values")
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+	"greenlight.zuyanh.net/internal/validator"
+)
+
+var ErrTicketTypeNotOffered = errors.New("ticket type not offered for the show")
+
+type TicketTypeModel struct {
+	DB *sql.DB
+}
+
+func (m TicketTypeModel) GetAll() ([]*entity.TicketType, error) {
+	query := `
+		SELECT code, name, adjustment, amount, version
+		FROM ticket_types
+		ORDER BY code ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ticketTypes := []*entity.TicketType{}
+	for rows.Next() {
+		var ticketType entity.TicketType
+
+		err := rows.Scan(
+			&ticketType.Code,
+			&ticketType.Name,
+			&ticketType.Adjustment,
+			&ticketType.Amount,
+			&ticketType.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		ticketTypes = append(ticketTypes, &ticketType)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ticketTypes, nil
+}
+
+func (m TicketTypeModel) Get(code string) (*entity.TicketType, error) {
+	query := `
+		SELECT code, name, adjustment, amount, version
+		FROM ticket_types
+		WHERE code = $1
+	`
+
+	var ticketType entity.TicketType
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, code).Scan(
+		&ticketType.Code,
+		&ticketType.Name,
+		&ticketType.Adjustment,
+		&ticketType.Amount,
+		&ticketType.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &ticketType, nil
+}
+
+// Update changes the name and default price modifier of a ticket type.
+func (m TicketTypeModel) Update(ticketType *entity.TicketType) error {
+	query := `
+		UPDATE ticket_types
+		SET name = $1, adjustment = $2, amount = $3, version = version + 1
+		WHERE code = $4 AND version = $5
+		RETURNING version
+	`
+
+	args := []interface{}{
+		ticketType.Name,
+		ticketType.Adjustment,
+		ticketType.Amount,
+		ticketType.Code,
+		ticketType.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&ticketType.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetForShow returns the ticket types sold for a show with their effective
+// modifiers. Shows without their own list offer every type at its default.
+func (m TicketTypeModel) GetForShow(showId int64) ([]*entity.TicketType, error) {
+	query := `
+		SELECT t.code, t.name, COALESCE(st.adjustment, t.adjustment), COALESCE(st.amount, t.amount)
+		FROM ticket_types t
+		LEFT JOIN show_ticket_types st ON st.ticket_type = t.code AND st.show_id = $1
+		WHERE st.show_id IS NOT NULL
+		OR NOT EXISTS (SELECT 1 FROM show_ticket_types WHERE show_id = $1)
+		ORDER BY t.code ASC
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, showId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ticketTypes := []*entity.TicketType{}
+	for rows.Next() {
+		var ticketType entity.TicketType
+
+		err := rows.Scan(
+			&ticketType.Code,
+			&ticketType.Name,
+			&ticketType.Adjustment,
+			&ticketType.Amount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		ticketTypes = append(ticketTypes, &ticketType)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ticketTypes, nil
+}
+
+// SetForShow replaces the ticket types sold for a show. An empty list makes
+// the show offer every type at its default modifier again.
+func (m TicketTypeModel) SetForShow(showId int64, ticketTypes []*entity.TicketType) error {
+	codes := make([]string, len(ticketTypes))
+	adjustments := make([]string, len(ticketTypes))
+	amounts := make([]int64, len(ticketTypes))
+	for i, ticketType := range ticketTypes {
+		codes[i] = ticketType.Code
+		adjustments[i] = ticketType.Adjustment
+		amounts[i] = ticketType.Amount
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM show_ticket_types WHERE show_id = $1`, showId)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO show_ticket_types (show_id, ticket_type, adjustment, amount)
+		SELECT $1, x.code, x.adjustment, x.amount
+		FROM unnest($2::text[], $3::text[], $4::bigint[]) AS x(code, adjustment, amount)
+	`
+
+	_, err = tx.ExecContext(ctx, insertQuery, showId, pq.Array(codes), pq.Array(adjustments), pq.Array(amounts))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return ErrViolatesForeignKey
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ValidateTicketType(v *validator.Validator, ticketType *entity.TicketType) {
+	v.Check(validator.In(ticketType.Code, entity.TicketTypes...), "code", "must be one of adult, child, student or senior")
+
+	v.Check(validator.In(ticketType.Adjustment, entity.AdjustPercent, entity.AdjustAmount), "adjustment", "must be percent or amount")
+	if ticketType.Adjustment == entity.AdjustPercent {
+		v.Check(ticketType.Amount >= -100 && ticketType.Amount <= 100, "amount", "must be between -100 and 100 percent")
+	}
+	if ticketType.Adjustment == entity.AdjustAmount {
+		v.Check(ticketType.Amount >= -10000000 && ticketType.Amount <= 10000000, "amount", "must be between -10000000 and 10000000")
+	}
+}
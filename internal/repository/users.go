@@ -56,7 +56,7 @@ func (m UserModel) Insert(user *entity.User) error {
 
 func (m UserModel) GetByEmail(email string) (*entity.User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, date_of_birth, dob_verified, student_verified, version
 		FROM users
 		WHERE email = $1
 	`
@@ -72,6 +72,9 @@ func (m UserModel) GetByEmail(email string) (*entity.User, error) {
 		&user.Email,
 		&user.Password.Hash,
 		&user.Activated,
+		&user.DateOfBirth,
+		&user.DobVerified,
+		&user.StudentVerified,
 		&user.Version,
 	)
 
@@ -89,7 +92,7 @@ func (m UserModel) GetByEmail(email string) (*entity.User, error) {
 
 func (m UserModel) GetById(id int64) (*entity.User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, date_of_birth, dob_verified, student_verified, version
 		FROM users
 		WHERE id = $1
 	`
@@ -105,6 +108,9 @@ func (m UserModel) GetById(id int64) (*entity.User, error) {
 		&user.Email,
 		&user.Password.Hash,
 		&user.Activated,
+		&user.DateOfBirth,
+		&user.DobVerified,
+		&user.StudentVerified,
 		&user.Version,
 	)
 
@@ -123,8 +129,8 @@ func (m UserModel) GetById(id int64) (*entity.User, error) {
 func (m UserModel) Update(user *entity.User) error {
 	query := `
 		UPDATE users 
-        SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-        WHERE id = $5 AND version = $6
+        SET name = $1, email = $2, password_hash = $3, activated = $4, date_of_birth = $5, dob_verified = $6, student_verified = $7, version = version + 1
+        WHERE id = $8 AND version = $9
         RETURNING version
 	`
 	args := []interface{}{
@@ -132,6 +138,9 @@ func (m UserModel) Update(user *entity.User) error {
 		user.Email,
 		user.Password.Hash,
 		user.Activated,
+		user.DateOfBirth,
+		user.DobVerified,
+		user.StudentVerified,
 		user.ID,
 		user.Version,
 	}
@@ -158,7 +167,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*entity.User,
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
 	query := `
-		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.date_of_birth, users.dob_verified, users.student_verified, users.version
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
@@ -181,6 +190,9 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*entity.User,
 		&user.Email,
 		&user.Password.Hash,
 		&user.Activated,
+		&user.DateOfBirth,
+		&user.DobVerified,
+		&user.StudentVerified,
 		&user.Version,
 	)
 
@@ -217,6 +229,11 @@ func ValidateUser(v *validator.Validator, user *entity.User) {
 		ValidatePasswordPlaintext(v, *user.Password.Plaintext)
 	}
 
+	if user.DateOfBirth != nil {
+		v.Check(user.DateOfBirth.Before(time.Now()), "date_of_birth", "must not be in the future")
+		v.Check(user.DateOfBirth.Year() >= 1900, "date_of_birth", "must be after 1900")
+	}
+
 	if user.Password.Hash == nil {
 		panic("missing password hash for user")
 	}
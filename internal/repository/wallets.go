@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"greenlight.zuyanh.net/internal/entity"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient wallet balance")
+
+type WalletModel struct {
+	DB *sql.DB
+}
+
+// Get returns the user's wallet. Users who never had a wallet transaction
+// have an empty one.
+func (m WalletModel) Get(userId int64) (*entity.Wallet, error) {
+	query := `
+		SELECT COALESCE((SELECT balance FROM wallets WHERE user_id = $1), 0)
+	`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	wallet := &entity.Wallet{UserId: userId}
+
+	err := m.DB.QueryRowContext(ctx, query, userId).Scan(&wallet.Balance)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// GetTransactions returns a page of the user's wallet history with the
+// ledger entries of each transaction.
+func (m WalletModel) GetTransactions(userId int64, filters Filters) ([]*entity.WalletTransaction, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, user_id, kind, reference, amount, balance
+		FROM wallet_transactions
+		WHERE user_id = $1
+		ORDER BY %s %s, id DESC
+		LIMIT $2 OFFSET $3
+	`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []interface{}{userId, filters.limit(), filters.offset()}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+
+	transactions := []*entity.WalletTransaction{}
+	byId := make(map[int64]*entity.WalletTransaction)
+	ids := []int64{}
+
+	for rows.Next() {
+		var transaction entity.WalletTransaction
+
+		err := rows.Scan(
+			&totalRecords,
+			&transaction.ID,
+			&transaction.CreatedAt,
+			&transaction.UserId,
+			&transaction.Kind,
+			&transaction.Reference,
+			&transaction.Amount,
+			&transaction.Balance,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		transactions = append(transactions, &transaction)
+		byId[transaction.ID] = &transaction
+		ids = append(ids, transaction.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	entriesQuery := `
+		SELECT transaction_id, account, user_id, amount
+		FROM wallet_entries
+		WHERE transaction_id = ANY($1)
+		ORDER BY id ASC
+	`
+
+	entryRows, err := m.DB.QueryContext(ctx, entriesQuery, pq.Array(ids))
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer entryRows.Close()
+
+	for entryRows.Next() {
+		var transactionId int64
+		var entry entity.WalletEntry
+
+		err := entryRows.Scan(&transactionId, &entry.Account, &entry.UserId, &entry.Amount)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		byId[transactionId].Entries = append(byId[transactionId].Entries, &entry)
+	}
+
+	if err = entryRows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+	return transactions, metadata, nil
+}
+
+// Debit pays amount from the user's wallet as part of tx. It returns
+// ErrInsufficientFunds if the balance does not cover it.
+func (m WalletModel) Debit(tx *sql.Tx, userId, amount int64, kind, reference string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := postWalletTransaction(ctx, tx, userId, -amount, entity.AccountSales, kind, reference)
+	return err
+}
+
+// postWalletTransaction moves amount into the user's wallet from account, or
+// out of it into account when amount is negative. It records the transaction
+// with a balanced pair of ledger entries and keeps the wallet balance in step.
+func postWalletTransaction(ctx context.Context, tx *sql.Tx, userId, amount int64, account, kind, reference string) (*entity.WalletTransaction, error) {
+	var balance int64
+
+	if amount >= 0 {
+		creditQuery := `
+			INSERT INTO wallets (user_id, balance)
+			VALUES ($1, $2)
+			ON CONFLICT (user_id) DO UPDATE
+			SET balance = wallets.balance + EXCLUDED.balance, version = wallets.version + 1
+			RETURNING balance
+		`
+
+		err := tx.QueryRowContext(ctx, creditQuery, userId, amount).Scan(&balance)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		debitQuery := `
+			UPDATE wallets
+			SET balance = balance + $2, version = version + 1
+			WHERE user_id = $1 AND balance + $2 >= 0
+			RETURNING balance
+		`
+
+		err := tx.QueryRowContext(ctx, debitQuery, userId, amount).Scan(&balance)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return nil, ErrInsufficientFunds
+			default:
+				return nil, err
+			}
+		}
+	}
+
+	transaction := &entity.WalletTransaction{
+		UserId:    userId,
+		Kind:      kind,
+		Reference: reference,
+		Amount:    amount,
+		Balance:   balance,
+		Entries: []*entity.WalletEntry{
+			{Account: entity.AccountWallet, UserId: &userId, Amount: amount},
+			{Account: account, Amount: -amount},
+		},
+	}
+
+	insertQuery := `
+		INSERT INTO wallet_transactions (user_id, kind, reference, amount, balance)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+
+	args := []interface{}{userId, kind, reference, amount, balance}
+
+	err := tx.QueryRowContext(ctx, insertQuery, args...).Scan(&transaction.ID, &transaction.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	entriesQuery := `
+		INSERT INTO wallet_entries (transaction_id, account, user_id, amount)
+		VALUES ($1, $2, $3, $4), ($1, $5, NULL, $6)
+	`
+
+	args = []interface{}{transaction.ID, entity.AccountWallet, userId, amount, account, -amount}
+
+	_, err = tx.ExecContext(ctx, entriesQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
@@ -0,0 +1,16 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("file not found")
+
+// Storage persists uploaded files under slash-separated keys such as
+// "movies/12/3f9a.jpg". Keys are always relative and never contain "..".
+type Storage interface {
+	Save(key string, data io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS movie_credits_person_idx;
+DROP TABLE IF EXISTS movie_credits;
+
+DROP INDEX IF EXISTS people_name_idx;
+DROP TABLE IF EXISTS people;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS theatre_images_theatre_idx;
+DROP TABLE IF EXISTS theatre_images;
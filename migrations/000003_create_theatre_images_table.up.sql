@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS theatre_images (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    theatre_id bigint NOT NULL REFERENCES theatres ON DELETE CASCADE,
+    url text NOT NULL,
+    thumbnail_url text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS theatre_images_theatre_idx ON theatre_images (theatre_id);
@@ -0,0 +1,7 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS checked_in_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS dob_verified;
+ALTER TABLE users DROP COLUMN IF EXISTS date_of_birth;
+
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_age_rating_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS age_rating;
@@ -0,0 +1,7 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS age_rating text NOT NULL DEFAULT 'P';
+ALTER TABLE movies ADD CONSTRAINT movies_age_rating_check CHECK (age_rating IN ('P', 'C13', 'C16', 'C18'));
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_of_birth date;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dob_verified bool NOT NULL DEFAULT false;
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS checked_in_at timestamp(0) with time zone;
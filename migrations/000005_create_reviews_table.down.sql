@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS review_moderations_review_idx;
+DROP TABLE IF EXISTS review_moderations;
+
+DROP INDEX IF EXISTS reviews_movie_idx;
+DROP TABLE IF EXISTS reviews;
+
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE movies DROP COLUMN IF EXISTS rating_average;
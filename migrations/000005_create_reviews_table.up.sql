@@ -0,0 +1,32 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_average numeric(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS rating_count integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    rating smallint NOT NULL,
+    body text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'visible',
+    version integer NOT NULL DEFAULT 1,
+    CONSTRAINT reviews_movie_user_unique UNIQUE (movie_id, user_id)
+);
+
+ALTER TABLE reviews ADD CONSTRAINT reviews_rating_check CHECK (rating BETWEEN 1 AND 5);
+ALTER TABLE reviews ADD CONSTRAINT reviews_status_check CHECK (status IN ('visible', 'flagged', 'hidden'));
+
+CREATE INDEX IF NOT EXISTS reviews_movie_idx ON reviews (movie_id);
+
+-- The audit trail deliberately has no foreign key to reviews so that entries
+-- survive the deletion of the review they describe.
+CREATE TABLE IF NOT EXISTS review_moderations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    review_id bigint NOT NULL,
+    moderator_id bigint REFERENCES users ON DELETE SET NULL,
+    action text NOT NULL,
+    reason text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS review_moderations_review_idx ON review_moderations (review_id);
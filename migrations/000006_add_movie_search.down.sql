@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS movies_language_idx;
+ALTER TABLE movies DROP COLUMN IF EXISTS language;
+
+DROP INDEX IF EXISTS movies_title_trgm_idx;
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- unaccent() is only STABLE because its dictionary can be changed at runtime,
+-- which keeps it out of index expressions. Pinning the dictionary makes the
+-- wrapper safe to declare IMMUTABLE.
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text AS
+$$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$
+LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+CREATE INDEX IF NOT EXISTS movies_title_trgm_idx ON movies USING GIN (immutable_unaccent(lower(title)) gin_trgm_ops);
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS language text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS movies_language_idx ON movies (language);
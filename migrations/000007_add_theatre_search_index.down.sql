@@ -0,0 +1 @@
+DROP INDEX IF EXISTS theatres_search_idx;
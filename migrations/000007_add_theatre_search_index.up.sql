@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS theatres_search_idx ON theatres USING GIN (to_tsvector('simple', name || ' ' || city));
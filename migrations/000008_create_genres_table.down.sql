@@ -0,0 +1,18 @@
+DROP FUNCTION IF EXISTS movie_genre_slugs(bigint);
+
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS genres text[] NOT NULL DEFAULT '{}';
+
+UPDATE movies m
+SET genres = (
+    SELECT COALESCE(array_agg(g.slug ORDER BY g.slug), '{}')
+    FROM movie_genres mg
+    INNER JOIN genres g ON mg.genre_id = g.id
+    WHERE mg.movie_id = m.id
+);
+
+CREATE INDEX IF NOT EXISTS movies_genres_idx ON movies USING GIN (genres);
+
+DROP INDEX IF EXISTS movie_genres_genre_idx;
+DROP TABLE IF EXISTS movie_genres;
+DROP TABLE IF EXISTS genres;
+DROP FUNCTION IF EXISTS genre_slug(text);
@@ -0,0 +1,86 @@
+CREATE OR REPLACE FUNCTION genre_slug(text) RETURNS text AS
+$$ SELECT trim(both '-' FROM regexp_replace(lower(immutable_unaccent($1)), '[^a-z0-9]+', '-', 'g')) $$
+LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    slug text NOT NULL UNIQUE,
+    name text NOT NULL,
+    names jsonb NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE genres ADD CONSTRAINT genres_slug_check CHECK (slug ~ '^[a-z0-9]+(-[a-z0-9]+)*$');
+
+CREATE TABLE IF NOT EXISTS movie_genres (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    genre_id bigint NOT NULL REFERENCES genres ON DELETE RESTRICT,
+    PRIMARY KEY (movie_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_genres_genre_idx ON movie_genres (genre_id);
+
+INSERT INTO genres (slug, name, names)
+VALUES
+    ('action', 'Action', '{"vi": "Hành động"}'),
+    ('adventure', 'Adventure', '{"vi": "Phiêu lưu"}'),
+    ('animation', 'Animation', '{"vi": "Hoạt hình"}'),
+    ('comedy', 'Comedy', '{"vi": "Hài"}'),
+    ('crime', 'Crime', '{"vi": "Tội phạm"}'),
+    ('documentary', 'Documentary', '{"vi": "Tài liệu"}'),
+    ('drama', 'Drama', '{"vi": "Chính kịch"}'),
+    ('family', 'Family', '{"vi": "Gia đình"}'),
+    ('fantasy', 'Fantasy', '{"vi": "Giả tưởng"}'),
+    ('horror', 'Horror', '{"vi": "Kinh dị"}'),
+    ('musical', 'Musical', '{"vi": "Nhạc kịch"}'),
+    ('mystery', 'Mystery', '{"vi": "Bí ẩn"}'),
+    ('romance', 'Romance', '{"vi": "Lãng mạn"}'),
+    ('sci-fi', 'Sci-Fi', '{"vi": "Khoa học viễn tưởng"}'),
+    ('thriller', 'Thriller', '{"vi": "Giật gân"}'),
+    ('war', 'War', '{"vi": "Chiến tranh"}')
+ON CONFLICT (slug) DO NOTHING;
+
+-- Normalise the free-text arrays: lower-case, strip accents and punctuation,
+-- and fold a trailing "s" when the singular form also exists, so that
+-- "Action", "action" and "actions" all become "action".
+CREATE TEMPORARY TABLE raw_movie_genres AS
+SELECT m.id AS movie_id,
+       genre_slug(g) AS slug,
+       min(trim(g)) AS name
+FROM movies m, unnest(m.genres) g
+GROUP BY 1, 2;
+
+DELETE FROM raw_movie_genres WHERE slug = '';
+
+UPDATE raw_movie_genres r
+SET slug = left(r.slug, -1)
+WHERE r.slug LIKE '%s'
+AND (
+    EXISTS (SELECT 1 FROM genres WHERE slug = left(r.slug, -1))
+    OR EXISTS (SELECT 1 FROM raw_movie_genres r2 WHERE r2.slug = left(r.slug, -1))
+);
+
+INSERT INTO genres (slug, name)
+SELECT slug, initcap(min(name))
+FROM raw_movie_genres
+GROUP BY slug
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO movie_genres (movie_id, genre_id)
+SELECT DISTINCT r.movie_id, g.id
+FROM raw_movie_genres r
+INNER JOIN genres g ON g.slug = r.slug
+ON CONFLICT DO NOTHING;
+
+DROP TABLE raw_movie_genres;
+
+DROP INDEX IF EXISTS movies_genres_idx;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS genres_length_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS genres;
+
+CREATE OR REPLACE FUNCTION movie_genre_slugs(bigint) RETURNS text[] AS
+$$ SELECT COALESCE(array_agg(g.slug ORDER BY g.slug), '{}')
+   FROM movie_genres mg
+   INNER JOIN genres g ON mg.genre_id = g.id
+   WHERE mg.movie_id = $1 $$
+LANGUAGE sql STABLE;
@@ -0,0 +1,3 @@
+-- external_id identifies a movie in the distributor's catalogue so bulk
+-- imports can be re-run to update rows instead of duplicating them.
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS external_id text UNIQUE;
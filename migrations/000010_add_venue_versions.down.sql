@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS reservation_seat_seat_id_idx;
+DROP INDEX IF EXISTS reservations_show_id_idx;
+
+ALTER TABLE shows DROP COLUMN IF EXISTS version;
+ALTER TABLE seats DROP COLUMN IF EXISTS version;
+ALTER TABLE screens DROP COLUMN IF EXISTS version;
+ALTER TABLE theatres DROP COLUMN IF EXISTS version;
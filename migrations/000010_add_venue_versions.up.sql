@@ -0,0 +1,7 @@
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE screens ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS reservations_show_id_idx ON reservations (show_id);
+CREATE INDEX IF NOT EXISTS reservation_seat_seat_id_idx ON reservation_seat (seat_id);
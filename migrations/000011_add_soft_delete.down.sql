@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS shows_deleted_at_idx;
+DROP INDEX IF EXISTS seats_deleted_at_idx;
+DROP INDEX IF EXISTS screens_deleted_at_idx;
+DROP INDEX IF EXISTS theatres_deleted_at_idx;
+DROP INDEX IF EXISTS movies_deleted_at_idx;
+
+ALTER TABLE shows DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE seats DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE screens DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE theatres DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE movies DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,13 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE screens ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS deleted_at timestamp(0) with time zone;
+
+-- Archived rows are rare, so partial indexes keep the admin archive listing
+-- and the purge job cheap without touching the public queries.
+CREATE INDEX IF NOT EXISTS movies_deleted_at_idx ON movies (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS theatres_deleted_at_idx ON theatres (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS screens_deleted_at_idx ON screens (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS seats_deleted_at_idx ON seats (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS shows_deleted_at_idx ON shows (deleted_at) WHERE deleted_at IS NOT NULL;
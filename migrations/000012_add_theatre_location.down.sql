@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS theatres_latitude_idx;
+
+ALTER TABLE theatres DROP CONSTRAINT IF EXISTS theatres_coordinates_check;
+ALTER TABLE theatres DROP CONSTRAINT IF EXISTS theatres_longitude_check;
+ALTER TABLE theatres DROP CONSTRAINT IF EXISTS theatres_latitude_check;
+
+ALTER TABLE theatres DROP COLUMN IF EXISTS amenities;
+ALTER TABLE theatres DROP COLUMN IF EXISTS opening_hours;
+ALTER TABLE theatres DROP COLUMN IF EXISTS phone;
+ALTER TABLE theatres DROP COLUMN IF EXISTS longitude;
+ALTER TABLE theatres DROP COLUMN IF EXISTS latitude;
+ALTER TABLE theatres DROP COLUMN IF EXISTS address;
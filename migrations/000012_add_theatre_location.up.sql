@@ -0,0 +1,14 @@
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS address text NOT NULL DEFAULT '';
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS latitude double precision;
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS longitude double precision;
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS phone text NOT NULL DEFAULT '';
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS opening_hours jsonb NOT NULL DEFAULT '{}';
+ALTER TABLE theatres ADD COLUMN IF NOT EXISTS amenities text[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE theatres ADD CONSTRAINT theatres_latitude_check CHECK (latitude BETWEEN -90 AND 90);
+ALTER TABLE theatres ADD CONSTRAINT theatres_longitude_check CHECK (longitude BETWEEN -180 AND 180);
+ALTER TABLE theatres ADD CONSTRAINT theatres_coordinates_check CHECK ((latitude IS NULL) = (longitude IS NULL));
+
+-- Nearby searches first narrow theatres down to a latitude band around the
+-- user before computing exact distances.
+CREATE INDEX IF NOT EXISTS theatres_latitude_idx ON theatres (latitude) WHERE latitude IS NOT NULL;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS shows_format_idx;
+
+ALTER TABLE shows DROP COLUMN IF EXISTS subtitle_language;
+ALTER TABLE shows DROP COLUMN IF EXISTS audio_language;
+ALTER TABLE shows DROP COLUMN IF EXISTS format;
+
+ALTER TABLE screens DROP COLUMN IF EXISTS formats;
+
+DROP TABLE IF EXISTS formats;
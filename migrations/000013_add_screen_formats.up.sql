@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS formats (
+    code text PRIMARY KEY,
+    name text NOT NULL,
+    surcharge integer NOT NULL DEFAULT 0 CHECK (surcharge >= 0),
+    version integer NOT NULL DEFAULT 1
+);
+
+INSERT INTO formats (code, name, surcharge) VALUES
+    ('2d', 'Standard 2D', 0),
+    ('3d', '3D', 30000),
+    ('imax', 'IMAX', 60000),
+    ('4dx', '4DX', 80000)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE screens ADD COLUMN IF NOT EXISTS formats text[] NOT NULL DEFAULT '{2d}';
+
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS format text NOT NULL DEFAULT '2d' REFERENCES formats (code);
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS audio_language text NOT NULL DEFAULT '';
+ALTER TABLE shows ADD COLUMN IF NOT EXISTS subtitle_language text NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS shows_format_idx ON shows (format) WHERE deleted_at IS NULL;
@@ -0,0 +1,10 @@
+DROP FUNCTION IF EXISTS show_seat_price(bigint, bigint);
+
+DROP TABLE IF EXISTS show_prices;
+DROP TABLE IF EXISTS screen_prices;
+
+UPDATE seats SET price = 0 WHERE price IS NULL;
+ALTER TABLE seats ALTER COLUMN price SET NOT NULL;
+ALTER TABLE seats DROP COLUMN IF EXISTS category;
+
+DROP TABLE IF EXISTS price_categories;
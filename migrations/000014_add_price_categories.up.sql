@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS price_categories (
+    code text PRIMARY KEY,
+    name text NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE price_categories ADD CONSTRAINT price_categories_code_check CHECK (code ~ '^[a-z0-9]+(-[a-z0-9]+)*$');
+
+INSERT INTO price_categories (code, name)
+VALUES
+    ('standard', 'Standard'),
+    ('vip', 'VIP'),
+    ('couple', 'Couple')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE seats ADD COLUMN IF NOT EXISTS category text NOT NULL DEFAULT 'standard' REFERENCES price_categories (code);
+
+-- seats.price is kept as a per-seat fallback for screens that have no base
+-- price for the seat's category yet. New seats may leave it empty.
+ALTER TABLE seats ALTER COLUMN price DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS screen_prices (
+    screen_id bigint NOT NULL REFERENCES screens ON DELETE CASCADE,
+    category text NOT NULL REFERENCES price_categories (code),
+    price integer NOT NULL CHECK (price > 0),
+    PRIMARY KEY (screen_id, category)
+);
+
+CREATE TABLE IF NOT EXISTS show_prices (
+    show_id bigint NOT NULL REFERENCES shows ON DELETE CASCADE,
+    category text NOT NULL REFERENCES price_categories (code),
+    price integer NOT NULL CHECK (price > 0),
+    PRIMARY KEY (show_id, category)
+);
+
+-- show_seat_price is the price of a seat for a show, before any format
+-- surcharge: the show's override for the seat's category, else the screen's
+-- base price for it, else the seat's own legacy price. NULL means unpriced.
+CREATE OR REPLACE FUNCTION show_seat_price(bigint, bigint) RETURNS integer AS
+$$
+    SELECT COALESCE(shp.price, scp.price, NULLIF(st.price, 0))
+    FROM seats st
+    LEFT JOIN show_prices shp ON shp.show_id = $1 AND shp.category = st.category
+    LEFT JOIN screen_prices scp ON scp.screen_id = st.screen_id AND scp.category = st.category
+    WHERE st.id = $2
+$$
+LANGUAGE sql STABLE PARALLEL SAFE STRICT;
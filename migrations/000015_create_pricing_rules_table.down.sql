@@ -0,0 +1,3 @@
+ALTER TABLE reservation_seat DROP COLUMN IF EXISTS price;
+
+DROP TABLE IF EXISTS pricing_rules;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS pricing_rules (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    priority integer NOT NULL DEFAULT 0,
+    conditions jsonb NOT NULL DEFAULT '{}',
+    adjustment text NOT NULL,
+    amount integer NOT NULL,
+    stop boolean NOT NULL DEFAULT false,
+    valid_from date,
+    valid_to date,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE pricing_rules ADD CONSTRAINT pricing_rules_adjustment_check CHECK (adjustment IN ('percent', 'amount'));
+ALTER TABLE pricing_rules ADD CONSTRAINT pricing_rules_validity_check CHECK (valid_to >= valid_from);
+
+-- The price of each seat is locked in when it is held, so later rule or
+-- price changes do not alter existing reservations.
+ALTER TABLE reservation_seat ADD COLUMN IF NOT EXISTS price bigint;
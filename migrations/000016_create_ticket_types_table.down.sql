@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN IF EXISTS student_verified;
+
+ALTER TABLE reservation_seat DROP COLUMN IF EXISTS ticket_type;
+
+DROP TABLE IF EXISTS show_ticket_types;
+DROP TABLE IF EXISTS ticket_types;
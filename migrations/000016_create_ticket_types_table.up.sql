@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS ticket_types (
+    code text PRIMARY KEY,
+    name text NOT NULL,
+    adjustment text NOT NULL DEFAULT 'percent',
+    amount integer NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE ticket_types ADD CONSTRAINT ticket_types_adjustment_check CHECK (adjustment IN ('percent', 'amount'));
+
+INSERT INTO ticket_types (code, name, adjustment, amount)
+VALUES
+    ('adult', 'Adult', 'percent', 0),
+    ('child', 'Child', 'percent', -30),
+    ('student', 'Student', 'percent', -20),
+    ('senior', 'Senior', 'percent', -30)
+ON CONFLICT (code) DO NOTHING;
+
+-- A show without rows here offers every ticket type at its default
+-- modifier. Once a show has rows, only the listed types are sold for it.
+CREATE TABLE IF NOT EXISTS show_ticket_types (
+    show_id bigint NOT NULL REFERENCES shows ON DELETE CASCADE,
+    ticket_type text NOT NULL REFERENCES ticket_types (code),
+    adjustment text NOT NULL,
+    amount integer NOT NULL,
+    PRIMARY KEY (show_id, ticket_type)
+);
+
+ALTER TABLE show_ticket_types ADD CONSTRAINT show_ticket_types_adjustment_check CHECK (adjustment IN ('percent', 'amount'));
+
+ALTER TABLE reservation_seat ADD COLUMN IF NOT EXISTS ticket_type text NOT NULL DEFAULT 'adult' REFERENCES ticket_types (code);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS student_verified boolean NOT NULL DEFAULT false;
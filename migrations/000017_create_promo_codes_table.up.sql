@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS promo_codes (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    code text NOT NULL UNIQUE,
+    description text NOT NULL DEFAULT '',
+    discount_type text NOT NULL,
+    discount_value integer NOT NULL,
+    min_spend bigint NOT NULL DEFAULT 0,
+    max_discount bigint,
+    valid_from date,
+    valid_to date,
+    movie_ids bigint[] NOT NULL DEFAULT '{}',
+    theatre_ids bigint[] NOT NULL DEFAULT '{}',
+    weekdays text[] NOT NULL DEFAULT '{}',
+    usage_limit integer,
+    per_user_limit integer,
+    active boolean NOT NULL DEFAULT true,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_code_check CHECK (code ~ '^[A-Z0-9_-]+$');
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_discount_type_check CHECK (discount_type IN ('percent', 'amount'));
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_discount_value_check CHECK (discount_value > 0);
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_validity_check CHECK (valid_to >= valid_from);
+
+-- Redemptions outlive their reservation so reversed ones stay on record.
+-- Only redemptions that have not been reversed count towards usage limits.
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    promo_code_id bigint NOT NULL REFERENCES promo_codes ON DELETE CASCADE,
+    reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    discount bigint NOT NULL,
+    reversed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS promo_redemptions_promo_code_idx ON promo_redemptions (promo_code_id, user_id) WHERE reversed_at IS NULL;
+CREATE INDEX IF NOT EXISTS promo_redemptions_reservation_idx ON promo_redemptions (reservation_id);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS discount bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,6 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS wallet_amount;
+
+DROP TABLE IF EXISTS wallet_entries;
+DROP TABLE IF EXISTS wallet_transactions;
+DROP TABLE IF EXISTS wallets;
+DROP TABLE IF EXISTS gift_cards;
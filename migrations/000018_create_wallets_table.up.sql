@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS gift_cards (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    code text NOT NULL UNIQUE,
+    amount bigint NOT NULL,
+    status text NOT NULL DEFAULT 'active',
+    purchased_by bigint REFERENCES users ON DELETE SET NULL,
+    redeemed_by bigint REFERENCES users ON DELETE SET NULL,
+    redeemed_at timestamp(0) with time zone,
+    expires_at date,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE gift_cards ADD CONSTRAINT gift_cards_amount_check CHECK (amount > 0);
+ALTER TABLE gift_cards ADD CONSTRAINT gift_cards_status_check CHECK (status IN ('pending', 'active', 'redeemed'));
+
+-- The balance is a running total of the user's wallet ledger entries, kept
+-- here so it can be locked and checked when paying.
+CREATE TABLE IF NOT EXISTS wallets (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    balance bigint NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE wallets ADD CONSTRAINT wallets_balance_check CHECK (balance >= 0);
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    kind text NOT NULL,
+    reference text NOT NULL DEFAULT '',
+    amount bigint NOT NULL,
+    balance bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS wallet_transactions_user_idx ON wallet_transactions (user_id, created_at);
+
+-- Every transaction posts one entry to the user's wallet and an opposite one
+-- to a counter account, so the entries of a transaction always sum to zero.
+CREATE TABLE IF NOT EXISTS wallet_entries (
+    id bigserial PRIMARY KEY,
+    transaction_id bigint NOT NULL REFERENCES wallet_transactions ON DELETE CASCADE,
+    account text NOT NULL,
+    user_id bigint REFERENCES users ON DELETE CASCADE,
+    amount bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS wallet_entries_transaction_idx ON wallet_entries (transaction_id);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS wallet_amount bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,7 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS points_earned;
+ALTER TABLE reservations DROP COLUMN IF EXISTS points_discount;
+ALTER TABLE reservations DROP COLUMN IF EXISTS points_redeemed;
+
+DROP TABLE IF EXISTS loyalty_transactions;
+DROP TABLE IF EXISTS loyalty_accounts;
+DROP TABLE IF EXISTS loyalty_tiers;
@@ -0,0 +1,42 @@
+-- Tiers are reached by paid spend over the last 365 days. The multiplier is
+-- the percentage of the base earn rate members of the tier get.
+CREATE TABLE IF NOT EXISTS loyalty_tiers (
+    code text PRIMARY KEY,
+    name text NOT NULL,
+    min_spend bigint NOT NULL UNIQUE,
+    multiplier integer NOT NULL,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE loyalty_tiers ADD CONSTRAINT loyalty_tiers_min_spend_check CHECK (min_spend >= 0);
+ALTER TABLE loyalty_tiers ADD CONSTRAINT loyalty_tiers_multiplier_check CHECK (multiplier > 0);
+
+INSERT INTO loyalty_tiers (code, name, min_spend, multiplier) VALUES
+    ('silver', 'Silver', 0, 100),
+    ('gold', 'Gold', 4000000, 125),
+    ('diamond', 'Diamond', 10000000, 150)
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS loyalty_accounts (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    points bigint NOT NULL DEFAULT 0,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE loyalty_accounts ADD CONSTRAINT loyalty_accounts_points_check CHECK (points >= 0);
+
+CREATE TABLE IF NOT EXISTS loyalty_transactions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    kind text NOT NULL,
+    points bigint NOT NULL,
+    balance bigint NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS loyalty_transactions_user_idx ON loyalty_transactions (user_id, created_at);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS points_redeemed bigint NOT NULL DEFAULT 0;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS points_discount bigint NOT NULL DEFAULT 0;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS points_earned bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,5 @@
+ALTER TABLE reservations DROP COLUMN IF EXISTS pickup_code;
+ALTER TABLE reservations DROP COLUMN IF EXISTS concessions_amount;
+
+DROP TABLE IF EXISTS reservation_concessions;
+DROP TABLE IF EXISTS concessions;
@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS concessions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    theatre_id bigint NOT NULL REFERENCES theatres ON DELETE CASCADE,
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    price bigint NOT NULL,
+    stock integer NOT NULL DEFAULT 0,
+    active boolean NOT NULL DEFAULT true,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE concessions ADD CONSTRAINT concessions_price_check CHECK (price >= 0);
+ALTER TABLE concessions ADD CONSTRAINT concessions_stock_check CHECK (stock >= 0);
+
+CREATE INDEX IF NOT EXISTS concessions_theatre_idx ON concessions (theatre_id);
+
+-- Items are sold at the price stored with them, like seats.
+CREATE TABLE IF NOT EXISTS reservation_concessions (
+    reservation_id bigint NOT NULL REFERENCES reservations ON DELETE CASCADE,
+    concession_id bigint NOT NULL REFERENCES concessions ON DELETE RESTRICT,
+    quantity integer NOT NULL,
+    price bigint NOT NULL,
+    PRIMARY KEY (reservation_id, concession_id)
+);
+
+ALTER TABLE reservation_concessions ADD CONSTRAINT reservation_concessions_quantity_check CHECK (quantity > 0);
+
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS concessions_amount bigint NOT NULL DEFAULT 0;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS pickup_code text;
@@ -0,0 +1,15 @@
+-- A seat change moves tickets of a paid reservation to other seats of the
+-- same show. Changes that cost more stay pending, holding the new seats,
+-- until the difference is paid.
+CREATE TABLE IF NOT EXISTS seat_changes (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    reservation_id bigint NOT NULL REFERENCES reservations ON DELETE CASCADE,
+    items jsonb NOT NULL,
+    amount bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending'
+);
+
+ALTER TABLE seat_changes ADD CONSTRAINT seat_changes_status_check CHECK (status IN ('pending', 'success', 'expired'));
+
+CREATE INDEX IF NOT EXISTS seat_changes_reservation_idx ON seat_changes (reservation_id);
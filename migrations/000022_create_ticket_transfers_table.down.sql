@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ticket_transfers;
+DROP INDEX IF EXISTS reservations_ticket_token_idx;
+ALTER TABLE reservations DROP COLUMN IF EXISTS transferred_from;
+ALTER TABLE reservations DROP COLUMN IF EXISTS ticket_token;
@@ -0,0 +1,27 @@
+-- The ticket token is what the holder shows at the door. It is replaced
+-- whenever tickets of the reservation change hands so the old one stops
+-- working.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS ticket_token text;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS transferred_from bigint REFERENCES reservations ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS reservations_ticket_token_idx ON reservations (ticket_token);
+
+-- A transfer hands seats of a paid reservation to another user. Once
+-- accepted the seats move to a new reservation owned by the recipient.
+CREATE TABLE IF NOT EXISTS ticket_transfers (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    reservation_id bigint NOT NULL REFERENCES reservations ON DELETE CASCADE,
+    from_user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    to_user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    seat_ids bigint[] NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    new_reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    responded_at timestamp(0) with time zone
+);
+
+ALTER TABLE ticket_transfers ADD CONSTRAINT ticket_transfers_status_check CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled'));
+ALTER TABLE ticket_transfers ADD CONSTRAINT ticket_transfers_users_check CHECK (from_user_id <> to_user_id);
+
+CREATE INDEX IF NOT EXISTS ticket_transfers_reservation_idx ON ticket_transfers (reservation_id);
+CREATE INDEX IF NOT EXISTS ticket_transfers_to_user_idx ON ticket_transfers (to_user_id);
@@ -0,0 +1,29 @@
+-- A resale listing offers one seat of a paid reservation to other users at
+-- no more than the price it was bought at. The seat stays with the seller
+-- until a buyer has paid.
+CREATE TABLE IF NOT EXISTS resale_listings (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    reservation_id bigint NOT NULL REFERENCES reservations ON DELETE CASCADE,
+    seller_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    show_id bigint NOT NULL REFERENCES shows ON DELETE CASCADE,
+    seat_id bigint NOT NULL REFERENCES seats ON DELETE CASCADE,
+    face_value bigint NOT NULL,
+    price bigint NOT NULL,
+    fee bigint NOT NULL DEFAULT 0,
+    status text NOT NULL DEFAULT 'listed',
+    buyer_id bigint REFERENCES users ON DELETE SET NULL,
+    new_reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    sold_at timestamp(0) with time zone,
+    version integer NOT NULL DEFAULT 1
+);
+
+ALTER TABLE resale_listings ADD CONSTRAINT resale_listings_price_check CHECK (price > 0 AND price <= face_value);
+ALTER TABLE resale_listings ADD CONSTRAINT resale_listings_fee_check CHECK (fee >= 0 AND fee <= price);
+ALTER TABLE resale_listings ADD CONSTRAINT resale_listings_status_check CHECK (status IN ('listed', 'reserved', 'sold', 'withdrawn'));
+
+-- A seat can only be on sale once at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS resale_listings_active_seat_idx ON resale_listings (reservation_id, seat_id) WHERE status IN ('listed', 'reserved');
+
+CREATE INDEX IF NOT EXISTS resale_listings_show_idx ON resale_listings (show_id, status);
+CREATE INDEX IF NOT EXISTS resale_listings_seller_idx ON resale_listings (seller_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS group_shares;
+DROP TABLE IF EXISTS group_bookings;
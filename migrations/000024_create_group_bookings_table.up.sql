@@ -0,0 +1,29 @@
+-- A group booking holds seats for a group until expires_at. The organizer
+-- splits the seats into shares, each paid separately by a member through an
+-- invitation link. Each share has its own pending reservation that becomes
+-- the member's once they pay.
+CREATE TABLE IF NOT EXISTS group_bookings (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    organizer_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    show_id bigint NOT NULL REFERENCES shows ON DELETE CASCADE,
+    expires_at timestamp(0) with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS group_bookings_expires_at_idx ON group_bookings (expires_at);
+
+CREATE TABLE IF NOT EXISTS group_shares (
+    id bigserial PRIMARY KEY,
+    group_booking_id bigint NOT NULL REFERENCES group_bookings ON DELETE CASCADE,
+    reservation_id bigint REFERENCES reservations ON DELETE SET NULL,
+    token text NOT NULL UNIQUE,
+    seat_ids bigint[] NOT NULL,
+    amount bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending',
+    member_id bigint REFERENCES users ON DELETE SET NULL,
+    paid_at timestamp(0) with time zone
+);
+
+ALTER TABLE group_shares ADD CONSTRAINT group_shares_status_check CHECK (status IN ('pending', 'paying', 'paid', 'expired'));
+
+CREATE INDEX IF NOT EXISTS group_shares_group_booking_idx ON group_shares (group_booking_id);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS account_flags;
+DROP INDEX IF EXISTS reservations_payment_identity_idx;
+DROP INDEX IF EXISTS reservations_client_ip_idx;
+ALTER TABLE reservations DROP COLUMN IF EXISTS payment_identity;
+ALTER TABLE reservations DROP COLUMN IF EXISTS client_ip;
+DROP TABLE IF EXISTS show_booking_limits;
@@ -0,0 +1,28 @@
+-- Shows without a row here use the server's default limits.
+CREATE TABLE IF NOT EXISTS show_booking_limits (
+    show_id bigint PRIMARY KEY REFERENCES shows ON DELETE CASCADE,
+    max_seats_per_user integer NOT NULL,
+    max_pending_holds integer NOT NULL
+);
+
+ALTER TABLE show_booking_limits ADD CONSTRAINT show_booking_limits_max_seats_check CHECK (max_seats_per_user > 0);
+ALTER TABLE show_booking_limits ADD CONSTRAINT show_booking_limits_max_holds_check CHECK (max_pending_holds > 0);
+
+-- Where a reservation was made from and who paid for it link accounts that
+-- may belong to the same buyer.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS client_ip text;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS payment_identity text;
+
+CREATE INDEX IF NOT EXISTS reservations_client_ip_idx ON reservations (client_ip, created_at);
+CREATE INDEX IF NOT EXISTS reservations_payment_identity_idx ON reservations (payment_identity);
+
+CREATE TABLE IF NOT EXISTS account_flags (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    show_id bigint REFERENCES shows ON DELETE SET NULL,
+    reason text NOT NULL,
+    resolved_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS account_flags_user_idx ON account_flags (user_id);
@@ -0,0 +1,3 @@
+UPDATE seat_changes SET status = 'expired' WHERE status = 'refunded';
+ALTER TABLE seat_changes DROP CONSTRAINT IF EXISTS seat_changes_status_check;
+ALTER TABLE seat_changes ADD CONSTRAINT seat_changes_status_check CHECK (status IN ('pending', 'success', 'expired'));
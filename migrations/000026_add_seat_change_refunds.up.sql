@@ -0,0 +1,4 @@
+-- A change paid for after it expired is refunded to the wallet and marked so
+-- it is only refunded once.
+ALTER TABLE seat_changes DROP CONSTRAINT IF EXISTS seat_changes_status_check;
+ALTER TABLE seat_changes ADD CONSTRAINT seat_changes_status_check CHECK (status IN ('pending', 'success', 'expired', 'refunded'));
@@ -0,0 +1,17 @@
+-- A resale purchase is one buyer's attempt to pay for a listing. Payments are
+-- matched to the attempt they were made for, so a payment for an attempt that
+-- was released in the meantime is refunded instead of selling the seat to
+-- whoever holds the listing now.
+CREATE TABLE IF NOT EXISTS resale_purchases (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    listing_id bigint NOT NULL REFERENCES resale_listings ON DELETE CASCADE,
+    buyer_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    amount bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending'
+);
+
+ALTER TABLE resale_purchases ADD CONSTRAINT resale_purchases_status_check CHECK (status IN ('pending', 'paid', 'released', 'refunded'));
+
+-- A listing is only being paid for by one buyer at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS resale_purchases_pending_idx ON resale_purchases (listing_id) WHERE status = 'pending';
@@ -0,0 +1,17 @@
+-- A group share payment is one member's attempt to pay for a share.
+-- Payments are matched to the attempt they were made for, so a payment for
+-- an attempt that was released or expired in the meantime is refunded
+-- instead of giving the seats to whoever is paying for the share now.
+CREATE TABLE IF NOT EXISTS group_share_payments (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    share_id bigint NOT NULL REFERENCES group_shares ON DELETE CASCADE,
+    member_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    amount bigint NOT NULL,
+    status text NOT NULL DEFAULT 'pending'
+);
+
+ALTER TABLE group_share_payments ADD CONSTRAINT group_share_payments_status_check CHECK (status IN ('pending', 'paid', 'released', 'refunded'));
+
+-- A share is only being paid for by one member at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS group_share_payments_pending_idx ON group_share_payments (share_id) WHERE status = 'pending';
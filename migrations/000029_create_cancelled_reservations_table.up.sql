@@ -0,0 +1,11 @@
+-- An unpaid reservation that is cancelled, because it was not paid in time
+-- or an admin cancelled the hold, can still be paid through the ZaloPay
+-- order made for it. Such a payment is refunded to the wallet and marked so
+-- it is only refunded once.
+CREATE TABLE IF NOT EXISTS cancelled_reservations (
+    reservation_id bigint PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    amount bigint NOT NULL,
+    refunded_at timestamp(0) with time zone
+);
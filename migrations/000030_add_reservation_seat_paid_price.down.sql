@@ -0,0 +1 @@
+ALTER TABLE reservation_seat DROP COLUMN IF EXISTS paid_price;
@@ -0,0 +1,16 @@
+-- What was actually paid for a seat once the reservation's discounts are
+-- shared out between its tickets. It stays with the seat when the ticket is
+-- transferred, so a ticket cannot be resold for more than was paid for it.
+ALTER TABLE reservation_seat ADD COLUMN IF NOT EXISTS paid_price bigint NOT NULL DEFAULT 0;
+
+-- Existing seats share out what their reservation paid. A seat bought on
+-- resale was paid the reservation's amount, while one received as a gift has
+-- no record of what the sender paid and is left at zero.
+UPDATE reservation_seat rs
+SET paid_price = COALESCE(rs.price, 0) * LEAST(GREATEST(t.total - r.discount - r.points_discount, 0), CASE WHEN r.transferred_from IS NULL THEN t.total ELSE r.amount END) / t.total
+FROM reservations r, (
+    SELECT reservation_id, sum(COALESCE(price, 0)) AS total
+    FROM reservation_seat
+    GROUP BY reservation_id
+) t
+WHERE r.id = rs.reservation_id AND t.reservation_id = rs.reservation_id AND t.total > 0;